@@ -11,6 +11,8 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	// Pos returns the position of the first character of the node
+	Pos() token.Position
 }
 
 // Statement for AST statement interface
@@ -29,6 +31,7 @@ type Expression interface {
 // It's a rot node of AST
 type Program struct {
 	Statements []Statement
+	Comments   []*Comment // all comments of the source, in order
 }
 
 // TokenLiteral returns the first statement's literal
@@ -39,6 +42,14 @@ func (p *Program) TokenLiteral() string {
 	return ""
 }
 
+// Pos returns the position of the first statement
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
 // String returns all statements' string value
 func (p *Program) String() string {
 	var out bytes.Buffer
@@ -52,6 +63,13 @@ func (p *Program) String() string {
 type Identifier struct {
 	Token token.Token // the token.IDENT token
 	Value string
+
+	// Set by the resolver for the variables of functions, which are the
+	// slot Slot of the environment of the function Depth levels out.
+	// Globals and built-ins are looked up by name.
+	Local bool
+	Depth int
+	Slot  int
 }
 
 func (i *Identifier) expressionNode() {}
@@ -59,6 +77,9 @@ func (i *Identifier) expressionNode() {}
 // TokenLiteral returns identifier's literal value
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
 
+// Pos returns the position of the identifier
+func (i *Identifier) Pos() token.Position { return i.Token.Pos }
+
 // String returns the identifier
 func (i *Identifier) String() string { return i.Value }
 
@@ -73,6 +94,9 @@ func (il *IntegerLiteral) expressionNode() {}
 // TokenLiteral returns identifier's literal value
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
 
+// Pos returns the position of the integer
+func (il *IntegerLiteral) Pos() token.Position { return il.Token.Pos }
+
 // String returns the identifier
 func (il *IntegerLiteral) String() string { return il.Token.Literal }
 
@@ -87,6 +111,9 @@ func (pe *PrefixExpression) expressionNode() {}
 
 // TokenLiteral return the operator literal
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+
+// Pos returns the position of the operator
+func (pe *PrefixExpression) Pos() token.Position { return pe.Token.Pos }
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -108,6 +135,10 @@ func (ie *InfixExpression) expressionNode() {}
 
 // TokenLiteral return the left expression literal
 func (ie *InfixExpression) TokenLiteral() string { return ie.Token.Literal }
+
+// Pos returns the position of the left expression
+func (ie *InfixExpression) Pos() token.Position { return ie.Left.Pos() }
+
 func (ie *InfixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -128,7 +159,10 @@ func (b *Boolean) expressionNode() {}
 
 // TokenLiteral returns the boolean value
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
-func (b *Boolean) String() string       { return b.Token.Literal }
+
+// Pos returns the position of the boolean
+func (b *Boolean) Pos() token.Position { return b.Token.Pos }
+func (b *Boolean) String() string      { return b.Token.Literal }
 
 // IfExpression structure
 type IfExpression struct {
@@ -143,6 +177,9 @@ func (ie *IfExpression) expressionNode() {}
 // TokenLiteral returns the if token
 // In Monkey language, the if-else-conditionals are expression. It will produce a value.
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+
+// Pos returns the position of the keyword if
+func (ie *IfExpression) Pos() token.Position { return ie.Token.Pos }
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("if")
@@ -166,6 +203,9 @@ func (bs *BlockStatement) statementNode() {}
 
 // TokenLiteral returns the left brace token
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+
+// Pos returns the position of the left brace
+func (bs *BlockStatement) Pos() token.Position { return bs.Token.Pos }
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 	for _, s := range bs.Statements {
@@ -177,14 +217,22 @@ func (bs *BlockStatement) String() string {
 // FunctionLiteral for defining functions
 type FunctionLiteral struct {
 	Token      token.Token // The 'fn' token
+	Name       *Identifier // the name of a declared function, nil otherwise
 	Parameters []*Identifier
 	Body       *BlockStatement
+
+	// Locals are the names of the variables by slot, the parameters first,
+	// set by the resolver
+	Locals []string
 }
 
 func (fl *FunctionLiteral) expressionNode() {}
 
 // TokenLiteral returns the token fn
 func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+
+// Pos returns the position of the keyword fn
+func (fl *FunctionLiteral) Pos() token.Position { return fl.Token.Pos }
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 	params := []string{}
@@ -192,6 +240,9 @@ func (fl *FunctionLiteral) String() string {
 		params = append(params, p.String())
 	}
 	out.WriteString(fl.TokenLiteral())
+	if fl.Name != nil {
+		out.WriteString(" " + fl.Name.String())
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") ")
@@ -210,6 +261,10 @@ func (ce *CallExpression) expressionNode() {}
 
 // TokenLiteral for token (
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+
+// Pos returns the position of the function expression
+func (ce *CallExpression) Pos() token.Position { return ce.Function.Pos() }
+
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 	args := []string{}
@@ -233,7 +288,10 @@ func (sl *StringLiteral) expressionNode() {}
 
 // TokenLiteral for string literal
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
-func (sl *StringLiteral) String() string       { return sl.Token.Literal }
+
+// Pos returns the position of the string
+func (sl *StringLiteral) Pos() token.Position { return sl.Token.Pos }
+func (sl *StringLiteral) String() string      { return sl.Token.Literal }
 
 // ArrayLiteral for array type
 type ArrayLiteral struct {
@@ -245,6 +303,9 @@ func (al *ArrayLiteral) expressionNode() {}
 
 // TokenLiteral returns [
 func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+
+// Pos returns the position of the left bracket
+func (al *ArrayLiteral) Pos() token.Position { return al.Token.Pos }
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
 	elements := []string{}
@@ -268,6 +329,10 @@ func (ie *IndexExpression) expressionNode() {}
 
 // TokenLiteral returns [
 func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+
+// Pos returns the position of the left expression
+func (ie *IndexExpression) Pos() token.Position { return ie.Left.Pos() }
+
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -288,14 +353,29 @@ func (hl *HashLiteral) expressionNode() {}
 
 // TokenLiteral returns {
 func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+
+// Pos returns the position of the left brace
+func (hl *HashLiteral) Pos() token.Position { return hl.Token.Pos }
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, key := range sortedKeys(hl.Pairs) {
+		pairs = append(pairs, key.String()+":"+hl.Pairs[key].String())
 	}
 	out.WriteString("{")
 	out.WriteString(strings.Join(pairs, ", "))
 	out.WriteString("}")
 	return out.String()
 }
+
+// Comment for a line comment, it's not part of any statement
+type Comment struct {
+	Token token.Token // the token.COMMENT token
+}
+
+// TokenLiteral returns the comment text including the leading //
+func (c *Comment) TokenLiteral() string { return c.Token.Literal }
+
+// Pos returns the position of the leading //
+func (c *Comment) Pos() token.Position { return c.Token.Pos }
+func (c *Comment) String() string      { return c.Token.Literal }
@@ -1,6 +1,9 @@
 package ast
 
 import (
+	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/lycheng/monkey-go/token"
@@ -26,3 +29,357 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
+
+func TestJSONRoundTrip(t *testing.T) {
+	program := sampleProgram()
+	nodes := []Node{
+		program,
+		&Program{},
+		&Program{Statements: []Statement{}, Comments: []*Comment{}},
+		&Identifier{Token: tok(token.IDENT, "x", 0), Value: "x"},
+		&Comment{Token: tok(token.COMMENT, "//", 3)},
+	}
+	for _, node := range nodes {
+		data, err := EncodeJSON(node)
+		if err != nil {
+			t.Fatalf("EncodeJSON failed for %s: %s", node.String(), err)
+		}
+		decoded, err := DecodeJSON(data)
+		if err != nil {
+			t.Fatalf("DecodeJSON failed for %s: %s", data, err)
+		}
+		if !equalNodes(reflect.ValueOf(node), reflect.ValueOf(decoded)) {
+			t.Errorf("wrong node decoded from %s.\nexpected=%s\ngot=     %s", data, node.String(), decoded.String())
+		}
+		again, err := EncodeJSON(decoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(again) != string(data) {
+			t.Errorf("encodings differ.\nexpected=%s\ngot=     %s", data, again)
+		}
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	node := &LetStatement{Token: tok(token.LET, "let", 0), Name: &Identifier{Token: tok(token.IDENT, "x", 4), Value: "x"}}
+	data, err := EncodeJSON(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"name":{"node":"Identifier","token":{"literal":"x","pos":{"column":5,"line":1,"offset":4},"type":"IDENT"},"value":"x"},` +
+		`"node":"LetStatement","token":{"literal":"let","pos":{"column":1,"line":1,"offset":0},"type":"LET"},"value":null}`
+	if string(data) != expected {
+		t.Errorf("wrong JSON.\nexpected=%s\ngot=     %s", expected, data)
+	}
+}
+
+func TestDecodeJSONErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[]`, "ast: $: node is not an object"},
+		{`{"node": "Loop"}`, `ast: $.token: token is not an object`},
+		{`{"node": "Loop", "token": {"type": "IDENT", "literal": "x", "pos": {"offset": 0, "line": 1, "column": 1}}}`,
+			`ast: $: unknown node "Loop"`},
+		{`{"node": "Program", "statements": [{"node": "Comment", "token": {"type": "COMMENT", "literal": "//",
+			"pos": {"offset": 0, "line": 1, "column": 1}}}]}`, "ast: $.statements[0]: Comment is not a statement"},
+		{`{"node": "Program", "statements": {}}`, "ast: $.statements: not an array"},
+		{`{"node": "IntegerLiteral", "token": {"type": "INT", "literal": "1", "pos": {"offset": 0, "line": 1, "column": 1}},
+			"value": 1.5}`, "ast: $.value: not an integer: 1.5"},
+		{`{"node": "Identifier", "token": {"type": "IDENT", "literal": "x", "pos": {"offset": 0, "line": 1}}, "value": "x"}`,
+			"ast: $.token.pos.column: not a number"},
+		{`{"node": "Program"} {}`, "ast: unexpected data after the node"},
+	}
+	for _, tt := range tests {
+		_, err := DecodeJSON([]byte(tt.input))
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error for %s. expected=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestInspect(t *testing.T) {
+	expected := []string{
+		"Program let", "LetStatement let", "Identifier f", "FunctionLiteral fn", "Identifier n",
+		"BlockStatement {", "ReturnStatement return", "IfExpression if", "InfixExpression <",
+		"IntegerLiteral 9223372036854775807", "Identifier n", "BlockStatement {", "ReturnStatement return",
+		"ExpressionStatement [", "IndexExpression [", "ArrayLiteral [", "PrefixExpression -", "IntegerLiteral 1",
+		"Boolean false", "ArrayLiteral [", "CallExpression (", "Identifier f", "HashLiteral {",
+		"StringLiteral key", "Boolean true", "IntegerLiteral 0", "HashLiteral {", "Identifier name",
+		"StringLiteral ", "ExpressionStatement x", "FunctionStatement fn", "FunctionLiteral fn", "Identifier g",
+		"BlockStatement {", "Comment // comment",
+	}
+	testTrace(t, trace(sampleProgram(), nil), expected)
+
+	// the children of the nodes for which f returns false are skipped
+	skipped := trace(sampleProgram(), func(n Node) bool {
+		_, ok := n.(*FunctionLiteral)
+		return !ok
+	})
+	testTrace(t, skipped, append(append(expected[:4:4], expected[13:32]...), expected[34:]...))
+
+	// every node is followed by a call with nil once its children are
+	// visited
+	depth := 0
+	Inspect(sampleProgram(), func(n Node) bool {
+		if n == nil {
+			depth--
+		} else {
+			depth++
+		}
+		if depth < 0 {
+			t.Fatalf("more calls with nil than nodes")
+		}
+		return true
+	})
+	if depth != 0 {
+		t.Errorf("wrong number of calls with nil. %d nodes left", depth)
+	}
+}
+
+func TestModify(t *testing.T) {
+	program := sampleProgram()
+	modified := Modify(program, func(node Node) Node {
+		switch node := node.(type) {
+		case *Identifier:
+			name := strings.ToUpper(node.Value)
+			return &Identifier{Token: token.Token{Type: token.IDENT, Literal: name, Pos: node.Pos()}, Value: name}
+		case *IntegerLiteral:
+			return &StringLiteral{Token: token.Token{Type: token.STRING, Literal: node.Token.Literal, Pos: node.Pos()},
+				Value: node.Token.Literal}
+		case *BlockStatement:
+			if len(node.Statements) == 0 {
+				b := &Boolean{Token: tok(token.TRUE, "true", node.Pos().Offset+1), Value: true}
+				node.Statements = append(node.Statements, &ExpressionStatement{Token: b.Token, Expression: b})
+			}
+		}
+		return node
+	})
+	if modified != program {
+		t.Fatalf("wrong node returned. expected=%p, got=%p", program, modified)
+	}
+	testTrace(t, trace(modified, nil), []string{
+		"Program let", "LetStatement let", "Identifier F", "FunctionLiteral fn", "Identifier N",
+		"BlockStatement {", "ReturnStatement return", "IfExpression if", "InfixExpression <",
+		"StringLiteral 9223372036854775807", "Identifier N", "BlockStatement {", "ExpressionStatement true",
+		"Boolean true", "ReturnStatement return", "ExpressionStatement [", "IndexExpression [", "ArrayLiteral [",
+		"PrefixExpression -", "StringLiteral 1", "Boolean false", "ArrayLiteral [", "CallExpression (",
+		"Identifier F", "HashLiteral {", "StringLiteral key", "Boolean true", "StringLiteral 0", "HashLiteral {",
+		"Identifier NAME", "StringLiteral ", "ExpressionStatement x", "FunctionStatement fn", "FunctionLiteral fn",
+		"Identifier G", "BlockStatement {", "ExpressionStatement true", "Boolean true", "Comment // comment",
+	})
+
+	// optional children are removed by nil
+	ifExp := &IfExpression{
+		Token:       tok(token.IF, "if", 0),
+		Condition:   &Boolean{Token: tok(token.TRUE, "true", 4), Value: true},
+		Consequence: &BlockStatement{Token: tok(token.LBRACE, "{", 10), Statements: []Statement{}},
+		Alternative: &BlockStatement{Token: tok(token.LBRACE, "{", 20), Statements: []Statement{}},
+	}
+	Modify(ifExp, func(node Node) Node {
+		if b, ok := node.(*BlockStatement); ok && b.Pos().Offset == 20 {
+			return nil
+		}
+		return node
+	})
+	if ifExp.Alternative != nil || ifExp.Consequence == nil {
+		t.Errorf("wrong branches. consequence=%v, alternative=%v", ifExp.Consequence, ifExp.Alternative)
+	}
+
+	// statements replaced by nil are taken out of their lists
+	program = sampleProgram()
+	Modify(program, func(node Node) Node {
+		switch node.(type) {
+		case *ReturnStatement, *FunctionStatement:
+			return nil
+		}
+		return node
+	})
+	testTrace(t, trace(program, nil), []string{
+		"Program let", "LetStatement let", "Identifier f", "FunctionLiteral fn", "Identifier n",
+		"BlockStatement {", "ExpressionStatement [", "IndexExpression [", "ArrayLiteral [",
+		"PrefixExpression -", "IntegerLiteral 1", "Boolean false", "ArrayLiteral [", "CallExpression (",
+		"Identifier f", "HashLiteral {", "StringLiteral key", "Boolean true", "IntegerLiteral 0", "HashLiteral {",
+		"Identifier name", "StringLiteral ", "ExpressionStatement x", "Comment // comment",
+	})
+	if body := program.Statements[0].(*LetStatement).Value.(*FunctionLiteral).Body; len(body.Statements) != 0 {
+		t.Errorf("statements left in the body: %v", body.Statements)
+	}
+}
+
+func TestModifyPanics(t *testing.T) {
+	tests := []struct {
+		modifier ModifierFunc
+		expected string
+	}{
+		{func(node Node) Node {
+			if _, ok := node.(*BlockStatement); ok {
+				return &ExpressionStatement{}
+			}
+			return node
+		}, "ast.Modify: block replaced by *ast.ExpressionStatement"},
+		{func(node Node) Node {
+			if stmt, ok := node.(*ExpressionStatement); ok && stmt.Expression != nil {
+				return stmt.Expression
+			}
+			return node
+		}, "ast.Modify: statement replaced by *ast.IndexExpression"},
+		{func(node Node) Node {
+			if _, ok := node.(*Boolean); ok {
+				return &Comment{}
+			}
+			return node
+		}, "ast.Modify: expression replaced by *ast.Comment"},
+		{func(node Node) Node {
+			if s, ok := node.(*StringLiteral); ok && s.Value == "key" {
+				return nil
+			}
+			return node
+		}, "ast.Modify: hash literal key replaced by nil"},
+		{func(node Node) Node {
+			if _, ok := node.(*FunctionLiteral); ok {
+				return &Boolean{}
+			}
+			return node
+		}, "ast.Modify: function replaced by *ast.Boolean"},
+	}
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if r := recover(); r != tt.expected {
+					t.Errorf("wrong panic. expected=%q, got=%v", tt.expected, r)
+				}
+			}()
+			Modify(sampleProgram(), tt.modifier)
+		}()
+	}
+}
+
+// trace returns the types and token literals of the nodes inspected with f,
+// all of them for a nil f
+func trace(node Node, f func(Node) bool) []string {
+	var nodes []string
+	Inspect(node, func(n Node) bool {
+		if n == nil {
+			return false
+		}
+		nodes = append(nodes, nodeName(n)+" "+n.TokenLiteral())
+		return f == nil || f(n)
+	})
+	return nodes
+}
+
+func testTrace(t *testing.T, got, expected []string) {
+	t.Helper()
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong nodes.\nexpected=%q\ngot=     %q", expected, got)
+	}
+}
+
+// sampleProgram returns a program of every kind of node, with positions on
+// the first line which don't match the String() of the program
+func sampleProgram() *Program {
+	ident := func(name string, offset int) *Identifier {
+		return &Identifier{Token: tok(token.IDENT, name, offset), Value: name}
+	}
+	integer := func(value int64, offset int) *IntegerLiteral {
+		return &IntegerLiteral{Token: tok(token.INT, fmt.Sprint(value), offset), Value: value}
+	}
+	local := ident("n", 40)
+	local.Local, local.Depth, local.Slot = true, 1, 2
+	key := &StringLiteral{Token: tok(token.STRING, "key", 60), Value: "key"}
+	return &Program{
+		Statements: []Statement{
+			&LetStatement{Token: tok(token.LET, "let", 0), Name: ident("f", 4), Value: &FunctionLiteral{
+				Token:      tok(token.FUNCTION, "fn", 8),
+				Parameters: []*Identifier{ident("n", 11)},
+				Locals:     []string{"n", "m"},
+				Body: &BlockStatement{Token: tok(token.LBRACE, "{", 14), Statements: []Statement{
+					&ReturnStatement{Token: tok(token.RETURN, "return", 16), ReturnValue: &IfExpression{
+						Token: tok(token.IF, "if", 23),
+						Condition: &InfixExpression{Token: tok(token.LT, "<", 28), Operator: "<",
+							Left: integer(9223372036854775807, 26), Right: local},
+						Consequence: &BlockStatement{Token: tok(token.LBRACE, "{", 32), Statements: []Statement{}},
+					}},
+					&ReturnStatement{Token: tok(token.RETURN, "return", 36)},
+				}},
+			}},
+			&ExpressionStatement{Token: tok(token.LBRACKET, "[", 50), Expression: &IndexExpression{
+				Token: tok(token.LBRACKET, "[", 58),
+				Left: &ArrayLiteral{Token: tok(token.LBRACKET, "[", 50), Elements: []Expression{
+					&PrefixExpression{Token: tok(token.MINUS, "-", 51), Operator: "-", Right: integer(1, 52)},
+					&Boolean{Token: tok(token.FALSE, "false", 54), Value: false},
+					&ArrayLiteral{Token: tok(token.LBRACKET, "[", 56), Elements: []Expression{}},
+				}},
+				Index: &CallExpression{Token: tok(token.LPAREN, "(", 62), Function: ident("f", 61), Arguments: []Expression{
+					&HashLiteral{Token: tok(token.LBRACE, "{", 59), Pairs: map[Expression]Expression{
+						key:               &Boolean{Token: tok(token.TRUE, "true", 66), Value: true},
+						integer(0, 72):    &HashLiteral{Token: tok(token.LBRACE, "{", 75), Pairs: map[Expression]Expression{}},
+						ident("name", 80): &StringLiteral{Token: tok(token.STRING, "", 86)},
+					}},
+				}},
+			}},
+			&ExpressionStatement{Token: tok(token.IDENT, "x", 90)},
+			&FunctionStatement{Token: tok(token.FUNCTION, "fn", 92), Function: &FunctionLiteral{
+				Token:      tok(token.FUNCTION, "fn", 92),
+				Name:       ident("g", 95),
+				Parameters: []*Identifier{},
+				Body:       &BlockStatement{Token: tok(token.LBRACE, "{", 98), Statements: []Statement{}},
+			}},
+		},
+		Comments: []*Comment{{Token: tok(token.COMMENT, "// comment", 100)}},
+	}
+}
+
+// tok returns a token on the first line
+func tok(typ token.Type, literal string, offset int) token.Token {
+	return token.Token{Type: typ, Literal: literal, Pos: token.Position{Offset: offset, Line: 1, Column: offset + 1}}
+}
+
+// equalNodes compares the nodes field by field, the pairs of the hash
+// literals in source order since their keys are pointers
+func equalNodes(a, b reflect.Value) bool {
+	if a.Kind() != b.Kind() || a.Type() != b.Type() {
+		return false
+	}
+	switch a.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return equalNodes(a.Elem(), b.Elem())
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			if !equalNodes(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Slice:
+		if a.Len() != b.Len() || a.IsNil() != b.IsNil() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !equalNodes(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Map:
+		if a.Len() != b.Len() || a.IsNil() != b.IsNil() {
+			return false
+		}
+		ka := sortedKeys(a.Interface().(map[Expression]Expression))
+		kb := sortedKeys(b.Interface().(map[Expression]Expression))
+		for i := range ka {
+			ki, kj := reflect.ValueOf(ka[i]), reflect.ValueOf(kb[i])
+			if !equalNodes(ki, kj) || !equalNodes(a.MapIndex(ki), b.MapIndex(kj)) {
+				return false
+			}
+		}
+		return true
+	}
+	return a.Interface() == b.Interface()
+}
@@ -0,0 +1,459 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/lycheng/monkey-go/token"
+)
+
+// The JSON of a node is an object whose "node" member is the name of its Go
+// type, "token" its token, and the other members its fields named in lower
+// camel case, with the name of a function and the fields of the resolver
+// when they are set. A token is an object of its "type", "literal" and
+// "pos", the "offset", "line" and "column" of its position. The pairs of a
+// hash literal are an array of objects of their "key" and "value", in
+// source order. Nil nodes are null, as are nil lists, while empty lists
+// are [].
+//
+//	{"node": "Identifier", "token": {"type": "IDENT", "literal": "x",
+//	 "pos": {"offset": 4, "line": 1, "column": 5}}, "value": "x"}
+
+// EncodeJSON returns the JSON of node, which DecodeJSON decodes back
+func EncodeJSON(node Node) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(jsonOf(node)); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// DecodeJSON returns the node of the JSON written by EncodeJSON
+func DecodeJSON(data []byte) (Node, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("ast: unexpected data after the node")
+	}
+	d := &jsonDecoder{}
+	node := d.node(v, "$")
+	if d.err != nil {
+		return nil, d.err
+	}
+	return node, nil
+}
+
+type jsonObject = map[string]interface{}
+
+func jsonOf(node Node) interface{} {
+	var obj jsonObject
+	switch node := node.(type) {
+	case *Program:
+		if node == nil {
+			return nil
+		}
+		var comments []interface{}
+		if node.Comments != nil {
+			comments = make([]interface{}, len(node.Comments))
+			for i, c := range node.Comments {
+				comments[i] = jsonOf(c)
+			}
+		}
+		obj = jsonObject{"statements": jsonStatements(node.Statements), "comments": comments}
+	case *LetStatement:
+		obj = jsonObject{"token": jsonToken(node.Token), "name": jsonOf(node.Name), "value": jsonOf(node.Value)}
+	case *ReturnStatement:
+		obj = jsonObject{"token": jsonToken(node.Token), "returnValue": jsonOf(node.ReturnValue)}
+	case *ExpressionStatement:
+		obj = jsonObject{"token": jsonToken(node.Token), "expression": jsonOf(node.Expression)}
+	case *FunctionStatement:
+		obj = jsonObject{"token": jsonToken(node.Token), "function": jsonOf(node.Function)}
+	case *BlockStatement:
+		if node == nil {
+			return nil
+		}
+		obj = jsonObject{"token": jsonToken(node.Token), "statements": jsonStatements(node.Statements)}
+	case *Identifier:
+		if node == nil {
+			return nil
+		}
+		obj = jsonObject{"token": jsonToken(node.Token), "value": node.Value}
+		if node.Local || node.Depth != 0 || node.Slot != 0 {
+			obj["local"], obj["depth"], obj["slot"] = node.Local, node.Depth, node.Slot
+		}
+	case *IntegerLiteral:
+		obj = jsonObject{"token": jsonToken(node.Token), "value": node.Value}
+	case *StringLiteral:
+		obj = jsonObject{"token": jsonToken(node.Token), "value": node.Value}
+	case *Boolean:
+		obj = jsonObject{"token": jsonToken(node.Token), "value": node.Value}
+	case *PrefixExpression:
+		obj = jsonObject{"token": jsonToken(node.Token), "operator": node.Operator, "right": jsonOf(node.Right)}
+	case *InfixExpression:
+		obj = jsonObject{"token": jsonToken(node.Token), "left": jsonOf(node.Left),
+			"operator": node.Operator, "right": jsonOf(node.Right)}
+	case *IfExpression:
+		obj = jsonObject{"token": jsonToken(node.Token), "condition": jsonOf(node.Condition),
+			"consequence": jsonOf(node.Consequence), "alternative": jsonOf(node.Alternative)}
+	case *FunctionLiteral:
+		if node == nil {
+			return nil
+		}
+		var params []interface{}
+		if node.Parameters != nil {
+			params = make([]interface{}, len(node.Parameters))
+			for i, param := range node.Parameters {
+				params[i] = jsonOf(param)
+			}
+		}
+		obj = jsonObject{"token": jsonToken(node.Token), "parameters": params, "body": jsonOf(node.Body)}
+		if node.Name != nil {
+			obj["name"] = jsonOf(node.Name)
+		}
+		if node.Locals != nil {
+			obj["locals"] = node.Locals
+		}
+	case *CallExpression:
+		obj = jsonObject{"token": jsonToken(node.Token), "function": jsonOf(node.Function),
+			"arguments": jsonExpressions(node.Arguments)}
+	case *ArrayLiteral:
+		obj = jsonObject{"token": jsonToken(node.Token), "elements": jsonExpressions(node.Elements)}
+	case *IndexExpression:
+		obj = jsonObject{"token": jsonToken(node.Token), "left": jsonOf(node.Left), "index": jsonOf(node.Index)}
+	case *HashLiteral:
+		var pairs []interface{}
+		if node.Pairs != nil {
+			pairs = make([]interface{}, 0, len(node.Pairs))
+			for _, key := range sortedKeys(node.Pairs) {
+				pairs = append(pairs, jsonObject{"key": jsonOf(key), "value": jsonOf(node.Pairs[key])})
+			}
+		}
+		obj = jsonObject{"token": jsonToken(node.Token), "pairs": pairs}
+	case *Comment:
+		obj = jsonObject{"token": jsonToken(node.Token)}
+	default:
+		// nil statements and expressions
+		return nil
+	}
+	obj["node"] = nodeName(node)
+	return obj
+}
+
+// nodeName returns the name of the type of node, without the package
+func nodeName(node Node) string {
+	name := fmt.Sprintf("%T", node)
+	return name[len("*ast."):]
+}
+
+func jsonToken(tok token.Token) jsonObject {
+	return jsonObject{
+		"type":    tok.Type,
+		"literal": tok.Literal,
+		"pos":     jsonObject{"offset": tok.Pos.Offset, "line": tok.Pos.Line, "column": tok.Pos.Column},
+	}
+}
+
+func jsonStatements(stmts []Statement) []interface{} {
+	if stmts == nil {
+		return nil
+	}
+	list := make([]interface{}, len(stmts))
+	for i, stmt := range stmts {
+		list[i] = jsonOf(stmt)
+	}
+	return list
+}
+
+func jsonExpressions(exps []Expression) []interface{} {
+	if exps == nil {
+		return nil
+	}
+	list := make([]interface{}, len(exps))
+	for i, exp := range exps {
+		list[i] = jsonOf(exp)
+	}
+	return list
+}
+
+// sortedKeys returns the keys of the pairs of a hash literal in source
+// order, the keys at the same offset, as built ones can be, by String
+func sortedKeys(pairs map[Expression]Expression) []Expression {
+	keys := make([]Expression, 0, len(pairs))
+	for key := range pairs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		oi, oj := keys[i].Pos().Offset, keys[j].Pos().Offset
+		if oi != oj {
+			return oi < oj
+		}
+		return keys[i].String() < keys[j].String()
+	})
+	return keys
+}
+
+// jsonDecoder builds the nodes of decoded JSON values, the first error
+// stops the decoding, with the path of the value at fault
+type jsonDecoder struct {
+	err error
+}
+
+func (d *jsonDecoder) fail(path, format string, a ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf("ast: %s: %s", path, fmt.Sprintf(format, a...))
+	}
+}
+
+func (d *jsonDecoder) node(v interface{}, path string) Node {
+	if v == nil || d.err != nil {
+		return nil
+	}
+	obj, ok := v.(jsonObject)
+	if !ok {
+		d.fail(path, "node is not an object")
+		return nil
+	}
+	kind := d.string(obj, "node", path)
+	if kind == "Program" {
+		program := &Program{Statements: d.statements(obj["statements"], path+".statements")}
+		if comments, ok := d.list(obj["comments"], path+".comments"); ok {
+			program.Comments = make([]*Comment, len(comments))
+			for i, c := range comments {
+				cpath := fmt.Sprintf("%s.comments[%d]", path, i)
+				if comment, ok := d.node(c, cpath).(*Comment); ok {
+					program.Comments[i] = comment
+				} else {
+					d.fail(cpath, "not a Comment")
+				}
+			}
+		}
+		return program
+	}
+	tok := d.token(obj["token"], path+".token")
+	switch kind {
+	case "LetStatement":
+		return &LetStatement{Token: tok, Name: d.identifier(obj["name"], path+".name"),
+			Value: d.expression(obj["value"], path+".value")}
+	case "ReturnStatement":
+		return &ReturnStatement{Token: tok, ReturnValue: d.expression(obj["returnValue"], path+".returnValue")}
+	case "ExpressionStatement":
+		return &ExpressionStatement{Token: tok, Expression: d.expression(obj["expression"], path+".expression")}
+	case "FunctionStatement":
+		return &FunctionStatement{Token: tok, Function: d.function(obj["function"], path+".function")}
+	case "BlockStatement":
+		return &BlockStatement{Token: tok, Statements: d.statements(obj["statements"], path+".statements")}
+	case "Identifier":
+		ident := &Identifier{Token: tok, Value: d.string(obj, "value", path)}
+		if _, ok := obj["local"]; ok {
+			ident.Local = d.bool(obj, "local", path)
+			ident.Depth = int(d.int(obj, "depth", path))
+			ident.Slot = int(d.int(obj, "slot", path))
+		}
+		return ident
+	case "IntegerLiteral":
+		return &IntegerLiteral{Token: tok, Value: d.int(obj, "value", path)}
+	case "StringLiteral":
+		return &StringLiteral{Token: tok, Value: d.string(obj, "value", path)}
+	case "Boolean":
+		return &Boolean{Token: tok, Value: d.bool(obj, "value", path)}
+	case "PrefixExpression":
+		return &PrefixExpression{Token: tok, Operator: d.string(obj, "operator", path),
+			Right: d.expression(obj["right"], path+".right")}
+	case "InfixExpression":
+		return &InfixExpression{Token: tok, Left: d.expression(obj["left"], path+".left"),
+			Operator: d.string(obj, "operator", path), Right: d.expression(obj["right"], path+".right")}
+	case "IfExpression":
+		return &IfExpression{Token: tok, Condition: d.expression(obj["condition"], path+".condition"),
+			Consequence: d.block(obj["consequence"], path+".consequence"),
+			Alternative: d.block(obj["alternative"], path+".alternative")}
+	case "FunctionLiteral":
+		fn := &FunctionLiteral{Token: tok, Name: d.identifier(obj["name"], path+".name"),
+			Body: d.block(obj["body"], path+".body")}
+		if params, ok := d.list(obj["parameters"], path+".parameters"); ok {
+			fn.Parameters = make([]*Identifier, len(params))
+			for i, param := range params {
+				fn.Parameters[i] = d.identifier(param, fmt.Sprintf("%s.parameters[%d]", path, i))
+			}
+		}
+		if locals, ok := d.list(obj["locals"], path+".locals"); ok {
+			fn.Locals = make([]string, len(locals))
+			for i, local := range locals {
+				if s, ok := local.(string); ok {
+					fn.Locals[i] = s
+				} else {
+					d.fail(fmt.Sprintf("%s.locals[%d]", path, i), "not a string")
+				}
+			}
+		}
+		return fn
+	case "CallExpression":
+		return &CallExpression{Token: tok, Function: d.expression(obj["function"], path+".function"),
+			Arguments: d.expressions(obj["arguments"], path+".arguments")}
+	case "ArrayLiteral":
+		return &ArrayLiteral{Token: tok, Elements: d.expressions(obj["elements"], path+".elements")}
+	case "IndexExpression":
+		return &IndexExpression{Token: tok, Left: d.expression(obj["left"], path+".left"),
+			Index: d.expression(obj["index"], path+".index")}
+	case "HashLiteral":
+		hash := &HashLiteral{Token: tok}
+		if pairs, ok := d.list(obj["pairs"], path+".pairs"); ok {
+			hash.Pairs = make(map[Expression]Expression, len(pairs))
+			for i, pair := range pairs {
+				ppath := fmt.Sprintf("%s.pairs[%d]", path, i)
+				p, ok := pair.(jsonObject)
+				if !ok {
+					d.fail(ppath, "pair is not an object")
+					return nil
+				}
+				key := d.expression(p["key"], ppath+".key")
+				if key == nil {
+					d.fail(ppath, "pair without a key")
+					return nil
+				}
+				hash.Pairs[key] = d.expression(p["value"], ppath+".value")
+			}
+		}
+		return hash
+	case "Comment":
+		return &Comment{Token: tok}
+	}
+	d.fail(path, "unknown node %q", kind)
+	return nil
+}
+
+func (d *jsonDecoder) statement(v interface{}, path string) Statement {
+	node := d.node(v, path)
+	if stmt, ok := node.(Statement); ok || node == nil {
+		return stmt
+	}
+	d.fail(path, "%s is not a statement", nodeName(node))
+	return nil
+}
+
+func (d *jsonDecoder) expression(v interface{}, path string) Expression {
+	node := d.node(v, path)
+	if exp, ok := node.(Expression); ok || node == nil {
+		return exp
+	}
+	d.fail(path, "%s is not an expression", nodeName(node))
+	return nil
+}
+
+func (d *jsonDecoder) identifier(v interface{}, path string) *Identifier {
+	node := d.node(v, path)
+	if ident, ok := node.(*Identifier); ok || node == nil {
+		return ident
+	}
+	d.fail(path, "%s is not an Identifier", nodeName(node))
+	return nil
+}
+
+func (d *jsonDecoder) function(v interface{}, path string) *FunctionLiteral {
+	node := d.node(v, path)
+	if fn, ok := node.(*FunctionLiteral); ok || node == nil {
+		return fn
+	}
+	d.fail(path, "%s is not a FunctionLiteral", nodeName(node))
+	return nil
+}
+
+func (d *jsonDecoder) block(v interface{}, path string) *BlockStatement {
+	node := d.node(v, path)
+	if b, ok := node.(*BlockStatement); ok || node == nil {
+		return b
+	}
+	d.fail(path, "%s is not a BlockStatement", nodeName(node))
+	return nil
+}
+
+func (d *jsonDecoder) statements(v interface{}, path string) []Statement {
+	list, ok := d.list(v, path)
+	if !ok {
+		return nil
+	}
+	stmts := make([]Statement, len(list))
+	for i, el := range list {
+		stmts[i] = d.statement(el, fmt.Sprintf("%s[%d]", path, i))
+	}
+	return stmts
+}
+
+func (d *jsonDecoder) expressions(v interface{}, path string) []Expression {
+	list, ok := d.list(v, path)
+	if !ok {
+		return nil
+	}
+	exps := make([]Expression, len(list))
+	for i, el := range list {
+		exps[i] = d.expression(el, fmt.Sprintf("%s[%d]", path, i))
+	}
+	return exps
+}
+
+// list returns the elements of an array, false for null
+func (d *jsonDecoder) list(v interface{}, path string) ([]interface{}, bool) {
+	if v == nil {
+		return nil, false
+	}
+	list, ok := v.([]interface{})
+	if !ok {
+		d.fail(path, "not an array")
+	}
+	return list, ok
+}
+
+func (d *jsonDecoder) token(v interface{}, path string) token.Token {
+	obj, ok := v.(jsonObject)
+	if !ok {
+		d.fail(path, "token is not an object")
+		return token.Token{}
+	}
+	tok := token.Token{Type: token.Type(d.string(obj, "type", path)), Literal: d.string(obj, "literal", path)}
+	pos, ok := obj["pos"].(jsonObject)
+	if !ok {
+		d.fail(path+".pos", "position is not an object")
+		return tok
+	}
+	tok.Pos.Offset = int(d.int(pos, "offset", path+".pos"))
+	tok.Pos.Line = int(d.int(pos, "line", path+".pos"))
+	tok.Pos.Column = int(d.int(pos, "column", path+".pos"))
+	return tok
+}
+
+func (d *jsonDecoder) string(obj jsonObject, key, path string) string {
+	s, ok := obj[key].(string)
+	if !ok {
+		d.fail(path+"."+key, "not a string")
+	}
+	return s
+}
+
+func (d *jsonDecoder) bool(obj jsonObject, key, path string) bool {
+	b, ok := obj[key].(bool)
+	if !ok {
+		d.fail(path+"."+key, "not a boolean")
+	}
+	return b
+}
+
+func (d *jsonDecoder) int(obj jsonObject, key, path string) int64 {
+	n, ok := obj[key].(json.Number)
+	if !ok {
+		d.fail(path+"."+key, "not a number")
+		return 0
+	}
+	i, err := strconv.ParseInt(string(n), 10, 64)
+	if err != nil {
+		d.fail(path+"."+key, "not an integer: %s", n)
+	}
+	return i
+}
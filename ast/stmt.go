@@ -19,6 +19,9 @@ func (ls *LetStatement) statementNode() {}
 // TokenLiteral returns the let token literal value
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
 
+// Pos returns the position of the first token
+func (ls *LetStatement) Pos() token.Position { return ls.Token.Pos }
+
 // String returns let statement string value
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
@@ -45,6 +48,9 @@ func (rs *ReturnStatement) statementNode() {}
 // TokenLiteral returns the let token literal value
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
 
+// Pos returns the position of the first token
+func (rs *ReturnStatement) Pos() token.Position { return rs.Token.Pos }
+
 // String return expression string value
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
@@ -68,6 +74,9 @@ func (es *ExpressionStatement) statementNode() {}
 // TokenLiteral returns the first token of the expression
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
 
+// Pos returns the position of the first token
+func (es *ExpressionStatement) Pos() token.Position { return es.Token.Pos }
+
 // String returns expression string value
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
@@ -75,3 +84,21 @@ func (es *ExpressionStatement) String() string {
 	}
 	return ""
 }
+
+// FunctionStatement for the declaration fn name(params) { ... }, which binds
+// the name of the function in the enclosing scope
+type FunctionStatement struct {
+	Token    token.Token // the 'fn' token
+	Function *FunctionLiteral
+}
+
+func (fs *FunctionStatement) statementNode() {}
+
+// TokenLiteral returns the fn token literal value
+func (fs *FunctionStatement) TokenLiteral() string { return fs.Token.Literal }
+
+// Pos returns the position of the first token
+func (fs *FunctionStatement) Pos() token.Position { return fs.Token.Pos }
+
+// String returns the function declaration string value
+func (fs *FunctionStatement) String() string { return fs.Function.String() }
@@ -0,0 +1,279 @@
+package ast
+
+import "fmt"
+
+// A Visitor's Visit method is invoked for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children
+// of node with the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses an AST in depth-first order: it starts by calling
+// v.Visit(node); node must not be nil. If the visitor w returned by
+// v.Visit(node) is not nil, Walk is invoked recursively with visitor w for
+// each of the non-nil children of node, followed by a call of w.Visit(nil).
+// The pairs of hash literals are visited in source order, the key before
+// the value, and the comments of a program after its statements.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		for _, stmt := range n.Statements {
+			walk(v, stmt)
+		}
+		for _, c := range n.Comments {
+			walk(v, c)
+		}
+	case *LetStatement:
+		walk(v, n.Name)
+		walk(v, n.Value)
+	case *ReturnStatement:
+		walk(v, n.ReturnValue)
+	case *ExpressionStatement:
+		walk(v, n.Expression)
+	case *FunctionStatement:
+		walk(v, n.Function)
+	case *BlockStatement:
+		for _, stmt := range n.Statements {
+			walk(v, stmt)
+		}
+	case *PrefixExpression:
+		walk(v, n.Right)
+	case *InfixExpression:
+		walk(v, n.Left)
+		walk(v, n.Right)
+	case *IfExpression:
+		walk(v, n.Condition)
+		walk(v, n.Consequence)
+		walk(v, n.Alternative)
+	case *FunctionLiteral:
+		walk(v, n.Name)
+		for _, param := range n.Parameters {
+			walk(v, param)
+		}
+		walk(v, n.Body)
+	case *CallExpression:
+		walk(v, n.Function)
+		for _, arg := range n.Arguments {
+			walk(v, arg)
+		}
+	case *ArrayLiteral:
+		for _, el := range n.Elements {
+			walk(v, el)
+		}
+	case *IndexExpression:
+		walk(v, n.Left)
+		walk(v, n.Index)
+	case *HashLiteral:
+		for _, key := range sortedKeys(n.Pairs) {
+			walk(v, key)
+			walk(v, n.Pairs[key])
+		}
+	case *Identifier, *IntegerLiteral, *StringLiteral, *Boolean, *Comment:
+		// nothing to do
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+// walk walks node unless it is nil, as the optional children are
+func walk(v Visitor, node Node) {
+	if !isNil(node) {
+		Walk(v, node)
+	}
+}
+
+// isNil reports whether node is nil or a nil pointer to a node
+func isNil(node Node) bool {
+	switch n := node.(type) {
+	case nil:
+		return true
+	case *BlockStatement:
+		return n == nil
+	case *Identifier:
+		return n == nil
+	case *FunctionLiteral:
+		return n == nil
+	}
+	return false
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses an AST in depth-first order: it starts by calling
+// f(node); node must not be nil. If f returns true, Inspect invokes f
+// recursively for each of the non-nil children of node, followed by a
+// call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// ModifierFunc returns the node replacing node, node itself to keep it
+type ModifierFunc func(node Node) Node
+
+// Modify rewrites an AST bottom-up: the children of node are modified
+// first and replaced in place by the results, then Modify returns
+// modifier(node). The keys and values of hash literals are modified in
+// source order, the key before the value.
+//
+// A child may be replaced by a node of another type as long as its field
+// holds it: a statement by a statement, an expression by an expression,
+// but the names of let statements and functions, the parameters of
+// functions, the functions of function statements and the blocks of
+// functions and if expressions by nodes of their own types only.
+// Modify panics otherwise. A child replaced by nil is removed, which only
+// the statements of programs and blocks, taken out of their lists, and the
+// optional children allow, like the value of a return statement or the else
+// branch of an if expression; the keys of hash literals can't be.
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch n := node.(type) {
+	case *Program:
+		n.Statements = modifyStatements(n.Statements, modifier)
+	case *LetStatement:
+		n.Name = modifyIdentifier(n.Name, modifier)
+		n.Value = modifyExpression(n.Value, modifier)
+	case *ReturnStatement:
+		n.ReturnValue = modifyExpression(n.ReturnValue, modifier)
+	case *ExpressionStatement:
+		n.Expression = modifyExpression(n.Expression, modifier)
+	case *FunctionStatement:
+		n.Function = modifyFunction(n.Function, modifier)
+	case *BlockStatement:
+		n.Statements = modifyStatements(n.Statements, modifier)
+	case *PrefixExpression:
+		n.Right = modifyExpression(n.Right, modifier)
+	case *InfixExpression:
+		n.Left = modifyExpression(n.Left, modifier)
+		n.Right = modifyExpression(n.Right, modifier)
+	case *IfExpression:
+		n.Condition = modifyExpression(n.Condition, modifier)
+		n.Consequence = modifyBlock(n.Consequence, modifier)
+		n.Alternative = modifyBlock(n.Alternative, modifier)
+	case *FunctionLiteral:
+		n.Name = modifyIdentifier(n.Name, modifier)
+		for i, param := range n.Parameters {
+			n.Parameters[i] = modifyIdentifier(param, modifier)
+		}
+		n.Body = modifyBlock(n.Body, modifier)
+	case *CallExpression:
+		n.Function = modifyExpression(n.Function, modifier)
+		for i, arg := range n.Arguments {
+			n.Arguments[i] = modifyExpression(arg, modifier)
+		}
+	case *ArrayLiteral:
+		for i, el := range n.Elements {
+			n.Elements[i] = modifyExpression(el, modifier)
+		}
+	case *IndexExpression:
+		n.Left = modifyExpression(n.Left, modifier)
+		n.Index = modifyExpression(n.Index, modifier)
+	case *HashLiteral:
+		if n.Pairs != nil {
+			pairs := make(map[Expression]Expression, len(n.Pairs))
+			for _, key := range sortedKeys(n.Pairs) {
+				value := n.Pairs[key]
+				if key = modifyExpression(key, modifier); key == nil {
+					panic("ast.Modify: hash literal key replaced by nil")
+				}
+				pairs[key] = modifyExpression(value, modifier)
+			}
+			n.Pairs = pairs
+		}
+	}
+	return modifier(node)
+}
+
+// modifyStatements modifies the statements of a list in place, leaving out
+// the ones replaced by nil
+func modifyStatements(stmts []Statement, modifier ModifierFunc) []Statement {
+	kept := stmts[:0]
+	for _, stmt := range stmts {
+		if stmt = modifyStatement(stmt, modifier); !isNil(stmt) {
+			kept = append(kept, stmt)
+		}
+	}
+	return kept
+}
+
+func modifyStatement(stmt Statement, modifier ModifierFunc) Statement {
+	if isNil(stmt) {
+		return stmt
+	}
+	switch n := Modify(stmt, modifier).(type) {
+	case nil:
+		return nil
+	case Statement:
+		return n
+	default:
+		panic(fmt.Sprintf("ast.Modify: statement replaced by %T", n))
+	}
+}
+
+func modifyExpression(exp Expression, modifier ModifierFunc) Expression {
+	if isNil(exp) {
+		return exp
+	}
+	switch n := Modify(exp, modifier).(type) {
+	case nil:
+		return nil
+	case Expression:
+		return n
+	default:
+		panic(fmt.Sprintf("ast.Modify: expression replaced by %T", n))
+	}
+}
+
+func modifyIdentifier(ident *Identifier, modifier ModifierFunc) *Identifier {
+	if ident == nil {
+		return nil
+	}
+	switch n := Modify(ident, modifier).(type) {
+	case nil:
+		return nil
+	case *Identifier:
+		return n
+	default:
+		panic(fmt.Sprintf("ast.Modify: identifier replaced by %T", n))
+	}
+}
+
+func modifyBlock(b *BlockStatement, modifier ModifierFunc) *BlockStatement {
+	if b == nil {
+		return nil
+	}
+	switch n := Modify(b, modifier).(type) {
+	case nil:
+		return nil
+	case *BlockStatement:
+		return n
+	default:
+		panic(fmt.Sprintf("ast.Modify: block replaced by %T", n))
+	}
+}
+
+func modifyFunction(fn *FunctionLiteral, modifier ModifierFunc) *FunctionLiteral {
+	if fn == nil {
+		return nil
+	}
+	switch n := Modify(fn, modifier).(type) {
+	case nil:
+		return nil
+	case *FunctionLiteral:
+		return n
+	default:
+		panic(fmt.Sprintf("ast.Modify: function replaced by %T", n))
+	}
+}
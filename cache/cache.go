@@ -0,0 +1,65 @@
+// Package cache stores the parsed programs next to their sources, so they
+// are decoded instead of parsed again while their sources don't change.
+//
+// A cached program is encoded as:
+//
+//   - the magic "MKPC" and the version of the format, a uvarint;
+//   - the SHA-256 of the source the program was parsed from;
+//   - the constant pool, the number of strings then the length and the
+//     bytes of each string, the token types, literals, names and operators;
+//   - the number of statements and the statements, then the number of
+//     comments and their tokens;
+//   - the CRC-32 (Castagnoli) of the bytes before it, little endian.
+//
+// A node is its tag, a byte, its token, its other fields and its children,
+// nil nodes are the tag 0 alone. A token is the indexes in the constant pool
+// of its type and literal, the differences of its offset and line with the
+// ones of the previous token, varints, and its column, a uvarint. Integers
+// are varints and counts uvarints.
+//
+// The programs are cached as parsed, before they are optimized and resolved.
+package cache
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/lycheng/monkey-go/ast"
+)
+
+// Path returns the path of the cached program of the source file name
+func Path(name string) string {
+	return name + "c"
+}
+
+// Load returns the cached program of the source file name, whose content is
+// src. The error is ErrStale when the source changed since it was cached.
+func Load(name string, src []byte) (*ast.Program, error) {
+	data, err := os.ReadFile(Path(name))
+	if err != nil {
+		return nil, err
+	}
+	return Decode(data, src)
+}
+
+// Store caches program, parsed from src, the content of the source file
+// name. The cached program is replaced atomically, so concurrent runs never
+// read a partial one.
+func Store(name string, src []byte, program *ast.Program) error {
+	path := Path(name)
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(Encode(program, src))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
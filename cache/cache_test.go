@@ -0,0 +1,220 @@
+package cache
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/lycheng/monkey-go/ast"
+	"github.com/lycheng/monkey-go/lexer"
+	"github.com/lycheng/monkey-go/parser"
+)
+
+var sources = []string{
+	"",
+	"5",
+	"let x = 5; x",
+	"-a + !b * c / (d - e); a < b; a > b; a == b; a != b",
+	`let s = "hello" + " " + "world"; len(s)`,
+	"if (x < y) { x } else { y }; if (true) { return 1; }",
+	"let add = fn(a, b) { return a + b; }; add(1, add(2, 3))",
+	"fn() {}; fn(x) { fn(y) { x + y } }(1)(2)",
+	"[1, [2, 3], []][1][0]",
+	`{"one": 1, "two": [2], true: {}, 3: fn() { 3 }}["one"]`,
+	"let big = 9223372036854775807; -big",
+	"// a comment\nlet x = 1; // after x\n\n  // indented\nx",
+	"fn even(n) { if (n == 0) { true } else { odd(n - 1) } }; fn odd(n) { !even(n) } fn main() { fn(x) { x } }",
+	"let f = fn(n) {\n\tif (n == 0) {\n\t\t0\n\t} else {\n\t\tf(n - 1)\n\t}\n};\nf(10)\n",
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, src := range sources {
+		program := parse(t, src)
+		decoded, err := Decode(Encode(program, []byte(src)), []byte(src))
+		if err != nil {
+			t.Errorf("Decode failed for %q: %s", src, err)
+			continue
+		}
+		if !equalValues(reflect.ValueOf(program), reflect.ValueOf(decoded)) {
+			t.Errorf("wrong program for %q.\nexpected=%s\ngot=     %s", src, program.String(), decoded.String())
+		}
+	}
+}
+
+func TestEncodeDeterministic(t *testing.T) {
+	src := `{"a": 1, "b": 2, "c": 3, "d": 4, "e": 5}`
+	expected := Encode(parse(t, src), []byte(src))
+	for i := 0; i < 10; i++ {
+		if got := Encode(parse(t, src), []byte(src)); string(got) != string(expected) {
+			t.Fatalf("encodings of %q differ", src)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	src := []byte("let x = fn(a) { a * 2 }; x(21)")
+	data := Encode(parse(t, string(src)), src)
+	tests := []struct {
+		name     string
+		data     []byte
+		src      []byte
+		expected string
+	}{
+		{"stale", data, []byte("let x = 1"), ErrStale.Error()},
+		{"empty", nil, src, "not a cached program"},
+		{"magic", append([]byte("MKXX"), data[4:]...), src, "not a cached program"},
+		{"truncated", data[:len(data)-1], src, "checksum mismatch"},
+		{"corrupted", flip(data, len(data)/2), src, "checksum mismatch"},
+		{"version", withChecksum(append(append([]byte{}, magic...), append([]byte{Version + 1}, data[5:len(data)-4]...)...)),
+			src, "version 3, want 2"},
+		{"trailing", withChecksum(append(append([]byte{}, data[:len(data)-4]...), 0)), src, "1 trailing bytes"},
+		{"count", withChecksum(append(append([]byte{}, data[:5+32]...), 0xff, 0x7f)), src, "count 16383 out of range"},
+	}
+	for _, tt := range tests {
+		program, err := Decode(tt.data, tt.src)
+		if err == nil {
+			t.Errorf("%s: expected an error, got %s", tt.name, program.String())
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("%s: wrong error. expected=%q, got=%q", tt.name, tt.expected, err)
+		}
+	}
+}
+
+func TestLoadStore(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "main.mk")
+	src := []byte("let x = 2; x * 21")
+	if _, err := Load(name, src); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Load without a cached program returned %v", err)
+	}
+	program := parse(t, string(src))
+	if err := Store(name, src, program); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(name + "c"); err != nil {
+		t.Fatalf("no cached program next to the source: %s", err)
+	}
+	loaded, err := Load(name, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.String() != program.String() {
+		t.Errorf("wrong program. expected=%s, got=%s", program.String(), loaded.String())
+	}
+	if _, err := Load(name, []byte("let x = 3; x * 21")); err != ErrStale {
+		t.Errorf("Load of a changed source returned %v, want ErrStale", err)
+	}
+	entries, err := os.ReadDir(filepath.Dir(name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("temporary files left: %d entries", len(entries))
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	src := benchmarkSource()
+	b.SetBytes(int64(len(src)))
+	for i := 0; i < b.N; i++ {
+		parser.New(lexer.New(src)).ParseProgram()
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	src := benchmarkSource()
+	data := Encode(parser.New(lexer.New(src)).ParseProgram(), []byte(src))
+	b.SetBytes(int64(len(src)))
+	for i := 0; i < b.N; i++ {
+		if _, err := Decode(data, []byte(src)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkSource() string {
+	return strings.Repeat(sources[len(sources)-1]+sources[9]+";\n"+sources[6]+"\n", 200)
+}
+
+func parse(t *testing.T, src string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.ErrorList()) != 0 {
+		t.Fatalf("parse errors for %q: %v", src, p.Errors())
+	}
+	return program
+}
+
+// flip returns a copy of data with a bit of the byte i flipped
+func flip(data []byte, i int) []byte {
+	flipped := append([]byte{}, data...)
+	flipped[i] ^= 1
+	return flipped
+}
+
+// withChecksum returns body followed by its checksum
+func withChecksum(body []byte) []byte {
+	sum := make([]byte, 4)
+	binary.LittleEndian.PutUint32(sum, crc32.Checksum(body, crcTable))
+	return append(append([]byte{}, body...), sum...)
+}
+
+// equalValues compares the nodes field by field, the maps of the hash
+// literals by their pairs in source order since their keys are pointers
+func equalValues(a, b reflect.Value) bool {
+	if a.Kind() != b.Kind() || a.Type() != b.Type() {
+		return false
+	}
+	switch a.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return equalValues(a.Elem(), b.Elem())
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			if !equalValues(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Slice:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !equalValues(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Map:
+		if a.Len() != b.Len() {
+			return false
+		}
+		ka, kb := sortedKeys(a), sortedKeys(b)
+		for i := range ka {
+			if !equalValues(ka[i], kb[i]) || !equalValues(a.MapIndex(ka[i]), b.MapIndex(kb[i])) {
+				return false
+			}
+		}
+		return true
+	}
+	return a.Interface() == b.Interface()
+}
+
+func sortedKeys(m reflect.Value) []reflect.Value {
+	keys := m.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Interface().(ast.Node).Pos().Offset < keys[j].Interface().(ast.Node).Pos().Offset
+	})
+	return keys
+}
@@ -0,0 +1,486 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sort"
+
+	"github.com/lycheng/monkey-go/ast"
+	"github.com/lycheng/monkey-go/token"
+)
+
+// Version of the format, bumped whenever the encoding of the AST changes
+const Version = 2
+
+var magic = []byte("MKPC")
+
+// ErrStale is returned when a cached program isn't the one of the source
+var ErrStale = errors.New("cached program is stale")
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// node tags
+const (
+	tagNil byte = iota
+	tagLet
+	tagReturn
+	tagExpression
+	tagBlock
+	tagIdentifier
+	tagInteger
+	tagString
+	tagBoolean
+	tagPrefix
+	tagInfix
+	tagIf
+	tagFunction
+	tagCall
+	tagArray
+	tagIndex
+	tagHash
+	tagFunctionStatement
+)
+
+// Encode returns the encoding of program, parsed from src
+func Encode(program *ast.Program, src []byte) []byte {
+	e := &encoder{strings: make(map[string]uint64)}
+	e.uint(uint64(len(program.Statements)))
+	for _, stmt := range program.Statements {
+		e.node(stmt)
+	}
+	e.uint(uint64(len(program.Comments)))
+	for _, c := range program.Comments {
+		e.token(c.Token)
+	}
+
+	var out bytes.Buffer
+	out.Write(magic)
+	out.Write(appendUvarint(nil, Version))
+	sum := sha256.Sum256(src)
+	out.Write(sum[:])
+	out.Write(appendUvarint(nil, uint64(len(e.pool))))
+	for _, s := range e.pool {
+		out.Write(appendUvarint(nil, uint64(len(s))))
+		out.WriteString(s)
+	}
+	out.Write(e.buf)
+	var sum32 [4]byte
+	binary.LittleEndian.PutUint32(sum32[:], crc32.Checksum(out.Bytes(), crcTable))
+	out.Write(sum32[:])
+	return out.Bytes()
+}
+
+// Decode returns the program encoded in data, ErrStale when it wasn't
+// parsed from src
+func Decode(data []byte, src []byte) (*ast.Program, error) {
+	if len(data) < len(magic)+4 || !bytes.Equal(data[:len(magic)], magic) {
+		return nil, errors.New("not a cached program")
+	}
+	body := data[:len(data)-4]
+	if crc32.Checksum(body, crcTable) != binary.LittleEndian.Uint32(data[len(body):]) {
+		return nil, errors.New("cached program is corrupted: checksum mismatch")
+	}
+	d := &decoder{buf: body[len(magic):]}
+	if version := d.uint(); version != Version {
+		return nil, fmt.Errorf("cached program has version %d, want %d", version, Version)
+	}
+	sum := sha256.Sum256(src)
+	if !bytes.Equal(d.bytes(len(sum)), sum[:]) {
+		return nil, ErrStale
+	}
+	d.pool = make([]string, d.count())
+	for i := range d.pool {
+		d.pool[i] = string(d.bytes(d.count()))
+	}
+
+	program := &ast.Program{Statements: make([]ast.Statement, d.count())}
+	for i := range program.Statements {
+		program.Statements[i] = d.statement()
+	}
+	if n := d.count(); n != 0 {
+		program.Comments = make([]*ast.Comment, n)
+		for i := range program.Comments {
+			program.Comments[i] = &ast.Comment{Token: d.token()}
+		}
+	}
+	if d.err == nil && len(d.buf) != 0 {
+		d.fail("%d trailing bytes", len(d.buf))
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return program, nil
+}
+
+// encoder writes the nodes in prefix order: the tag, the token, the other
+// fields, then the children. The strings are indexes in the constant pool
+// and the positions are relative to the position of the previous token.
+type encoder struct {
+	buf     []byte
+	pool    []string
+	strings map[string]uint64
+	pos     token.Position
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+func appendVarint(b []byte, v int64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutVarint(buf[:], v)]...)
+}
+
+func (e *encoder) uint(v uint64) {
+	e.buf = appendUvarint(e.buf, v)
+}
+
+func (e *encoder) int(v int64) {
+	e.buf = appendVarint(e.buf, v)
+}
+
+func (e *encoder) string(s string) {
+	i, ok := e.strings[s]
+	if !ok {
+		i = uint64(len(e.pool))
+		e.strings[s] = i
+		e.pool = append(e.pool, s)
+	}
+	e.uint(i)
+}
+
+func (e *encoder) bool(b bool) {
+	if b {
+		e.buf = append(e.buf, 1)
+	} else {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *encoder) token(tok token.Token) {
+	e.string(string(tok.Type))
+	e.string(tok.Literal)
+	e.int(int64(tok.Pos.Offset - e.pos.Offset))
+	e.int(int64(tok.Pos.Line - e.pos.Line))
+	e.uint(uint64(tok.Pos.Column))
+	e.pos = tok.Pos
+}
+
+func (e *encoder) node(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		e.buf = append(e.buf, tagLet)
+		e.token(node.Token)
+		e.node(node.Name)
+		e.node(node.Value)
+	case *ast.ReturnStatement:
+		e.buf = append(e.buf, tagReturn)
+		e.token(node.Token)
+		e.node(node.ReturnValue)
+	case *ast.ExpressionStatement:
+		e.buf = append(e.buf, tagExpression)
+		e.token(node.Token)
+		e.node(node.Expression)
+	case *ast.FunctionStatement:
+		e.buf = append(e.buf, tagFunctionStatement)
+		e.token(node.Token)
+		e.node(node.Function)
+	case *ast.BlockStatement:
+		if node == nil {
+			e.buf = append(e.buf, tagNil)
+			return
+		}
+		e.buf = append(e.buf, tagBlock)
+		e.token(node.Token)
+		e.uint(uint64(len(node.Statements)))
+		for _, stmt := range node.Statements {
+			e.node(stmt)
+		}
+	case *ast.Identifier:
+		if node == nil {
+			e.buf = append(e.buf, tagNil)
+			return
+		}
+		e.buf = append(e.buf, tagIdentifier)
+		e.token(node.Token)
+		e.string(node.Value)
+	case *ast.IntegerLiteral:
+		e.buf = append(e.buf, tagInteger)
+		e.token(node.Token)
+		e.int(node.Value)
+	case *ast.StringLiteral:
+		e.buf = append(e.buf, tagString)
+		e.token(node.Token)
+		e.string(node.Value)
+	case *ast.Boolean:
+		e.buf = append(e.buf, tagBoolean)
+		e.token(node.Token)
+		e.bool(node.Value)
+	case *ast.PrefixExpression:
+		e.buf = append(e.buf, tagPrefix)
+		e.token(node.Token)
+		e.string(node.Operator)
+		e.node(node.Right)
+	case *ast.InfixExpression:
+		e.buf = append(e.buf, tagInfix)
+		e.token(node.Token)
+		e.string(node.Operator)
+		e.node(node.Left)
+		e.node(node.Right)
+	case *ast.IfExpression:
+		e.buf = append(e.buf, tagIf)
+		e.token(node.Token)
+		e.node(node.Condition)
+		e.node(node.Consequence)
+		e.node(node.Alternative)
+	case *ast.FunctionLiteral:
+		e.buf = append(e.buf, tagFunction)
+		e.token(node.Token)
+		e.node(node.Name)
+		e.uint(uint64(len(node.Parameters)))
+		for _, param := range node.Parameters {
+			e.node(param)
+		}
+		e.node(node.Body)
+	case *ast.CallExpression:
+		e.buf = append(e.buf, tagCall)
+		e.token(node.Token)
+		e.node(node.Function)
+		e.uint(uint64(len(node.Arguments)))
+		for _, arg := range node.Arguments {
+			e.node(arg)
+		}
+	case *ast.ArrayLiteral:
+		e.buf = append(e.buf, tagArray)
+		e.token(node.Token)
+		e.uint(uint64(len(node.Elements)))
+		for _, el := range node.Elements {
+			e.node(el)
+		}
+	case *ast.IndexExpression:
+		e.buf = append(e.buf, tagIndex)
+		e.token(node.Token)
+		e.node(node.Left)
+		e.node(node.Index)
+	case *ast.HashLiteral:
+		e.buf = append(e.buf, tagHash)
+		e.token(node.Token)
+		// the pairs in source order, so the encoding doesn't depend on
+		// the order of the map
+		keys := make([]ast.Expression, 0, len(node.Pairs))
+		for key := range node.Pairs {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].Pos().Offset < keys[j].Pos().Offset
+		})
+		e.uint(uint64(len(keys)))
+		for _, key := range keys {
+			e.node(key)
+			e.node(node.Pairs[key])
+		}
+	default:
+		// nil statements and expressions
+		e.buf = append(e.buf, tagNil)
+	}
+}
+
+// decoder reads the nodes written by the encoder, the first error stops
+// the decoding
+type decoder struct {
+	buf  []byte
+	pool []string
+	pos  token.Position
+	err  error
+}
+
+func (d *decoder) fail(format string, a ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf("cached program is invalid: "+format, a...)
+	}
+	d.buf = nil
+}
+
+func (d *decoder) uint() uint64 {
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.fail("bad unsigned integer")
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) int() int64 {
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.fail("bad integer")
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+// count reads a number of items, which can't be more than the bytes left
+func (d *decoder) count() int {
+	n := d.uint()
+	if n > uint64(len(d.buf)) {
+		d.fail("count %d out of range", n)
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) bytes(n int) []byte {
+	if n > len(d.buf) {
+		d.fail("unexpected end")
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) byte() byte {
+	if b := d.bytes(1); b != nil {
+		return b[0]
+	}
+	return tagNil
+}
+
+func (d *decoder) string() string {
+	i := d.uint()
+	if i >= uint64(len(d.pool)) {
+		d.fail("constant %d out of range", i)
+		return ""
+	}
+	return d.pool[i]
+}
+
+func (d *decoder) token() token.Token {
+	tok := token.Token{Type: token.Type(d.string()), Literal: d.string()}
+	tok.Pos.Offset = d.pos.Offset + int(d.int())
+	tok.Pos.Line = d.pos.Line + int(d.int())
+	tok.Pos.Column = int(d.uint())
+	d.pos = tok.Pos
+	return tok
+}
+
+func (d *decoder) statement() ast.Statement {
+	node := d.node()
+	if stmt, ok := node.(ast.Statement); ok || node == nil {
+		return stmt
+	}
+	d.fail("%T is not a statement", node)
+	return nil
+}
+
+func (d *decoder) expression() ast.Expression {
+	node := d.node()
+	if exp, ok := node.(ast.Expression); ok || node == nil {
+		return exp
+	}
+	d.fail("%T is not an expression", node)
+	return nil
+}
+
+// identifier reads an identifier, which may be nil
+func (d *decoder) identifier() *ast.Identifier {
+	exp := d.expression()
+	if ident, ok := exp.(*ast.Identifier); ok || exp == nil {
+		return ident
+	}
+	d.fail("%T is not an identifier", exp)
+	return nil
+}
+
+// block reads a block, which may be nil
+func (d *decoder) block() *ast.BlockStatement {
+	node := d.node()
+	if b, ok := node.(*ast.BlockStatement); ok || node == nil {
+		return b
+	}
+	d.fail("%T is not a block", node)
+	return nil
+}
+
+func (d *decoder) node() ast.Node {
+	tag := d.byte()
+	if tag == tagNil || d.err != nil {
+		return nil
+	}
+	tok := d.token()
+	switch tag {
+	case tagLet:
+		return &ast.LetStatement{Token: tok, Name: d.identifier(), Value: d.expression()}
+	case tagReturn:
+		return &ast.ReturnStatement{Token: tok, ReturnValue: d.expression()}
+	case tagExpression:
+		return &ast.ExpressionStatement{Token: tok, Expression: d.expression()}
+	case tagBlock:
+		b := &ast.BlockStatement{Token: tok, Statements: make([]ast.Statement, d.count())}
+		for i := range b.Statements {
+			b.Statements[i] = d.statement()
+		}
+		return b
+	case tagIdentifier:
+		return &ast.Identifier{Token: tok, Value: d.string()}
+	case tagInteger:
+		return &ast.IntegerLiteral{Token: tok, Value: d.int()}
+	case tagString:
+		return &ast.StringLiteral{Token: tok, Value: d.string()}
+	case tagBoolean:
+		return &ast.Boolean{Token: tok, Value: d.byte() != 0}
+	case tagPrefix:
+		return &ast.PrefixExpression{Token: tok, Operator: d.string(), Right: d.expression()}
+	case tagInfix:
+		return &ast.InfixExpression{Token: tok, Operator: d.string(), Left: d.expression(), Right: d.expression()}
+	case tagIf:
+		return &ast.IfExpression{Token: tok, Condition: d.expression(), Consequence: d.block(), Alternative: d.block()}
+	case tagFunctionStatement:
+		node := d.node()
+		if fn, ok := node.(*ast.FunctionLiteral); ok {
+			return &ast.FunctionStatement{Token: tok, Function: fn}
+		}
+		d.fail("%T is not a function", node)
+		return nil
+	case tagFunction:
+		fn := &ast.FunctionLiteral{Token: tok, Name: d.identifier()}
+		fn.Parameters = make([]*ast.Identifier, d.count())
+		for i := range fn.Parameters {
+			fn.Parameters[i] = d.identifier()
+		}
+		fn.Body = d.block()
+		return fn
+	case tagCall:
+		call := &ast.CallExpression{Token: tok, Function: d.expression()}
+		call.Arguments = make([]ast.Expression, d.count())
+		for i := range call.Arguments {
+			call.Arguments[i] = d.expression()
+		}
+		return call
+	case tagArray:
+		arr := &ast.ArrayLiteral{Token: tok, Elements: make([]ast.Expression, d.count())}
+		for i := range arr.Elements {
+			arr.Elements[i] = d.expression()
+		}
+		return arr
+	case tagIndex:
+		return &ast.IndexExpression{Token: tok, Left: d.expression(), Index: d.expression()}
+	case tagHash:
+		n := d.count()
+		hash := &ast.HashLiteral{Token: tok, Pairs: make(map[ast.Expression]ast.Expression, n)}
+		for i := 0; i < n; i++ {
+			key := d.expression()
+			hash.Pairs[key] = d.expression()
+		}
+		return hash
+	}
+	d.fail("unknown tag %d", tag)
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/lycheng/monkey-go/coverage"
+)
+
+func runCover(args []string) int {
+	flags := flag.NewFlagSet("cover", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: monkey cover profile\n\n")
+		fmt.Fprintf(flags.Output(), "Shows the sources of an LCOV coverage profile annotated with the\n")
+		fmt.Fprintf(flags.Output(), "hits of their lines and branches.\n")
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	files, err := coverage.ParseLCOV(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey cover: %s: %s\n", flags.Arg(0), err)
+		return 1
+	}
+
+	status := 0
+	for i, file := range files {
+		src, err := os.ReadFile(file.Path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		file.WriteText(os.Stdout, string(src))
+	}
+	return status
+}
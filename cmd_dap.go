@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/lycheng/monkey-go/dap"
+)
+
+func runDAP(args []string) int {
+	flags := flag.NewFlagSet("dap", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: monkey dap\n\n")
+		fmt.Fprintf(flags.Output(), "Serves the Debug Adapter Protocol over stdin and stdout.\n")
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	// the protocol owns stdout, what the program prints is sent to the
+	// client as output events
+	protocol := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey dap: %s\n", err)
+		return 1
+	}
+	os.Stdout = w
+	defer func() { os.Stdout = protocol }()
+
+	server := dap.NewServer(os.Stdin, protocol)
+	forwarded := make(chan struct{})
+	go func() {
+		defer close(forwarded)
+		buf := make([]byte, 4096)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				server.Output(string(buf[:n]))
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	err = server.Run()
+	w.Close()
+	<-forwarded
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey dap: %s\n", err)
+		return 1
+	}
+	return 0
+}
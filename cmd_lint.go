@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/lycheng/monkey-go/lexer"
+	"github.com/lycheng/monkey-go/lint"
+	"github.com/lycheng/monkey-go/parser"
+)
+
+func runLint(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	disable := flags.String("disable", "", "comma separated rule IDs to skip")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: monkey lint [-disable rules] [path ...]\n\n")
+		fmt.Fprintf(flags.Output(), "Without paths lint checks the standard input.\n\n")
+		flags.PrintDefaults()
+		fmt.Fprintf(flags.Output(), "\nRules:\n")
+		for _, rule := range lint.Rules {
+			fmt.Fprintf(flags.Output(), "  %-15s %s\n", rule.ID, rule.Description)
+		}
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	config := lint.Config{Disabled: make(map[string]bool)}
+	for _, rule := range strings.Split(*disable, ",") {
+		if rule = strings.TrimSpace(rule); rule != "" {
+			config.Disabled[rule] = true
+		}
+	}
+
+	if flags.NArg() == 0 {
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return lintSource("<stdin>", string(src), config)
+	}
+
+	files, err := sourceFiles(flags.Args(), isSourceFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	status := 0
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+		if lintSource(file, string(src), config) != 0 {
+			status = 1
+		}
+	}
+	return status
+}
+
+func lintSource(name, src string, config lint.Config) int {
+	l := lexer.New(src)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.ErrorList()) != 0 {
+		for _, err := range p.ErrorList() {
+			fmt.Printf("%s:%s (syntax)\n", name, err)
+		}
+		return 1
+	}
+	issues := lint.Check(program, config)
+	for _, issue := range issues {
+		fmt.Printf("%s:%s\n", name, issue)
+	}
+	if len(issues) != 0 {
+		return 1
+	}
+	return 0
+}
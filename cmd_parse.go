@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/lycheng/monkey-go/ast"
+	"github.com/lycheng/monkey-go/lexer"
+	"github.com/lycheng/monkey-go/parser"
+)
+
+func runParse(args []string) int {
+	flags := flag.NewFlagSet("parse", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print the AST as JSON, with the tokens and their positions")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: monkey parse [-json] [file]\n\n")
+		fmt.Fprintf(flags.Output(), "Without a file parse reads the standard input. Without -json the program\n")
+		fmt.Fprintf(flags.Output(), "is printed with its expressions parenthesized.\n\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+
+	name := "<stdin>"
+	var src []byte
+	var err error
+	if flags.NArg() == 0 {
+		src, err = io.ReadAll(os.Stdin)
+	} else {
+		name = flags.Arg(0)
+		src, err = os.ReadFile(name)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if errs := p.ErrorList(); len(errs) != 0 {
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "%s:%s\n", name, err)
+		}
+		return 1
+	}
+
+	if !*asJSON {
+		for _, stmt := range program.Statements {
+			fmt.Println(stmt.String())
+		}
+		return 0
+	}
+	out, err := ast.EncodeJSON(program)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey parse: %s\n", err)
+		return 1
+	}
+	fmt.Printf("%s\n", out)
+	return 0
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/lycheng/monkey-go/ast"
+	"github.com/lycheng/monkey-go/cache"
+	"github.com/lycheng/monkey-go/coverage"
+	"github.com/lycheng/monkey-go/evaluator"
+	"github.com/lycheng/monkey-go/lexer"
+	"github.com/lycheng/monkey-go/object"
+	"github.com/lycheng/monkey-go/optimize"
+	"github.com/lycheng/monkey-go/parser"
+	"github.com/lycheng/monkey-go/profile"
+)
+
+func runRun(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	profileFile := flags.String("profile", "", "write a profile of the functions to `file`")
+	profileFormat := flags.String("profile-format", "folded", "profile format, folded stacks for flame graphs or text")
+	coverProfile := flags.String("coverprofile", "", "write an LCOV coverage profile to `file`")
+	allowFS := flags.String("allow-fs", "", "comma separated `dirs` whose files the program may access")
+	readOnly := flags.Bool("read-only", false, "deny writing to the files of -allow-fs")
+	allowEnv := flags.String("allow-env", "", "comma separated environment `variables` the program may read, * for all")
+	allowExec := flags.String("allow-exec", "", "comma separated `commands` the program may execute")
+	optimized := flags.Bool("optimize", false, "fold constants, prune decided branches and inline constant bindings before running")
+	cached := flags.Bool("cache", true, "reuse the parsed program cached next to the file while the file doesn't change")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: monkey run [flags] file\n\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	if *profileFormat != "folded" && *profileFormat != "text" {
+		fmt.Fprintf(os.Stderr, "monkey run: unknown profile format %q\n", *profileFormat)
+		return 2
+	}
+	if *optimized && *coverProfile != "" {
+		fmt.Fprintln(os.Stderr, "monkey run: -optimize drops code, it can't be used with -coverprofile")
+		return 2
+	}
+
+	name := flags.Arg(0)
+	src, err := os.ReadFile(name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	parsed, errs := parseFile(name, src, *cached)
+	if len(errs) != 0 {
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "%s:%s\n", name, err)
+		}
+		return 1
+	}
+	if *optimized {
+		optimize.Program(parsed)
+	}
+	program := evaluator.Prepare(parsed)
+
+	e := evaluator.New()
+	e.Policy = &evaluator.Policy{
+		Roots:    splitList(*allowFS),
+		ReadOnly: *readOnly,
+		Env:      splitList(*allowEnv),
+		Commands: splitList(*allowExec),
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	e.Context = ctx
+	var profiler *profile.Profiler
+	if *profileFile != "" {
+		profiler = profile.New()
+		e.Tracer = profiler
+	}
+	var recorder *coverage.Recorder
+	if *coverProfile != "" {
+		recorder = coverage.NewRecorder(name, program.AST())
+		e.Coverage = recorder
+	}
+	result := program.RunWith(e, object.NewEnvironment())
+
+	status := 0
+	if err, ok := result.(*object.Error); ok {
+		fmt.Fprintf(os.Stderr, "%s:%s: %s\n", name, err.Pos, err.Message)
+		status = 1
+	}
+	if profiler != nil {
+		if err := writeProfile(profiler, *profileFile, *profileFormat); err != nil {
+			fmt.Fprintf(os.Stderr, "monkey run: %s\n", err)
+			status = 1
+		}
+	}
+	if recorder != nil {
+		if err := writeCoverProfile(*coverProfile, []*coverage.File{recorder.File()}); err != nil {
+			fmt.Fprintf(os.Stderr, "monkey run: %s\n", err)
+			status = 1
+		}
+	}
+	return status
+}
+
+// parseFile parses src, the content of the file name. With cached, the
+// program cached next to the file is reused while src doesn't change, and
+// the cache is written when it is missing or stale. Failing to write it
+// doesn't fail the run.
+func parseFile(name string, src []byte, cached bool) (*ast.Program, []*parser.Error) {
+	if cached {
+		if program, err := cache.Load(name, src); err == nil {
+			return program, nil
+		}
+	}
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if errs := p.ErrorList(); len(errs) != 0 {
+		return nil, errs
+	}
+	if cached {
+		cache.Store(name, src, program)
+	}
+	return program, nil
+}
+
+func writeProfile(profiler *profile.Profiler, name, format string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if format == "text" {
+		err = profiler.WriteText(f)
+	} else {
+		err = profiler.WriteFolded(f)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func writeCoverProfile(name string, files []*coverage.File) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	err = coverage.WriteLCOV(f, files)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// splitList returns the elements of a comma separated list
+func splitList(list string) []string {
+	var elements []string
+	for _, el := range strings.Split(list, ",") {
+		if el = strings.TrimSpace(el); el != "" {
+			elements = append(elements, el)
+		}
+	}
+	return elements
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/lycheng/monkey-go/testrunner"
+)
+
+func runTest(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	run := flags.String("run", "", "run only the tests whose name matches the `regexp`")
+	verbose := flags.Bool("v", false, "report every test, not only the failed ones")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: monkey test [-run regexp] [-v] [path ...]\n\n")
+		fmt.Fprintf(flags.Output(), "Runs the test_ functions of the %s files found in the paths,\n", testrunner.FileSuffix)
+		fmt.Fprintf(flags.Output(), "the current directory by default.\n\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	var match func(string) bool
+	if *run != "" {
+		re, err := regexp.Compile(*run)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "monkey test: invalid -run: %s\n", err)
+			return 2
+		}
+		match = re.MatchString
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := sourceFiles(paths, isTestFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	status := 0
+	for _, file := range files {
+		if !testFile(file, match, *verbose) {
+			status = 1
+		}
+	}
+	return status
+}
+
+func isTestFile(name string) bool {
+	return strings.HasSuffix(name, testrunner.FileSuffix)
+}
+
+// testFile runs the tests of a file and reports whether they all passed
+func testFile(name string, match func(string) bool, verbose bool) bool {
+	start := time.Now()
+	src, err := os.ReadFile(name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return false
+	}
+	results, errs := testrunner.Run(string(src), match)
+	if len(errs) != 0 {
+		for _, err := range errs {
+			fmt.Printf("%s:%s\n", name, err)
+		}
+		fmt.Printf("FAIL\t%s [syntax error]\n", name)
+		return false
+	}
+
+	passed := true
+	for _, r := range results {
+		if verbose {
+			fmt.Printf("=== RUN   %s\n", r.Name)
+		}
+		if r.Passed() {
+			if verbose {
+				fmt.Printf("--- PASS: %s (%.3fs)\n", r.Name, r.Elapsed.Seconds())
+			}
+			continue
+		}
+		passed = false
+		fmt.Printf("--- FAIL: %s (%.3fs)\n", r.Name, r.Elapsed.Seconds())
+		message := strings.ReplaceAll(r.Err.Message, "\n", "\n        ")
+		fmt.Printf("    %s:%s: %s\n", name, r.Err.Pos, message)
+	}
+
+	elapsed := time.Since(start).Seconds()
+	switch {
+	case !passed:
+		fmt.Printf("FAIL\t%s\t%.3fs\n", name, elapsed)
+	case len(results) == 0:
+		fmt.Printf("ok  \t%s\t%.3fs [no tests to run]\n", name, elapsed)
+	default:
+		fmt.Printf("ok  \t%s\t%.3fs\n", name, elapsed)
+	}
+	return passed
+}
@@ -0,0 +1,136 @@
+// Package coverage records the statements and branches of a Monkey program
+// reached by its evaluations. The coverage is keyed by source position,
+// stored in LCOV tracefiles and shown as annotated source.
+package coverage
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/lycheng/monkey-go/ast"
+	"github.com/lycheng/monkey-go/token"
+)
+
+// Recorder is an evaluator.Coverage counting the statements and branches
+// of a program, it may observe several evaluations at once
+type Recorder struct {
+	path string
+
+	mu       sync.Mutex
+	stmts    map[token.Position]int     // hits by statement position
+	branches map[token.Position]*[2]int // consequence and alternative hits by if position
+}
+
+// NewRecorder returns a recorder for the program parsed from the file path,
+// all its statements and branches start unreached
+func NewRecorder(path string, program *ast.Program) *Recorder {
+	r := &Recorder{
+		path:     path,
+		stmts:    make(map[token.Position]int),
+		branches: make(map[token.Position]*[2]int),
+	}
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.LetStatement, *ast.ReturnStatement, *ast.ExpressionStatement, *ast.FunctionStatement:
+			r.stmts[node.Pos()] = 0
+		case *ast.IfExpression:
+			r.branches[node.Pos()] = &[2]int{}
+		}
+		return true
+	})
+	return r
+}
+
+// Statement counts a statement reached
+func (r *Recorder) Statement(stmt ast.Statement) {
+	r.mu.Lock()
+	r.stmts[stmt.Pos()]++
+	r.mu.Unlock()
+}
+
+// Branch counts a branch taken
+func (r *Recorder) Branch(ie *ast.IfExpression, consequence bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	counts, ok := r.branches[ie.Pos()]
+	if !ok {
+		counts = &[2]int{}
+		r.branches[ie.Pos()] = counts
+	}
+	if consequence {
+		counts[0]++
+	} else {
+		counts[1]++
+	}
+}
+
+// File returns the coverage recorded so far, a line counts the hits of its
+// most reached statement
+func (r *Recorder) File() *File {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f := &File{Path: r.path, Lines: make(map[int]int)}
+	for pos, hits := range r.stmts {
+		if hits >= f.Lines[pos.Line] {
+			f.Lines[pos.Line] = hits
+		}
+	}
+
+	ifs := make([]token.Position, 0, len(r.branches))
+	for pos := range r.branches {
+		ifs = append(ifs, pos)
+	}
+	sort.Slice(ifs, func(i, j int) bool { return ifs[i].Offset < ifs[j].Offset })
+	for block, pos := range ifs {
+		counts := r.branches[pos]
+		for branch, hits := range counts {
+			if counts[0]+counts[1] == 0 {
+				hits = -1
+			}
+			f.Branches = append(f.Branches, Branch{
+				Line:   pos.Line,
+				Block:  block,
+				Branch: branch,
+				Taken:  hits,
+			})
+		}
+	}
+	return f
+}
+
+// File is the coverage of a source file
+type File struct {
+	Path     string
+	Lines    map[int]int // hits by line starting a statement
+	Branches []Branch    // in source order
+}
+
+// Branch is the coverage of a branch of an if expression
+type Branch struct {
+	Line   int // line of the if keyword
+	Block  int // index of the if expression in the file
+	Branch int // 0 for the consequence, 1 for the alternative
+	Taken  int // -1 when the condition was never evaluated
+}
+
+// LineCoverage returns the number of lines reached and the number of lines
+// starting a statement
+func (f *File) LineCoverage() (hit, total int) {
+	for _, hits := range f.Lines {
+		if hits > 0 {
+			hit++
+		}
+	}
+	return hit, len(f.Lines)
+}
+
+// BranchCoverage returns the number of branches taken and the number of
+// branches
+func (f *File) BranchCoverage() (hit, total int) {
+	for _, b := range f.Branches {
+		if b.Taken > 0 {
+			hit++
+		}
+	}
+	return hit, len(f.Branches)
+}
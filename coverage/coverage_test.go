@@ -0,0 +1,112 @@
+package coverage
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/lycheng/monkey-go/evaluator"
+	"github.com/lycheng/monkey-go/lexer"
+	"github.com/lycheng/monkey-go/object"
+	"github.com/lycheng/monkey-go/parser"
+)
+
+const input = `let abs = fn(x) {
+  if (x < 0) {
+    return -x;
+  }
+  x
+};
+let sign = fn(x) { if (x > 0) { 1 } else { 0 } };
+abs(3) + abs(4);
+if (false) { sign(1) }
+`
+
+func record(t *testing.T) *File {
+	program := parser.New(lexer.New(input)).ParseProgram()
+	r := NewRecorder("test.mk", program)
+	e := evaluator.New()
+	e.Coverage = r
+	if result := e.Eval(program, object.NewEnvironment()); result.Inspect() != "null" {
+		t.Fatalf("wrong result %s", result.Inspect())
+	}
+	return r.File()
+}
+
+const lcov = `TN:
+SF:test.mk
+BRDA:2,0,0,0
+BRDA:2,0,1,2
+BRDA:7,1,0,-
+BRDA:7,1,1,-
+BRDA:9,2,0,0
+BRDA:9,2,1,1
+BRF:6
+BRH:2
+DA:1,1
+DA:2,2
+DA:3,0
+DA:5,2
+DA:7,1
+DA:8,1
+DA:9,1
+LF:7
+LH:6
+end_of_record
+`
+
+func TestWriteLCOV(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteLCOV(&out, []*File{record(t)}); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != lcov {
+		t.Errorf("wrong tracefile.\nexpected=%q\ngot=%q", lcov, out.String())
+	}
+}
+
+func TestParseLCOV(t *testing.T) {
+	files, err := ParseLCOV(strings.NewReader(lcov))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("expected 1 file, got %d", len(files))
+	}
+	var out bytes.Buffer
+	WriteLCOV(&out, files)
+	if out.String() != lcov {
+		t.Errorf("tracefile not preserved.\nexpected=%q\ngot=%q", lcov, out.String())
+	}
+
+	if _, err := ParseLCOV(strings.NewReader("SF:a.mk\nDA:x,1\n")); err == nil {
+		t.Errorf("expected an error for an invalid DA record")
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	var out bytes.Buffer
+	if err := record(t).WriteText(&out, input); err != nil {
+		t.Fatal(err)
+	}
+	expected := `test.mk: 85.7% of lines, 33.3% of branches
+        1:    1:let abs = fn(x) {
+        2:    2:  if (x < 0) {
+branch  0 consequence taken 0
+branch  0 alternative taken 2
+    #####:    3:    return -x;
+        -:    4:  }
+        2:    5:  x
+        -:    6:};
+        1:    7:let sign = fn(x) { if (x > 0) { 1 } else { 0 } };
+branch  1 consequence never executed
+branch  1 alternative never executed
+        1:    8:abs(3) + abs(4);
+        1:    9:if (false) { sign(1) }
+branch  2 consequence taken 0
+branch  2 alternative taken 1
+`
+	if out.String() != expected {
+		t.Errorf("wrong report.\nexpected=%s\ngot=%s", expected, out.String())
+	}
+}
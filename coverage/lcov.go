@@ -0,0 +1,98 @@
+package coverage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WriteLCOV writes the coverage of the files as an LCOV tracefile
+func WriteLCOV(w io.Writer, files []*File) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range files {
+		fmt.Fprintf(bw, "TN:\nSF:%s\n", f.Path)
+		for _, b := range f.Branches {
+			taken := "-"
+			if b.Taken >= 0 {
+				taken = strconv.Itoa(b.Taken)
+			}
+			fmt.Fprintf(bw, "BRDA:%d,%d,%d,%s\n", b.Line, b.Block, b.Branch, taken)
+		}
+		hit, total := f.BranchCoverage()
+		fmt.Fprintf(bw, "BRF:%d\nBRH:%d\n", total, hit)
+
+		lines := make([]int, 0, len(f.Lines))
+		for line := range f.Lines {
+			lines = append(lines, line)
+		}
+		sort.Ints(lines)
+		for _, line := range lines {
+			fmt.Fprintf(bw, "DA:%d,%d\n", line, f.Lines[line])
+		}
+		hit, total = f.LineCoverage()
+		fmt.Fprintf(bw, "LF:%d\nLH:%d\nend_of_record\n", total, hit)
+	}
+	return bw.Flush()
+}
+
+// ParseLCOV reads the line and branch coverage of an LCOV tracefile, the
+// other records are skipped
+func ParseLCOV(r io.Reader) ([]*File, error) {
+	var files []*File
+	var f *File
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		kind, value, _ := cut(line, ":")
+		if kind == "SF" {
+			f = &File{Path: value, Lines: make(map[int]int)}
+			files = append(files, f)
+			continue
+		}
+		if line == "end_of_record" {
+			f = nil
+			continue
+		}
+		if f == nil || (kind != "DA" && kind != "BRDA") {
+			continue
+		}
+
+		fields := strings.Split(value, ",")
+		nums := make([]int, len(fields))
+		for i, field := range fields {
+			if field == "-" && kind == "BRDA" && i == 3 {
+				nums[i] = -1
+				continue
+			}
+			num, err := strconv.Atoi(field)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid %s record %q", n, kind, value)
+			}
+			nums[i] = num
+		}
+		switch {
+		case kind == "DA" && len(nums) >= 2:
+			f.Lines[nums[0]] += nums[1]
+		case kind == "BRDA" && len(nums) == 4:
+			f.Branches = append(f.Branches, Branch{
+				Line:   nums[0],
+				Block:  nums[1],
+				Branch: nums[2],
+				Taken:  nums[3],
+			})
+		default:
+			return nil, fmt.Errorf("line %d: invalid %s record %q", n, kind, value)
+		}
+	}
+	return files, scanner.Err()
+}
+
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
@@ -0,0 +1,58 @@
+package coverage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// branchNames of the branches of an if expression
+var branchNames = [2]string{"consequence", "alternative"}
+
+// WriteText writes src annotated with the coverage in the style of gcov.
+// Every line is prefixed with the hits of its statements, "#####" for
+// statements never reached and "-" for lines without statements, and
+// followed by the branches of the if expressions starting on it.
+func (f *File) WriteText(w io.Writer, src string) error {
+	bw := bufio.NewWriter(w)
+	lineHit, lineTotal := f.LineCoverage()
+	branchHit, branchTotal := f.BranchCoverage()
+	fmt.Fprintf(bw, "%s: %s of lines, %s of branches\n", f.Path,
+		percent(lineHit, lineTotal), percent(branchHit, branchTotal))
+
+	branches := make(map[int][]Branch)
+	for _, b := range f.Branches {
+		branches[b.Line] = append(branches[b.Line], b)
+	}
+	for i, text := range strings.Split(strings.TrimSuffix(src, "\n"), "\n") {
+		line := i + 1
+		count := "-"
+		if hits, ok := f.Lines[line]; ok {
+			count = "#####"
+			if hits > 0 {
+				count = fmt.Sprint(hits)
+			}
+		}
+		fmt.Fprintf(bw, "%9s:%5d:%s\n", count, line, text)
+		for _, b := range branches[line] {
+			name := fmt.Sprint(b.Branch)
+			if b.Branch < len(branchNames) {
+				name = branchNames[b.Branch]
+			}
+			if b.Taken < 0 {
+				fmt.Fprintf(bw, "branch %2d %s never executed\n", b.Block, name)
+			} else {
+				fmt.Fprintf(bw, "branch %2d %s taken %d\n", b.Block, name, b.Taken)
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+func percent(hit, total int) string {
+	if total == 0 {
+		return "100.0%"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(hit)/float64(total))
+}
@@ -0,0 +1,189 @@
+package dap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lycheng/monkey-go/evaluator"
+	"github.com/lycheng/monkey-go/lexer"
+	"github.com/lycheng/monkey-go/object"
+	"github.com/lycheng/monkey-go/parser"
+)
+
+func (s *Server) threads(args json.RawMessage) (interface{}, error) {
+	return map[string]interface{}{
+		"threads": []Thread{{ID: threadID, Name: "main"}},
+	}, nil
+}
+
+// Frame IDs are the positions on the call stack counted from one, the
+// program frame has ID 1
+
+func (s *Server) stackTrace(args json.RawMessage) (interface{}, error) {
+	p, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	frames := []StackFrame{}
+	for i := len(p.frames) - 1; i >= 0; i-- {
+		frame := p.frames[i]
+		source := s.source
+		frames = append(frames, StackFrame{
+			ID:     i + 1,
+			Name:   frame.Name(),
+			Source: &source,
+			Line:   frame.Pos.Line,
+			Column: frame.Pos.Column,
+		})
+	}
+	return map[string]interface{}{
+		"stackFrames": frames,
+		"totalFrames": len(frames),
+	}, nil
+}
+
+// frame returns the frame by ID, the innermost one for ID 0
+func (p *pause) frame(id int) (*evaluator.Frame, error) {
+	if id == 0 {
+		return p.frames[len(p.frames)-1], nil
+	}
+	if id < 0 || id > len(p.frames) {
+		return nil, fmt.Errorf("unknown frame %d", id)
+	}
+	return p.frames[id-1], nil
+}
+
+func (s *Server) scopes(args json.RawMessage) (interface{}, error) {
+	var a scopesArguments
+	if err := unmarshalArgs(args, &a); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.paused == nil {
+		return nil, errors.New("the program is not paused")
+	}
+	frame, err := s.paused.frame(a.FrameID)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := []Scope{}
+	for env := frame.Env; env != nil; env = env.Outer() {
+		name := "Closure"
+		switch {
+		case env.Outer() == nil:
+			name = "Globals"
+		case env == frame.Env:
+			name = "Locals"
+		}
+		scopes = append(scopes, Scope{Name: name, VariablesReference: s.paused.ref(env)})
+	}
+	return map[string]interface{}{"scopes": scopes}, nil
+}
+
+func (s *Server) variables(args json.RawMessage) (interface{}, error) {
+	var a variablesArguments
+	if err := unmarshalArgs(args, &a); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.paused
+	if p == nil {
+		return nil, errors.New("the program is not paused")
+	}
+	if a.VariablesReference < 1 || a.VariablesReference > len(p.refs) {
+		return nil, fmt.Errorf("unknown variables reference %d", a.VariablesReference)
+	}
+
+	vars := []Variable{}
+	switch v := p.refs[a.VariablesReference-1].(type) {
+	case *object.Environment:
+		for _, name := range v.Names() {
+			obj, _ := v.Get(name)
+			vars = append(vars, p.variable(name, obj))
+		}
+	case *object.Array:
+		for i, el := range v.Elements() {
+			vars = append(vars, p.variable(fmt.Sprintf("[%d]", i), el))
+		}
+	case *object.Hash:
+		for _, pair := range v.SortedPairs() {
+			vars = append(vars, p.variable(pair.Key.Inspect(), pair.Val))
+		}
+	}
+	return map[string]interface{}{"variables": vars}, nil
+}
+
+// variable describes obj, arrays and hashes get a reference to their
+// elements
+func (p *pause) variable(name string, obj object.Object) Variable {
+	v := Variable{Name: name, Value: display(obj), Type: string(obj.Type())}
+	switch obj := obj.(type) {
+	case *object.Array:
+		if obj.Len() != 0 {
+			v.VariablesReference = p.ref(obj)
+		}
+	case *object.Hash:
+		if obj.Len() != 0 {
+			v.VariablesReference = p.ref(obj)
+		}
+	}
+	return v
+}
+
+// display returns the value shown for obj, functions are cut to their
+// signature
+func display(obj object.Object) string {
+	if fn, ok := obj.(*object.Function); ok {
+		params := []string{}
+		for _, p := range fn.Parameters {
+			params = append(params, p.Value)
+		}
+		return "fn(" + strings.Join(params, ", ") + ")"
+	}
+	return obj.Inspect()
+}
+
+// evaluate evaluates a watch expression in the environment of a frame of
+// the paused program
+func (s *Server) evaluate(args json.RawMessage) (interface{}, error) {
+	var a evaluateArguments
+	if err := unmarshalArgs(args, &a); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.paused == nil {
+		return nil, errors.New("the program is not paused")
+	}
+	frame, err := s.paused.frame(a.FrameID)
+	if err != nil {
+		return nil, err
+	}
+	if frame.Env == nil {
+		return nil, fmt.Errorf("%s is a built-in function", frame.Name())
+	}
+
+	p := parser.New(lexer.New(a.Expression))
+	program := p.ParseProgram()
+	if errs := p.ErrorList(); len(errs) != 0 {
+		return nil, errs[0]
+	}
+	result := evaluator.Eval(program, frame.Env)
+	if result == nil {
+		return map[string]interface{}{"result": "", "variablesReference": 0}, nil
+	}
+	if err, ok := result.(*object.Error); ok {
+		return nil, errors.New(err.Message)
+	}
+	v := s.paused.variable(a.Expression, result)
+	return map[string]interface{}{
+		"result":             v.Value,
+		"type":               v.Type,
+		"variablesReference": v.VariablesReference,
+	}, nil
+}
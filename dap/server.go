@@ -0,0 +1,445 @@
+// Package dap implements a Debug Adapter Protocol server for Monkey.
+//
+// The server launches a single program and evaluates it on its own
+// goroutine with an evaluator hook, which pauses the program on line
+// breakpoints and steps. While the program is paused the client can inspect
+// the call stack, the environment chain of each frame and evaluate watch
+// expressions in the scope of a frame.
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/lycheng/monkey-go/ast"
+	"github.com/lycheng/monkey-go/evaluator"
+	"github.com/lycheng/monkey-go/lexer"
+	"github.com/lycheng/monkey-go/object"
+	"github.com/lycheng/monkey-go/parser"
+)
+
+// threadID of the only thread of a Monkey program
+const threadID = 1
+
+// stepMode tells the hook where to pause next
+type stepMode int
+
+const (
+	modeContinue stepMode = iota // on breakpoints only
+	modeEntry                    // on the first statement
+	modeStepIn                   // on the next statement
+	modeStepOver                 // on the next statement of the frame or a caller
+	modeStepOut                  // on the next statement of a caller
+	modePause                    // on the next statement, asked by the client
+)
+
+// errTerminated stops the program when the client terminates it
+var errTerminated = &object.Error{Message: "terminated by the debugger"}
+
+// Server for the Debug Adapter Protocol
+type Server struct {
+	in *bufio.Reader
+
+	wmu sync.Mutex // guards out and seq, events are sent by the program too
+	out io.Writer
+	seq int
+
+	source     Source
+	program    *ast.Program
+	lines      map[int]bool // lines where a statement starts
+	launched   bool
+	configured bool
+	done       chan struct{} // closed when the program ends, nil before it starts
+	afterReply func()        // run once the response to the current request is sent
+	quit       bool
+
+	mu          sync.Mutex // guards the fields below, they are shared with the hook
+	breakpoints map[int]bool
+	mode        stepMode
+	depth       int // call depth of the last pause
+	lastLine    int
+	lastDepth   int
+	terminated  bool
+	paused      *pause // nil while the program runs
+
+	resume chan struct{}
+}
+
+// pause is the state of the paused program
+type pause struct {
+	frames []*evaluator.Frame
+	refs   []interface{} // environments and values by variables reference - 1
+}
+
+// ref returns the variables reference of an environment or a value
+func (p *pause) ref(v interface{}) int {
+	p.refs = append(p.refs, v)
+	return len(p.refs)
+}
+
+// NewServer returns a server reading requests from in and writing
+// responses and events to out
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:          bufio.NewReader(in),
+		out:         out,
+		breakpoints: make(map[int]bool),
+		resume:      make(chan struct{}),
+	}
+}
+
+type handler func(s *Server, args json.RawMessage) (interface{}, error)
+
+var handlers = map[string]handler{
+	"initialize":              (*Server).initialize,
+	"launch":                  (*Server).launch,
+	"setBreakpoints":          (*Server).setBreakpoints,
+	"setExceptionBreakpoints": (*Server).setExceptionBreakpoints,
+	"configurationDone":       (*Server).configurationDone,
+	"threads":                 (*Server).threads,
+	"stackTrace":              (*Server).stackTrace,
+	"scopes":                  (*Server).scopes,
+	"variables":               (*Server).variables,
+	"evaluate":                (*Server).evaluate,
+	"continue":                (*Server).continueRequest,
+	"next":                    (*Server).next,
+	"stepIn":                  (*Server).stepIn,
+	"stepOut":                 (*Server).stepOut,
+	"pause":                   (*Server).pauseRequest,
+	"terminate":               (*Server).terminate,
+	"disconnect":              (*Server).disconnect,
+}
+
+// Run serves until the client disconnects or closes the input stream, the
+// program is terminated before Run returns
+func (s *Server) Run() error {
+	defer s.stopProgram()
+	for !s.quit {
+		req, err := readRequest(s.in)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if err := s.handle(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Output sends text written by the program to the client
+func (s *Server) Output(text string) error {
+	return s.send("output", outputEventBody{Category: "stdout", Output: text})
+}
+
+func (s *Server) handle(req *request) error {
+	fn, ok := handlers[req.Command]
+	if !ok {
+		return s.reply(req, nil, fmt.Errorf("unsupported command: %s", req.Command))
+	}
+	body, err := fn(s, req.Arguments)
+	if err := s.reply(req, body, err); err != nil {
+		return err
+	}
+	if after := s.afterReply; after != nil {
+		s.afterReply = nil
+		after()
+	}
+	return nil
+}
+
+func (s *Server) reply(req *request, body interface{}, err error) error {
+	resp := &response{
+		Type:       "response",
+		RequestSeq: req.Seq,
+		Success:    err == nil,
+		Command:    req.Command,
+		Body:       body,
+	}
+	if err != nil {
+		resp.Message = err.Error()
+		resp.Body = nil
+	}
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	s.seq++
+	resp.Seq = s.seq
+	return writeMessage(s.out, resp)
+}
+
+func (s *Server) send(name string, body interface{}) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	s.seq++
+	return writeMessage(s.out, &event{Seq: s.seq, Type: "event", Event: name, Body: body})
+}
+
+func unmarshalArgs(args json.RawMessage, v interface{}) error {
+	if len(args) == 0 {
+		return nil
+	}
+	return json.Unmarshal(args, v)
+}
+
+func (s *Server) initialize(args json.RawMessage) (interface{}, error) {
+	return Capabilities{
+		SupportsConfigurationDoneRequest: true,
+		SupportsEvaluateForHovers:        true,
+		SupportsTerminateRequest:         true,
+	}, nil
+}
+
+func (s *Server) launch(args json.RawMessage) (interface{}, error) {
+	var a launchArguments
+	if err := unmarshalArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if s.launched {
+		return nil, errors.New("a program is already launched")
+	}
+	path, err := filepath.Abs(a.Program)
+	if err != nil {
+		return nil, err
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if errs := p.ErrorList(); len(errs) != 0 {
+		return nil, fmt.Errorf("%s:%s", a.Program, errs[0])
+	}
+
+	s.source = Source{Name: filepath.Base(path), Path: path}
+	s.program = program
+	s.lines = make(map[int]bool)
+	statementLines(program, s.lines)
+	s.launched = true
+	if a.StopOnEntry {
+		s.mu.Lock()
+		s.mode = modeEntry
+		s.mu.Unlock()
+	}
+	// configuration requests are expected once the program is known
+	s.afterReply = func() { s.send("initialized", nil) }
+	return nil, nil
+}
+
+// statementLines records the lines where the statements in node start
+func statementLines(node ast.Node, lines map[int]bool) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.LetStatement, *ast.ReturnStatement, *ast.ExpressionStatement, *ast.FunctionStatement:
+			lines[n.Pos().Line] = true
+		}
+		return true
+	})
+}
+
+func (s *Server) setBreakpoints(args json.RawMessage) (interface{}, error) {
+	var a setBreakpointsArguments
+	if err := unmarshalArgs(args, &a); err != nil {
+		return nil, err
+	}
+	path, err := filepath.Abs(a.Source.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	breakpoints := []Breakpoint{}
+	lines := make(map[int]bool)
+	for _, bp := range a.Breakpoints {
+		result := Breakpoint{Line: bp.Line}
+		switch {
+		case !s.launched || path != s.source.Path:
+			result.Message = "source is not the launched program"
+		case !s.lines[bp.Line]:
+			result.Message = "no statement starts on this line"
+		default:
+			result.Verified = true
+			lines[bp.Line] = true
+		}
+		breakpoints = append(breakpoints, result)
+	}
+	if s.launched && path == s.source.Path {
+		s.mu.Lock()
+		s.breakpoints = lines
+		s.mu.Unlock()
+	}
+	return map[string]interface{}{"breakpoints": breakpoints}, nil
+}
+
+func (s *Server) setExceptionBreakpoints(args json.RawMessage) (interface{}, error) {
+	return map[string]interface{}{"breakpoints": []Breakpoint{}}, nil
+}
+
+func (s *Server) configurationDone(args json.RawMessage) (interface{}, error) {
+	if !s.launched {
+		return nil, errors.New("no program is launched")
+	}
+	if !s.configured {
+		s.configured = true
+		s.afterReply = s.start
+	}
+	return nil, nil
+}
+
+// start evaluates the program on its own goroutine
+func (s *Server) start() {
+	s.done = make(chan struct{})
+	e := evaluator.New()
+	e.Hook = s.hook
+	go func() {
+		defer close(s.done)
+		code := 0
+		defer func() {
+			if r := recover(); r != nil {
+				s.send("output", outputEventBody{Category: "stderr", Output: fmt.Sprintf("panic: %v\n", r)})
+				code = 2
+			}
+			s.send("exited", exitedEventBody{ExitCode: code})
+			s.send("terminated", nil)
+		}()
+		result := e.Eval(s.program, object.NewEnvironment())
+		if err, ok := result.(*object.Error); ok && err != errTerminated {
+			s.send("output", outputEventBody{Category: "stderr", Output: "ERROR: " + err.Message + "\n"})
+			code = 1
+		}
+	}()
+}
+
+// hook runs on the goroutine of the program before each statement, it
+// blocks while the program is paused
+func (s *Server) hook(e *evaluator.Evaluator, stmt ast.Statement, env *object.Environment) *object.Error {
+	line, depth := stmt.Pos().Line, e.Depth()
+
+	s.mu.Lock()
+	if s.terminated {
+		s.mu.Unlock()
+		return errTerminated
+	}
+	reason := ""
+	switch s.mode {
+	case modeEntry:
+		reason = "entry"
+	case modeStepIn:
+		reason = "step"
+	case modeStepOver:
+		if depth <= s.depth {
+			reason = "step"
+		}
+	case modeStepOut:
+		if depth < s.depth {
+			reason = "step"
+		}
+	case modePause:
+		reason = "pause"
+	}
+	// a breakpoint is hit once when several statements share its line
+	if reason == "" && s.breakpoints[line] && (line != s.lastLine || depth != s.lastDepth) {
+		reason = "breakpoint"
+	}
+	s.lastLine, s.lastDepth = line, depth
+	if reason == "" {
+		s.mu.Unlock()
+		return nil
+	}
+	s.paused = &pause{frames: e.Stack()}
+	s.mode, s.depth = modeContinue, depth
+	s.mu.Unlock()
+
+	s.send("stopped", stoppedEventBody{Reason: reason, ThreadID: threadID, AllThreadsStopped: true})
+	<-s.resume
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.terminated {
+		return errTerminated
+	}
+	return nil
+}
+
+// current returns the state of the paused program
+func (s *Server) current() (*pause, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.paused == nil {
+		return nil, errors.New("the program is not paused")
+	}
+	return s.paused, nil
+}
+
+// proceed resumes the paused program once the response is sent
+func (s *Server) proceed(mode stepMode) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.paused == nil {
+		return nil, errors.New("the program is not paused")
+	}
+	s.paused = nil
+	s.mode = mode
+	s.afterReply = func() { s.resume <- struct{}{} }
+	return map[string]interface{}{"allThreadsContinued": true}, nil
+}
+
+func (s *Server) continueRequest(args json.RawMessage) (interface{}, error) {
+	return s.proceed(modeContinue)
+}
+
+func (s *Server) next(args json.RawMessage) (interface{}, error) {
+	return s.proceed(modeStepOver)
+}
+
+func (s *Server) stepIn(args json.RawMessage) (interface{}, error) {
+	return s.proceed(modeStepIn)
+}
+
+func (s *Server) stepOut(args json.RawMessage) (interface{}, error) {
+	return s.proceed(modeStepOut)
+}
+
+func (s *Server) pauseRequest(args json.RawMessage) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.paused == nil {
+		s.mode = modePause
+	}
+	return nil, nil
+}
+
+func (s *Server) terminate(args json.RawMessage) (interface{}, error) {
+	s.afterReply = s.stopProgram
+	return nil, nil
+}
+
+func (s *Server) disconnect(args json.RawMessage) (interface{}, error) {
+	s.afterReply = func() {
+		s.stopProgram()
+		s.quit = true
+	}
+	return nil, nil
+}
+
+// stopProgram terminates the program and waits for it to end
+func (s *Server) stopProgram() {
+	s.mu.Lock()
+	s.terminated = true
+	paused := s.paused != nil
+	s.paused = nil
+	s.mu.Unlock()
+	if paused {
+		s.resume <- struct{}{}
+	}
+	if s.done != nil {
+		<-s.done
+	}
+}
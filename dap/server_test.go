@@ -0,0 +1,327 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lycheng/monkey-go/framing"
+)
+
+const program = `let add = fn(a, b) {
+  let sum = a + b;
+  sum
+};
+let x = add(1, 2);
+let y = {"x": x, "list": [x, 2]};
+y
+`
+
+// testMessage is any message sent by the server
+type testMessage struct {
+	Type       string          `json:"type"`
+	Event      string          `json:"event"`
+	Command    string          `json:"command"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// client drives a server running on its own goroutine
+type client struct {
+	t        *testing.T
+	in       *io.PipeWriter
+	messages chan *testMessage
+	pending  []*testMessage
+	seq      int
+	done     chan error
+}
+
+func newClient(t *testing.T) *client {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &client{t: t, in: inW, messages: make(chan *testMessage, 100), done: make(chan error, 1)}
+	go func() {
+		err := NewServer(inR, outW).Run()
+		outW.Close()
+		c.done <- err
+	}()
+	go func() {
+		defer close(c.messages)
+		r := bufio.NewReader(outR)
+		for {
+			body, err := framing.Read(r)
+			if err != nil {
+				return
+			}
+			msg := &testMessage{}
+			if err := json.Unmarshal(body, msg); err != nil {
+				t.Errorf("invalid message %s: %s", body, err)
+				return
+			}
+			c.messages <- msg
+		}
+	}()
+	return c
+}
+
+// next returns the first message, kept or received, matching match
+func (c *client) next(match func(*testMessage) bool) *testMessage {
+	c.t.Helper()
+	for i, msg := range c.pending {
+		if match(msg) {
+			c.pending = append(c.pending[:i], c.pending[i+1:]...)
+			return msg
+		}
+	}
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg, ok := <-c.messages:
+			if !ok {
+				c.t.Fatalf("server closed the stream")
+			}
+			if match(msg) {
+				return msg
+			}
+			c.pending = append(c.pending, msg)
+		case <-timeout:
+			c.t.Fatalf("timed out waiting for a message")
+		}
+	}
+}
+
+// request sends a request and returns the body of its response
+func (c *client) request(command string, args interface{}) *testMessage {
+	c.t.Helper()
+	c.seq++
+	data, _ := json.Marshal(map[string]interface{}{
+		"seq": c.seq, "type": "request", "command": command, "arguments": args,
+	})
+	if _, err := fmt.Fprintf(c.in, "Content-Length: %d\r\n\r\n%s", len(data), data); err != nil {
+		c.t.Fatalf("write failed: %s", err)
+	}
+	seq := c.seq
+	return c.next(func(m *testMessage) bool {
+		return m.Type == "response" && m.RequestSeq == seq
+	})
+}
+
+// ok sends a request, checks that it succeeds and decodes the body into v
+func (c *client) ok(command string, args interface{}, v interface{}) {
+	c.t.Helper()
+	resp := c.request(command, args)
+	if !resp.Success {
+		c.t.Fatalf("%s failed: %s", command, resp.Message)
+	}
+	if v != nil {
+		if err := json.Unmarshal(resp.Body, v); err != nil {
+			c.t.Fatalf("invalid %s body %s: %s", command, resp.Body, err)
+		}
+	}
+}
+
+func (c *client) event(name string) *testMessage {
+	c.t.Helper()
+	return c.next(func(m *testMessage) bool {
+		return m.Type == "event" && m.Event == name
+	})
+}
+
+// stopped waits for the program to stop and returns the reason and the
+// stack
+func (c *client) stopped() (string, []StackFrame) {
+	c.t.Helper()
+	var body stoppedEventBody
+	json.Unmarshal(c.event("stopped").Body, &body)
+	var trace struct {
+		StackFrames []StackFrame `json:"stackFrames"`
+	}
+	c.ok("stackTrace", map[string]int{"threadId": threadID}, &trace)
+	return body.Reason, trace.StackFrames
+}
+
+func (c *client) close() {
+	c.t.Helper()
+	c.ok("disconnect", nil, nil)
+	if err := <-c.done; err != nil {
+		c.t.Errorf("server returned error: %s", err)
+	}
+}
+
+func (c *client) launch(stopOnEntry bool) string {
+	c.t.Helper()
+	path := filepath.Join(c.t.TempDir(), "test.mk")
+	if err := os.WriteFile(path, []byte(program), 0o644); err != nil {
+		c.t.Fatal(err)
+	}
+	c.ok("initialize", map[string]string{"adapterID": "monkey"}, nil)
+	c.ok("launch", map[string]interface{}{"program": path, "stopOnEntry": stopOnEntry}, nil)
+	c.event("initialized")
+	return path
+}
+
+func location(frames []StackFrame) string {
+	s := ""
+	for _, f := range frames {
+		s += fmt.Sprintf("%s:%d:%d ", f.Name, f.Line, f.Column)
+	}
+	return s
+}
+
+func TestBreakpointsAndSteps(t *testing.T) {
+	c := newClient(t)
+	path := c.launch(false)
+
+	var bps struct {
+		Breakpoints []Breakpoint `json:"breakpoints"`
+	}
+	c.ok("setBreakpoints", map[string]interface{}{
+		"source":      map[string]string{"path": path},
+		"breakpoints": []map[string]int{{"line": 2}, {"line": 4}},
+	}, &bps)
+	if len(bps.Breakpoints) != 2 || !bps.Breakpoints[0].Verified || bps.Breakpoints[1].Verified {
+		t.Fatalf("wrong breakpoints %+v", bps.Breakpoints)
+	}
+	c.ok("configurationDone", nil, nil)
+
+	tests := []struct {
+		command  string // resumes the program, empty for the first stop
+		reason   string
+		location string
+	}{
+		{"", "breakpoint", "add:2:3 main:5:1 "},
+		{"next", "step", "add:3:3 main:5:1 "},
+		{"stepOut", "step", "main:6:1 "},
+		{"next", "step", "main:7:1 "},
+	}
+	for _, tt := range tests {
+		if tt.command != "" {
+			c.ok(tt.command, map[string]int{"threadId": threadID}, nil)
+		}
+		reason, frames := c.stopped()
+		if reason != tt.reason || location(frames) != tt.location {
+			t.Fatalf("after %q expected %s at %q, got %s at %q",
+				tt.command, tt.reason, tt.location, reason, location(frames))
+		}
+	}
+
+	c.ok("continue", map[string]int{"threadId": threadID}, nil)
+	var exited exitedEventBody
+	json.Unmarshal(c.event("exited").Body, &exited)
+	if exited.ExitCode != 0 {
+		t.Errorf("wrong exit code %d", exited.ExitCode)
+	}
+	c.event("terminated")
+	if resp := c.request("stackTrace", map[string]int{"threadId": threadID}); resp.Success {
+		t.Errorf("stackTrace succeeded after the program ended")
+	}
+	c.close()
+}
+
+func TestVariablesAndEvaluate(t *testing.T) {
+	c := newClient(t)
+	c.launch(true)
+	c.ok("configurationDone", nil, nil)
+
+	reason, frames := c.stopped()
+	if reason != "entry" || location(frames) != "main:1:1 " {
+		t.Fatalf("wrong entry stop %s at %q", reason, location(frames))
+	}
+	for _, want := range []string{"main:5:1 ", "add:2:3 main:5:1 "} {
+		c.ok("stepIn", map[string]int{"threadId": threadID}, nil)
+		if _, frames = c.stopped(); location(frames) != want {
+			t.Fatalf("wrong step in location. expected=%q, got=%q", want, location(frames))
+		}
+	}
+
+	var scopes struct {
+		Scopes []Scope `json:"scopes"`
+	}
+	c.ok("scopes", map[string]int{"frameId": frames[0].ID}, &scopes)
+	if len(scopes.Scopes) != 2 || scopes.Scopes[0].Name != "Locals" || scopes.Scopes[1].Name != "Globals" {
+		t.Fatalf("wrong scopes %+v", scopes.Scopes)
+	}
+	vars := func(ref int) string {
+		var body struct {
+			Variables []Variable `json:"variables"`
+		}
+		c.ok("variables", map[string]int{"variablesReference": ref}, &body)
+		s := ""
+		for _, v := range body.Variables {
+			s += fmt.Sprintf("%s=%s ", v.Name, v.Value)
+		}
+		return s
+	}
+	if got := vars(scopes.Scopes[0].VariablesReference); got != "a=1 b=2 " {
+		t.Errorf("wrong locals %q", got)
+	}
+	if got := vars(scopes.Scopes[1].VariablesReference); got != "add=fn(a, b) " {
+		t.Errorf("wrong globals %q", got)
+	}
+
+	evaluate := func(expr string, frame int) *testMessage {
+		return c.request("evaluate", map[string]interface{}{
+			"expression": expr, "frameId": frame, "context": "watch",
+		})
+	}
+	resp := evaluate("a * 10 + b", frames[0].ID)
+	var result struct {
+		Result string `json:"result"`
+		Type   string `json:"type"`
+	}
+	json.Unmarshal(resp.Body, &result)
+	if !resp.Success || result.Result != "12" || result.Type != "INTEGER" {
+		t.Errorf("wrong watch result %+v", resp)
+	}
+	if resp := evaluate("a", frames[1].ID); resp.Success || resp.Message != "identifier not found: a" {
+		t.Errorf("expected an error for a in the program frame, got %+v", resp)
+	}
+	if resp := evaluate("a +", frames[0].ID); resp.Success {
+		t.Errorf("expected a parse error, got %+v", resp)
+	}
+
+	// stop after y is set and expand it
+	c.ok("stepOut", map[string]int{"threadId": threadID}, nil)
+	c.stopped()
+	c.ok("next", map[string]int{"threadId": threadID}, nil)
+	if _, frames = c.stopped(); location(frames) != "main:7:1 " {
+		t.Fatalf("wrong location %q", location(frames))
+	}
+	resp = evaluate("y", 0)
+	var hash struct {
+		VariablesReference int `json:"variablesReference"`
+	}
+	json.Unmarshal(resp.Body, &hash)
+	if got := vars(hash.VariablesReference); got != "list=[3, 2] x=3 " {
+		t.Errorf("wrong hash elements %q", got)
+	}
+	c.close()
+}
+
+func TestDisconnectWhilePaused(t *testing.T) {
+	c := newClient(t)
+	c.launch(true)
+	c.ok("configurationDone", nil, nil)
+	c.stopped()
+	c.ok("disconnect", nil, nil)
+	c.event("terminated")
+	if err := <-c.done; err != nil {
+		t.Errorf("server returned error: %s", err)
+	}
+}
+
+func TestInvalidContentLength(t *testing.T) {
+	in := strings.NewReader("Content-Length: -1\r\n\r\n{}")
+	err := NewServer(in, io.Discard).Run()
+	if err == nil || err.Error() != `invalid Content-Length "-1"` {
+		t.Errorf("wrong error: %v", err)
+	}
+}
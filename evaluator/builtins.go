@@ -2,20 +2,23 @@ package evaluator
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/lycheng/monkey-go/object"
 )
 
 var builtins = map[string]*object.Builtin{
 	"len": {
-		Fn: func(args ...object.Object) object.Object {
+		Name: "len",
+		Doc:  "len(x) returns the number of elements of an array or the number of bytes of a string",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1",
 					len(args))
 			}
 			switch arg := args[0].(type) {
 			case *object.Array:
-				return &object.Integer{Value: int64(len(arg.Elements))}
+				return &object.Integer{Value: int64(arg.Len())}
 			case *object.String:
 				return &object.Integer{Value: int64(len(arg.Value))}
 			default:
@@ -25,7 +28,9 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"first": {
-		Fn: func(args ...object.Object) object.Object {
+		Name: "first",
+		Doc:  "first(array) returns the first element of array, or null if it is empty",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1",
 					len(args))
@@ -35,14 +40,16 @@ var builtins = map[string]*object.Builtin{
 					args[0].Type())
 			}
 			arr := args[0].(*object.Array)
-			if len(arr.Elements) > 0 {
-				return arr.Elements[0]
+			if arr.Len() > 0 {
+				return arr.At(0)
 			}
 			return nullObj
 		},
 	},
 	"last": {
-		Fn: func(args ...object.Object) object.Object {
+		Name: "last",
+		Doc:  "last(array) returns the last element of array, or null if it is empty",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1",
 					len(args))
@@ -52,15 +59,17 @@ var builtins = map[string]*object.Builtin{
 					args[0].Type())
 			}
 			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
+			length := arr.Len()
 			if length > 0 {
-				return arr.Elements[length-1]
+				return arr.At(length - 1)
 			}
 			return nullObj
 		},
 	},
 	"rest": {
-		Fn: func(args ...object.Object) object.Object {
+		Name: "rest",
+		Doc:  "rest(array) returns a new array without the first element, or null if it is empty",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1",
 					len(args))
@@ -70,17 +79,17 @@ var builtins = map[string]*object.Builtin{
 					args[0].Type())
 			}
 			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
+			length := arr.Len()
 			if length > 0 {
-				newElements := make([]object.Object, length-1, length-1)
-				copy(newElements, arr.Elements[1:length])
-				return &object.Array{Elements: newElements}
+				return arr.Slice(1, length)
 			}
 			return nullObj
 		},
 	},
 	"push": {
-		Fn: func(args ...object.Object) object.Object {
+		Name: "push",
+		Doc:  "push(array, x) returns a new array with x appended",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
 			if len(args) != 2 {
 				return newError("wrong number of arguments. got=%d, want=2",
 					len(args))
@@ -89,20 +98,114 @@ var builtins = map[string]*object.Builtin{
 				return newError("argument to `push` must be ARRAY, got %s",
 					args[0].Type())
 			}
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
-			newElements := make([]object.Object, length+1, length+1)
-			copy(newElements, arr.Elements)
-			newElements[length] = args[1]
-			return &object.Array{Elements: newElements}
+			return args[0].(*object.Array).Push(args[1])
 		},
 	},
 	"puts": {
-		Fn: func(args ...object.Object) object.Object {
+		Name: "puts",
+		Doc:  "puts(x, ...) prints the arguments, one per line, and returns null",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
 			for _, arg := range args {
 				fmt.Println(arg.Inspect())
 			}
 			return nullObj
 		},
 	},
+	"try": {
+		Name: "try",
+		Doc:  "try(fn, handler) returns the result of fn(), or of handler(message) when fn() fails with an error",
+		Fn: func(caller object.Caller, args ...object.Object) object.Object {
+			if err := checkArgs("try", args, object.FUNCTION, object.FUNCTION); err != nil {
+				return err
+			}
+			result := caller.Call(args[0])
+			err, ok := result.(*object.Error)
+			if !ok {
+				return result
+			}
+			if e, ok := caller.(*Evaluator); ok && e.stopped == err {
+				return err // the evaluation is stopped by the hook
+			}
+			return caller.Call(args[1], &object.String{Value: err.Message})
+		},
+	},
+}
+
+// checkArgs returns an error when args are not one argument of each type,
+// in the style of the messages of the other built-in functions. FUNCTION
+// arguments may be built-in functions too, and an empty type accepts any
+// argument.
+func checkArgs(name string, args []object.Object, types ...object.Type) *object.Error {
+	if len(args) != len(types) {
+		return newError("wrong number of arguments. got=%d, want=%d",
+			len(args), len(types))
+	}
+	for i, typ := range types {
+		if !hasType(args[i], typ) {
+			return typeError(name, i, typ, args[i])
+		}
+	}
+	return nil
+}
+
+// checkOptionalArgs is checkArgs for functions whose arguments after the
+// required ones may be left out
+func checkOptionalArgs(name string, args []object.Object, required int, types ...object.Type) *object.Error {
+	if len(args) < required || len(args) > len(types) {
+		if len(types) == required+1 {
+			return newError("wrong number of arguments. got=%d, want=%d or %d",
+				len(args), required, len(types))
+		}
+		return newError("wrong number of arguments. got=%d, want=%d to %d",
+			len(args), required, len(types))
+	}
+	for i, arg := range args {
+		if !hasType(arg, types[i]) {
+			return typeError(name, i, types[i], arg)
+		}
+	}
+	return nil
+}
+
+// hasType reports whether obj is of the type, built-in functions have the
+// FUNCTION type too
+func hasType(obj object.Object, typ object.Type) bool {
+	return typ == "" || obj.Type() == typ ||
+		(typ == object.FUNCTION && obj.Type() == object.BUILTIN)
+}
+
+// typeError reports the argument i of a built-in function not having the
+// wanted type
+func typeError(name string, i int, want object.Type, got object.Object) *object.Error {
+	if i == 0 {
+		return newError("argument to `%s` must be %s, got %s", name, want, got.Type())
+	}
+	return newError("argument %d to `%s` must be %s, got %s", i+1, name, want, got.Type())
+}
+
+// register adds built-in functions defined in other files, it is only
+// called by init functions so builtins is read-only during evaluations
+func register(fns map[string]*object.Builtin) {
+	for name, fn := range fns {
+		fn.Name = name
+		builtins[name] = fn
+	}
+}
+
+// BuiltinNames returns the names of all built-in functions in sorted order
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BuiltinDoc returns the usage of the built-in function name
+func BuiltinDoc(name string) (string, bool) {
+	if builtin, ok := builtins[name]; ok {
+		return builtin.Doc, true
+	}
+	return "", false
 }
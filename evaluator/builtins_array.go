@@ -0,0 +1,346 @@
+package evaluator
+
+import (
+	"sort"
+
+	"github.com/lycheng/monkey-go/object"
+)
+
+// The functions passed to the array built-in functions are called through
+// the caller, errors they return stop the iteration and are returned as is
+
+// maxRangeLen bounds the number of elements of the arrays built by range
+const maxRangeLen = 1 << 24
+
+func init() {
+	register(map[string]*object.Builtin{
+		"map": {
+			Doc: "map(array, fn) returns the array of the results of fn called on each element of array",
+			Fn: func(caller object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("map", args, object.ARRAY, object.FUNCTION); err != nil {
+					return err
+				}
+				elements := args[0].(*object.Array).Elements()
+				mapped := make([]object.Object, len(elements))
+				for i, el := range elements {
+					result := caller.Call(args[1], el)
+					if isError(result) {
+						return result
+					}
+					mapped[i] = result
+				}
+				return object.NewArray(mapped)
+			},
+		},
+		"filter": {
+			Doc: "filter(array, fn) returns the array of the elements of array for which fn returns a truthy value",
+			Fn: func(caller object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("filter", args, object.ARRAY, object.FUNCTION); err != nil {
+					return err
+				}
+				filtered := []object.Object{}
+				for _, el := range args[0].(*object.Array).Elements() {
+					result := caller.Call(args[1], el)
+					if isError(result) {
+						return result
+					}
+					if isTruthy(result) {
+						filtered = append(filtered, el)
+					}
+				}
+				return object.NewArray(filtered)
+			},
+		},
+		"reduce": {
+			Doc: "reduce(array, fn, initial?) folds array with fn(acc, x) from initial, or from the first element without initial, and returns null for an empty array without initial",
+			Fn: func(caller object.Caller, args ...object.Object) object.Object {
+				if err := checkOptionalArgs("reduce", args, 2, object.ARRAY, object.FUNCTION, ""); err != nil {
+					return err
+				}
+				elements := args[0].(*object.Array).Elements()
+				var acc object.Object
+				if len(args) == 3 {
+					acc = args[2]
+				} else if len(elements) == 0 {
+					return nullObj
+				} else {
+					acc, elements = elements[0], elements[1:]
+				}
+				for _, el := range elements {
+					acc = caller.Call(args[1], acc, el)
+					if isError(acc) {
+						return acc
+					}
+				}
+				return acc
+			},
+		},
+		"sort": {
+			Doc: "sort(array, cmp?) returns a sorted copy of array, of integers or strings in ascending order without cmp, cmp(a, b) returns whether a comes before b as a boolean or as a negative integer",
+			Fn: func(caller object.Caller, args ...object.Object) object.Object {
+				if err := checkOptionalArgs("sort", args, 1, object.ARRAY, object.FUNCTION); err != nil {
+					return err
+				}
+				sorted := args[0].(*object.Array).Elements()
+
+				var err object.Object
+				var less func(a, b object.Object) bool
+				if len(args) == 2 {
+					less = func(a, b object.Object) bool {
+						result := caller.Call(args[1], a, b)
+						switch result := result.(type) {
+						case *object.Boolean:
+							return result.Value
+						case *object.Integer:
+							return result.Value < 0
+						case *object.Error:
+							err = result
+						default:
+							err = newError("comparator of `sort` must return BOOLEAN or INTEGER, got %s",
+								result.Type())
+						}
+						return false
+					}
+				} else {
+					if err := checkSortable(sorted); err != nil {
+						return err
+					}
+					less = lessObjects
+				}
+				sort.SliceStable(sorted, func(i, j int) bool {
+					if err != nil {
+						return false
+					}
+					return less(sorted[i], sorted[j])
+				})
+				if err != nil {
+					return err
+				}
+				return object.NewArray(sorted)
+			},
+		},
+		"reverse": {
+			Doc: "reverse(array) returns a copy of array with the elements in reverse order",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("reverse", args, object.ARRAY); err != nil {
+					return err
+				}
+				elements := args[0].(*object.Array).Elements()
+				reversed := make([]object.Object, len(elements))
+				for i, el := range elements {
+					reversed[len(elements)-1-i] = el
+				}
+				return object.NewArray(reversed)
+			},
+		},
+		"range": {
+			Doc: "range(end), range(start, end, step?) returns the array of the integers from start, 0 by default, up to end excluded, by step, 1 by default",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkOptionalArgs("range", args, 1, object.INTEGER, object.INTEGER, object.INTEGER); err != nil {
+					return err
+				}
+				var start, end, step int64 = 0, args[0].(*object.Integer).Value, 1
+				if len(args) > 1 {
+					start, end = end, args[1].(*object.Integer).Value
+				}
+				if len(args) > 2 {
+					step = args[2].(*object.Integer).Value
+				}
+				if step == 0 {
+					return newError("step of `range` must not be 0")
+				}
+				n := rangeLen(start, end, step)
+				if n > maxRangeLen {
+					return newError("result of `range` longer than %d elements", maxRangeLen)
+				}
+				elements := make([]object.Object, n)
+				for i := range elements {
+					elements[i] = &object.Integer{Value: start}
+					start += step
+				}
+				return object.NewArray(elements)
+			},
+		},
+		"slice": {
+			Doc: "slice(array, start, end?) returns the elements of array from start up to end, or the end of array; negative indexes count from the end",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkOptionalArgs("slice", args, 2, object.ARRAY, object.INTEGER, object.INTEGER); err != nil {
+					return err
+				}
+				arr := args[0].(*object.Array)
+				last := int64(arr.Len())
+				if len(args) == 3 {
+					last = args[2].(*object.Integer).Value
+				}
+				start, end := sliceBounds(args[1].(*object.Integer).Value, last, arr.Len())
+				return arr.Slice(start, end)
+			},
+		},
+		"zip": {
+			Doc: "zip(array, ...) returns the array of the arrays of the elements at the same index in the arrays, as long as the shortest one",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if len(args) == 0 {
+					return newError("wrong number of arguments. got=0, want=1 or more")
+				}
+				length := -1
+				for i, arg := range args {
+					arr, ok := arg.(*object.Array)
+					if !ok {
+						return typeError("zip", i, object.ARRAY, arg)
+					}
+					if length < 0 || arr.Len() < length {
+						length = arr.Len()
+					}
+				}
+				zipped := make([]object.Object, length)
+				for i := range zipped {
+					tuple := make([]object.Object, len(args))
+					for j, arg := range args {
+						tuple[j] = arg.(*object.Array).At(i)
+					}
+					zipped[i] = object.NewArray(tuple)
+				}
+				return object.NewArray(zipped)
+			},
+		},
+		"any": {
+			Doc: "any(array, fn?) returns whether fn returns a truthy value for an element of array, or whether an element is truthy without fn",
+			Fn: func(caller object.Caller, args ...object.Object) object.Object {
+				return testElements(caller, "any", args, true)
+			},
+		},
+		"all": {
+			Doc: "all(array, fn?) returns whether fn returns a truthy value for all elements of array, or whether all elements are truthy without fn",
+			Fn: func(caller object.Caller, args ...object.Object) object.Object {
+				return testElements(caller, "all", args, false)
+			},
+		},
+		"find": {
+			Doc: "find(array, fn) returns the first element of array for which fn returns a truthy value, or null",
+			Fn: func(caller object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("find", args, object.ARRAY, object.FUNCTION); err != nil {
+					return err
+				}
+				for _, el := range args[0].(*object.Array).Elements() {
+					result := caller.Call(args[1], el)
+					if isError(result) {
+						return result
+					}
+					if isTruthy(result) {
+						return el
+					}
+				}
+				return nullObj
+			},
+		},
+		"flatten": {
+			Doc: "flatten(array) returns array with the elements of its nested arrays in their place, one level deep",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("flatten", args, object.ARRAY); err != nil {
+					return err
+				}
+				flattened := []object.Object{}
+				for _, el := range args[0].(*object.Array).Elements() {
+					if arr, ok := el.(*object.Array); ok {
+						flattened = append(flattened, arr.Elements()...)
+					} else {
+						flattened = append(flattened, el)
+					}
+				}
+				return object.NewArray(flattened)
+			},
+		},
+		"unique": {
+			Doc: "unique(array) returns array without the elements equal to a previous one",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("unique", args, object.ARRAY); err != nil {
+					return err
+				}
+				seen := make(map[object.HashKey]bool)
+				var others []object.Object // elements which are not hashable
+				unique := []object.Object{}
+			elements:
+				for _, el := range args[0].(*object.Array).Elements() {
+					if hashable, ok := el.(object.Hashable); ok {
+						key := hashable.HashKey()
+						if seen[key] {
+							continue
+						}
+						seen[key] = true
+					} else {
+						for _, other := range others {
+							if equalObjects(el, other) {
+								continue elements
+							}
+						}
+						others = append(others, el)
+					}
+					unique = append(unique, el)
+				}
+				return object.NewArray(unique)
+			},
+		},
+	})
+}
+
+// testElements implements any, when want is true, and all
+func testElements(caller object.Caller, name string, args []object.Object, want bool) object.Object {
+	if err := checkOptionalArgs(name, args, 1, object.ARRAY, object.FUNCTION); err != nil {
+		return err
+	}
+	for _, el := range args[0].(*object.Array).Elements() {
+		result := el
+		if len(args) == 2 {
+			result = caller.Call(args[1], el)
+			if isError(result) {
+				return result
+			}
+		}
+		if isTruthy(result) == want {
+			return nativeBoolToBooleanObject(want)
+		}
+	}
+	return nativeBoolToBooleanObject(!want)
+}
+
+// checkSortable returns an error unless elements are all integers or all
+// strings
+func checkSortable(elements []object.Object) *object.Error {
+	for _, el := range elements {
+		if el.Type() != object.INTEGER && el.Type() != object.STRING {
+			return newError("elements of argument to `sort` must be INTEGER or STRING, got %s",
+				el.Type())
+		}
+		if el.Type() != elements[0].Type() {
+			return newError("elements of argument to `sort` must have the same type, got %s and %s",
+				elements[0].Type(), el.Type())
+		}
+	}
+	return nil
+}
+
+// lessObjects compares integers or strings of the same type
+func lessObjects(a, b object.Object) bool {
+	switch a := a.(type) {
+	case *object.Integer:
+		return a.Value < b.(*object.Integer).Value
+	case *object.String:
+		return a.Value < b.(*object.String).Value
+	}
+	return false
+}
+
+// rangeLen returns the number of integers from start up to end excluded by
+// step, the differences are computed on uint64 values so they can't overflow
+func rangeLen(start, end, step int64) uint64 {
+	var span, stride uint64
+	switch {
+	case step > 0 && start < end:
+		span, stride = uint64(end)-uint64(start), uint64(step)
+	case step < 0 && start > end:
+		span, stride = uint64(start)-uint64(end), -uint64(step)
+	default:
+		return 0
+	}
+	return (span-1)/stride + 1
+}
@@ -0,0 +1,164 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lycheng/monkey-go/object"
+)
+
+func init() {
+	register(map[string]*object.Builtin{
+		"assert": {
+			Doc: "assert(cond, message?) returns null, or an error with the message when cond is not truthy",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if len(args) != 1 && len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=1 or 2",
+						len(args))
+				}
+				if isTruthy(args[0]) {
+					return nullObj
+				}
+				return newError("assertion failed%s", assertMessage(args[1:]))
+			},
+		},
+		"assert_eq": {
+			Doc: "assert_eq(got, want, message?) returns null, or an error showing the difference when got and want are not equal",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if len(args) != 2 && len(args) != 3 {
+					return newError("wrong number of arguments. got=%d, want=2 or 3",
+						len(args))
+				}
+				if equalObjects(args[0], args[1]) {
+					return nullObj
+				}
+				return newError("assert_eq failed%s\n%s",
+					assertMessage(args[2:]), inspectDiff(args[0], args[1]))
+			},
+		},
+	})
+}
+
+// assertMessage returns the optional message argument of an assertion
+func assertMessage(args []object.Object) string {
+	if len(args) == 0 {
+		return ""
+	}
+	if str, ok := args[0].(*object.String); ok {
+		return ": " + str.Value
+	}
+	return ": " + args[0].Inspect()
+}
+
+// equalObjects reports whether a and b hold the same value, functions are
+// equal when they are the same object
+func equalObjects(a, b object.Object) bool {
+	if a.Type() != b.Type() {
+		return false
+	}
+	switch a := a.(type) {
+	case *object.Integer:
+		return a.Value == b.(*object.Integer).Value
+	case *object.Boolean:
+		return a.Value == b.(*object.Boolean).Value
+	case *object.String:
+		return a.Value == b.(*object.String).Value
+	case *object.Null:
+		return true
+	case *object.Array:
+		other := b.(*object.Array)
+		if a.Len() != other.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !equalObjects(a.At(i), other.At(i)) {
+				return false
+			}
+		}
+		return true
+	case *object.Hash:
+		other := b.(*object.Hash)
+		if a.Len() != other.Len() {
+			return false
+		}
+		equal := true
+		a.Range(func(key object.HashKey, pair object.HashPair) bool {
+			otherPair, ok := other.Get(key)
+			equal = ok && equalObjects(pair.Val, otherPair.Val)
+			return equal
+		})
+		return equal
+	case *object.Error:
+		return a.Message == b.(*object.Error).Message
+	case *object.BigInteger:
+		return a.Value.Cmp(b.(*object.BigInteger).Value) == 0
+	case *object.Float:
+		return a.Value == b.(*object.Float).Value
+	case *object.Time:
+		return a.Value.Equal(b.(*object.Time).Value)
+	case *object.Duration:
+		return a.Value == b.(*object.Duration).Value
+	case *object.Regex:
+		return a.Regexp.String() == b.(*object.Regex).Regexp.String()
+	}
+	return a == b
+}
+
+// inspectDiff shows how got differs from want. Values fitting on a line
+// are shown one above the other with a caret under the first difference,
+// longer ones as a line diff.
+func inspectDiff(got, want object.Object) string {
+	g, w := got.Inspect(), want.Inspect()
+	if g == w || got.Type() != want.Type() {
+		return fmt.Sprintf("  got:  %s (%s)\n  want: %s (%s)", g, got.Type(), w, want.Type())
+	}
+	if !strings.Contains(g, "\n") && !strings.Contains(w, "\n") {
+		i := 0
+		for i < len(g) && i < len(w) && g[i] == w[i] {
+			i++
+		}
+		caret := strings.Repeat(" ", utf8.RuneCountInString(g[:i]))
+		return fmt.Sprintf("  got:  %s\n  want: %s\n        %s^", g, w, caret)
+	}
+	return lineDiff(strings.Split(g, "\n"), strings.Split(w, "\n"))
+}
+
+// lineDiff returns the lines of a and b prefixed with "-" when only a has
+// them, "+" when only b has them and a space when both have them
+func lineDiff(a, b []string) string {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	lines := []string{"  --- got", "  +++ want"}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, "    "+a[i])
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "  - "+a[i])
+			i++
+		default:
+			lines = append(lines, "  + "+b[j])
+			j++
+		}
+	}
+	return strings.Join(lines, "\n")
+}
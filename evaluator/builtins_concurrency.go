@@ -0,0 +1,205 @@
+package evaluator
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/lycheng/monkey-go/object"
+)
+
+// Spawned functions run on evaluators of their own, sharing the policy, the
+// clock, the context and the coverage of the spawning one. Hooks and tracers
+// only observe the goroutine of the program. The functions waiting stop with
+// an error when the context of the evaluator is done.
+
+// maxChannelSize bounds the buffers of channels
+const maxChannelSize = 1 << 20
+
+func init() {
+	register(map[string]*object.Builtin{
+		"spawn": {
+			Doc: "spawn(fn, arg, ...) calls fn with the arguments on its own goroutine and returns the future of its result",
+			Fn: func(caller object.Caller, args ...object.Object) object.Object {
+				if len(args) == 0 {
+					return newError("wrong number of arguments. got=0, want=1 or more")
+				}
+				if !hasType(args[0], object.FUNCTION) {
+					return typeError("spawn", 0, object.FUNCTION, args[0])
+				}
+				e := spawnedEvaluator(caller)
+				future := object.NewFuture()
+				go func() {
+					future.Resolve(e.Call(args[0], args[1:]...))
+				}()
+				return future
+			},
+		},
+		"await": {
+			Doc: "await(future) waits for the result of a spawned function and returns it, or its error",
+			Fn: func(caller object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("await", args, object.FUTURE); err != nil {
+					return err
+				}
+				future := args[0].(*object.Future)
+				ctx := contextOf(caller)
+				select {
+				case <-future.Done():
+					return future.Result()
+				case <-ctx.Done():
+					return newError("await: %s", ctx.Err())
+				}
+			},
+		},
+		"channel": {
+			Doc: "channel(size?) returns a channel buffering size objects, 0 by default",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkOptionalArgs("channel", args, 0, object.INTEGER); err != nil {
+					return err
+				}
+				size := int64(0)
+				if len(args) == 1 {
+					size = args[0].(*object.Integer).Value
+				}
+				if size < 0 {
+					return newError("argument to `channel` must not be negative, got %d", size)
+				}
+				if size > maxChannelSize {
+					return newError("argument to `channel` must be at most %d, got %d", maxChannelSize, size)
+				}
+				return &object.Channel{C: make(chan object.Object, size)}
+			},
+		},
+		"send": {
+			Doc: "send(channel, x) sends x on channel, waiting for a receiver or for room in the buffer",
+			Fn: func(caller object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("send", args, object.CHANNEL, ""); err != nil {
+					return err
+				}
+				result := selectCases(caller, "send", []object.Object{args[0], args[1]}, []bool{true}, false)
+				if isError(result) {
+					return result
+				}
+				return nullObj
+			},
+		},
+		"recv": {
+			Doc: "recv(channel) waits for an object sent on channel and returns it, or null when channel is closed",
+			Fn: func(caller object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("recv", args, object.CHANNEL); err != nil {
+					return err
+				}
+				result := selectCases(caller, "recv", []object.Object{args[0], nil}, []bool{false}, false)
+				if isError(result) {
+					return result
+				}
+				return result.(*object.Array).At(1)
+			},
+		},
+		"close": {
+			Doc: "close(channel) closes channel, the receivers get null once the objects sent are received",
+			Fn: func(_ object.Caller, args ...object.Object) (result object.Object) {
+				if err := checkArgs("close", args, object.CHANNEL); err != nil {
+					return err
+				}
+				defer func() {
+					if recover() != nil {
+						result = newError("close of closed channel")
+					}
+				}()
+				close(args[0].(*object.Channel).C)
+				return nullObj
+			},
+		},
+		"select": {
+			Doc: "select(cases, default?) waits for the first case ready: a channel to receive from or a [channel, x] array to send x, and returns [index of the case, object received or null, false when the channel received from is closed]; with default it returns [-1, default, false] when no case is ready",
+			Fn: func(caller object.Caller, args ...object.Object) object.Object {
+				if err := checkOptionalArgs("select", args, 1, object.ARRAY, ""); err != nil {
+					return err
+				}
+				elements := args[0].(*object.Array).Elements()
+				cases := make([]object.Object, 0, 2*len(elements))
+				sends := make([]bool, len(elements))
+				for i, el := range elements {
+					switch el := el.(type) {
+					case *object.Channel:
+						cases = append(cases, el, nil)
+					case *object.Array:
+						if el.Len() != 2 || el.At(0).Type() != object.CHANNEL {
+							return newError("case %d of `select` must be CHANNEL or [CHANNEL, value], got %s",
+								i, el.Inspect())
+						}
+						cases = append(cases, el.At(0), el.At(1))
+						sends[i] = true
+					default:
+						return newError("case %d of `select` must be CHANNEL or [CHANNEL, value], got %s",
+							i, el.Type())
+					}
+				}
+				result := selectCases(caller, "select", cases, sends, len(args) == 2)
+				if arr, ok := result.(*object.Array); ok && len(args) == 2 && arr.At(0).(*object.Integer).Value < 0 {
+					return object.NewArray([]object.Object{arr.At(0), args[1], arr.At(2)})
+				}
+				return result
+			},
+		},
+	})
+}
+
+// spawnedEvaluator returns an evaluator for a function spawned by the
+// evaluator calling a built-in function
+func spawnedEvaluator(caller object.Caller) *Evaluator {
+	spawned := New()
+	if e, ok := caller.(*Evaluator); ok {
+		spawned.Policy = e.Policy
+		spawned.Clock = e.Clock
+		spawned.Random = randomOf(e)
+		spawned.Context = e.Context
+		spawned.Coverage = e.Coverage
+	}
+	return spawned
+}
+
+// selectCases waits for the first ready case, given as pairs of a channel
+// and the object to send, nil to receive. It returns [index of the case,
+// object received or null, false when the channel received from is
+// closed], [-1, null, false] when no case is ready and nonblocking is true,
+// or an error when the context is done or the channel of a send is closed.
+func selectCases(caller object.Caller, name string, cases []object.Object, sends []bool, nonblocking bool) (result object.Object) {
+	ctx := contextOf(caller)
+	selected := make([]reflect.SelectCase, 0, len(sends)+1)
+	for i, send := range sends {
+		ch := reflect.ValueOf(cases[2*i].(*object.Channel).C)
+		if send {
+			selected = append(selected, reflect.SelectCase{
+				Dir: reflect.SelectSend, Chan: ch, Send: reflect.ValueOf(&cases[2*i+1]).Elem(),
+			})
+		} else {
+			selected = append(selected, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: ch})
+		}
+	}
+	if nonblocking {
+		selected = append(selected, reflect.SelectCase{Dir: reflect.SelectDefault})
+	} else {
+		selected = append(selected, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())})
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			result = newError("%s: %s", name, fmt.Sprint(r))
+		}
+	}()
+	i, value, ok := reflect.Select(selected)
+	if i == len(sends) {
+		if nonblocking {
+			return object.NewArray([]object.Object{&object.Integer{Value: -1}, nullObj, falseObj})
+		}
+		return newError("%s: %s", name, ctx.Err())
+	}
+	received := object.Object(nullObj)
+	if ok {
+		received = value.Interface().(object.Object)
+	}
+	return object.NewArray([]object.Object{
+		&object.Integer{Value: int64(i)}, received, nativeBoolToBooleanObject(ok || sends[i]),
+	})
+}
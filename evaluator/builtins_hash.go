@@ -0,0 +1,132 @@
+package evaluator
+
+import (
+	"github.com/lycheng/monkey-go/object"
+)
+
+// Hashes are values like arrays: delete and merge return new hashes, and the
+// functions listing the pairs order them by key as Inspect does
+
+func init() {
+	register(map[string]*object.Builtin{
+		"keys": {
+			Doc: "keys(hash) returns the array of the keys of hash, ordered",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("keys", args, object.HASH); err != nil {
+					return err
+				}
+				pairs := args[0].(*object.Hash).SortedPairs()
+				keys := make([]object.Object, len(pairs))
+				for i, pair := range pairs {
+					keys[i] = pair.Key
+				}
+				return object.NewArray(keys)
+			},
+		},
+		"values": {
+			Doc: "values(hash) returns the array of the values of hash, ordered by key",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("values", args, object.HASH); err != nil {
+					return err
+				}
+				pairs := args[0].(*object.Hash).SortedPairs()
+				values := make([]object.Object, len(pairs))
+				for i, pair := range pairs {
+					values[i] = pair.Val
+				}
+				return object.NewArray(values)
+			},
+		},
+		"items": {
+			Doc: "items(hash) returns the array of the [key, value] pairs of hash, ordered by key",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("items", args, object.HASH); err != nil {
+					return err
+				}
+				pairs := args[0].(*object.Hash).SortedPairs()
+				items := make([]object.Object, len(pairs))
+				for i, pair := range pairs {
+					items[i] = object.NewArray([]object.Object{pair.Key, pair.Val})
+				}
+				return object.NewArray(items)
+			},
+		},
+		"has": {
+			Doc: "has(hash, key) returns whether hash holds key, even with a null value",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("has", args, object.HASH, ""); err != nil {
+					return err
+				}
+				key, err := hashKey("has", args[1])
+				if err != nil {
+					return err
+				}
+				_, ok := args[0].(*object.Hash).Get(key)
+				return nativeBoolToBooleanObject(ok)
+			},
+		},
+		"get": {
+			Doc: "get(hash, key, default?) returns the value of key in hash, or default, null by default, when hash doesn't hold key",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkOptionalArgs("get", args, 2, object.HASH, "", ""); err != nil {
+					return err
+				}
+				key, err := hashKey("get", args[1])
+				if err != nil {
+					return err
+				}
+				if pair, ok := args[0].(*object.Hash).Get(key); ok {
+					return pair.Val
+				}
+				if len(args) == 3 {
+					return args[2]
+				}
+				return nullObj
+			},
+		},
+		"delete": {
+			Doc: "delete(hash, key) returns a copy of hash without key",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("delete", args, object.HASH, ""); err != nil {
+					return err
+				}
+				key, err := hashKey("delete", args[1])
+				if err != nil {
+					return err
+				}
+				return args[0].(*object.Hash).Delete(key)
+			},
+		},
+		"merge": {
+			Doc: "merge(hash, ...) returns a hash with the pairs of all the hashes, the values of the last ones win",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if len(args) == 0 {
+					return newError("wrong number of arguments. got=0, want=1 or more")
+				}
+				for i, arg := range args {
+					if arg.Type() != object.HASH {
+						return typeError("merge", i, object.HASH, arg)
+					}
+				}
+				merged := args[0].(*object.Hash)
+				for _, arg := range args[1:] {
+					arg.(*object.Hash).Range(func(key object.HashKey, pair object.HashPair) bool {
+						merged = merged.Set(key, pair)
+						return true
+					})
+				}
+				return merged
+			},
+		},
+	})
+}
+
+// hashKey returns the key of the key argument of a built-in function
+func hashKey(name string, key object.Object) (object.HashKey, *object.Error) {
+	hashable, ok := key.(object.Hashable)
+	if !ok {
+		return object.HashKey{}, newError("argument 2 to `%s` unusable as hash key: %s",
+			name, key.Type())
+	}
+	return hashable.HashKey(), nil
+}
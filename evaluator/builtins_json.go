@@ -0,0 +1,243 @@
+package evaluator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lycheng/monkey-go/object"
+)
+
+// JSON numbers written with a fraction or an exponent map to floats, the
+// other ones to integers, whatever their value: 2.0 and 2e3 are floats. Hash
+// keys which are not strings are written as strings, and hashes are written
+// with their keys ordered as Inspect does.
+
+// maxJSONIndent bounds the number of spaces json_stringify indents by
+const maxJSONIndent = 16
+
+func init() {
+	register(map[string]*object.Builtin{
+		"json_parse": {
+			Doc: "json_parse(str) returns the value of the JSON document str, objects become hashes and numbers integers, or floats when written with a fraction or an exponent",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("json_parse", args, object.STRING); err != nil {
+					return err
+				}
+				return parseJSON(args[0].(*object.String).Value)
+			},
+		},
+		"json_stringify": {
+			Doc: "json_stringify(x, indent?) returns x as a JSON document, indented by indent, a number of spaces or a string",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkOptionalArgs("json_stringify", args, 1, "", ""); err != nil {
+					return err
+				}
+				var buf bytes.Buffer
+				if err := writeJSON(&buf, args[0]); err != nil {
+					return err
+				}
+				if len(args) == 1 {
+					return &object.String{Value: buf.String()}
+				}
+
+				var indent string
+				switch arg := args[1].(type) {
+				case *object.Integer:
+					if arg.Value < 0 {
+						return newError("argument 2 to `json_stringify` must not be negative, got %d", arg.Value)
+					}
+					if arg.Value > maxJSONIndent {
+						return newError("argument 2 to `json_stringify` must be at most %d, got %d",
+							maxJSONIndent, arg.Value)
+					}
+					indent = strings.Repeat(" ", int(arg.Value))
+				case *object.String:
+					indent = arg.Value
+				default:
+					return newError("argument 2 to `json_stringify` must be INTEGER or STRING, got %s",
+						arg.Type())
+				}
+				var out bytes.Buffer
+				if err := json.Indent(&out, buf.Bytes(), "", indent); err != nil {
+					return newError("json_stringify: %s", err)
+				}
+				return &object.String{Value: out.String()}
+			},
+		},
+	})
+}
+
+// jsonParser reads the tokens of a JSON document
+type jsonParser struct {
+	src string
+	dec *json.Decoder
+}
+
+// parseJSON returns the value of the JSON document src, or an error with
+// the line and column where src is malformed
+func parseJSON(src string) object.Object {
+	p := &jsonParser{src: src, dec: json.NewDecoder(strings.NewReader(src))}
+	p.dec.UseNumber()
+	value, err := p.value()
+	if err == nil {
+		end := p.dec.InputOffset()
+		if _, err = p.dec.Token(); err == io.EOF {
+			return value
+		} else if err == nil {
+			rest := src[end:]
+			end += int64(len(rest) - len(strings.TrimLeft(rest, " \t\r\n")))
+			err = p.errorf(end, "unexpected data after the top level value")
+		}
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = p.errorf(int64(len(src)), "unexpected end of JSON input")
+	}
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		offset := syntaxErr.Offset
+		if offset > 0 && syntaxErr.Error() != "unexpected end of JSON input" {
+			offset-- // the offset follows the invalid character
+		}
+		err = p.errorf(offset, "%s", syntaxErr)
+	}
+	return newError("json_parse: %s", err)
+}
+
+func (p *jsonParser) value() (object.Object, error) {
+	tok, err := p.dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok := tok.(type) {
+	case json.Delim:
+		if tok == '[' {
+			return p.array()
+		}
+		if tok == '{' {
+			return p.object()
+		}
+		return nil, p.errorf(p.dec.InputOffset(), "unexpected %q", rune(tok))
+	case string:
+		return &object.String{Value: tok}, nil
+	case json.Number:
+		if !strings.ContainsAny(string(tok), ".eE") {
+			n, _ := new(big.Int).SetString(string(tok), 10)
+			return fromBig(n), nil
+		}
+		f, err := strconv.ParseFloat(string(tok), 64)
+		if err != nil {
+			return nil, p.errorf(p.dec.InputOffset()-int64(len(tok)),
+				"number %s out of range", tok)
+		}
+		return &object.Float{Value: f}, nil
+	case bool:
+		return nativeBoolToBooleanObject(tok), nil
+	default:
+		return nullObj, nil
+	}
+}
+
+func (p *jsonParser) array() (object.Object, error) {
+	elements := []object.Object{}
+	for p.dec.More() {
+		el, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, el)
+	}
+	if _, err := p.dec.Token(); err != nil {
+		return nil, err
+	}
+	return object.NewArray(elements), nil
+}
+
+func (p *jsonParser) object() (object.Object, error) {
+	hash := &object.Hash{}
+	for p.dec.More() {
+		tok, err := p.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key := &object.String{Value: tok.(string)}
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		hash = hash.Set(key.HashKey(), object.HashPair{Key: key, Val: value})
+	}
+	if _, err := p.dec.Token(); err != nil {
+		return nil, err
+	}
+	return hash, nil
+}
+
+// errorf returns an error located at the byte offset of the source
+func (p *jsonParser) errorf(offset int64, format string, a ...interface{}) error {
+	if offset > int64(len(p.src)) {
+		offset = int64(len(p.src))
+	}
+	before := p.src[:offset]
+	line := strings.Count(before, "\n") + 1
+	column := utf8.RuneCountInString(before[strings.LastIndex(before, "\n")+1:]) + 1
+	return fmt.Errorf("line %d, column %d: %s", line, column, fmt.Sprintf(format, a...))
+}
+
+// writeJSON writes obj as compact JSON
+func writeJSON(buf *bytes.Buffer, obj object.Object) *object.Error {
+	switch obj := obj.(type) {
+	case *object.Null:
+		buf.WriteString("null")
+	case *object.Boolean:
+		fmt.Fprint(buf, obj.Value)
+	case *object.Integer:
+		fmt.Fprint(buf, obj.Value)
+	case *object.BigInteger:
+		buf.WriteString(obj.Value.String())
+	case *object.Float:
+		buf.WriteString(obj.Inspect())
+	case *object.String:
+		writeJSONString(buf, obj.Value)
+	case *object.Array:
+		buf.WriteByte('[')
+		for i, el := range obj.Elements() {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSON(buf, el); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case *object.Hash:
+		buf.WriteByte('{')
+		for i, pair := range obj.SortedPairs() {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJSONString(buf, pair.Key.Inspect())
+			buf.WriteByte(':')
+			if err := writeJSON(buf, pair.Val); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return newError("json_stringify: %s is not serializable", obj.Type())
+	}
+	return nil
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	buf.Truncate(buf.Len() - 1) // newline written by Encode
+}
@@ -0,0 +1,249 @@
+package evaluator
+
+import (
+	"math/big"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/lycheng/monkey-go/object"
+)
+
+// The math functions take integers and big integers. Monkey has no floating
+// point numbers: sqrt rounds down, floor and ceil round divisions.
+
+// maxPowBits bounds the size of the results of pow
+const maxPowBits = 1 << 20
+
+func init() {
+	register(map[string]*object.Builtin{
+		"abs": {
+			Doc: "abs(n) returns the absolute value of n",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkIntegers("abs", args, 1, 1); err != nil {
+					return err
+				}
+				return fromBig(toBig(args[0]).Abs(toBig(args[0])))
+			},
+		},
+		"min": {
+			Doc: "min(n, ...) returns the smallest of the integers, or of the elements of an array when it is the only argument",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				return extremum("min", args, -1)
+			},
+		},
+		"max": {
+			Doc: "max(n, ...) returns the largest of the integers, or of the elements of an array when it is the only argument",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				return extremum("max", args, 1)
+			},
+		},
+		"pow": {
+			Doc: "pow(base, exp) returns base raised to the non negative exp",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkIntegers("pow", args, 2, 2); err != nil {
+					return err
+				}
+				exp := toBig(args[1])
+				if exp.Sign() < 0 {
+					return newError("argument 2 to `pow` must not be negative, got %s", exp)
+				}
+				base := toBig(args[0])
+				// |base| > 1 has a result of at least exp * (bits of |base| - 1) bits
+				if bits := int64(base.BitLen() - 1); bits > 0 &&
+					(!exp.IsInt64() || exp.Int64() > maxPowBits/bits) {
+					return newError("result of `pow` larger than %d bits", maxPowBits)
+				}
+				return fromBig(new(big.Int).Exp(base, exp, nil))
+			},
+		},
+		"sqrt": {
+			Doc: "sqrt(n) returns the square root of the non negative n, rounded down",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkIntegers("sqrt", args, 1, 1); err != nil {
+					return err
+				}
+				n := toBig(args[0])
+				if n.Sign() < 0 {
+					return newError("argument to `sqrt` must not be negative, got %s", n)
+				}
+				return fromBig(n.Sqrt(n))
+			},
+		},
+		"floor": {
+			Doc: "floor(n, d?) returns n divided by d, 1 by default, rounded down",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				return roundedDivision("floor", args, false)
+			},
+		},
+		"ceil": {
+			Doc: "ceil(n, d?) returns n divided by d, 1 by default, rounded up",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				return roundedDivision("ceil", args, true)
+			},
+		},
+		"divmod": {
+			Doc: "divmod(a, b) returns [a / b, the remainder], the quotient truncated toward zero as with /",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkIntegers("divmod", args, 2, 2); err != nil {
+					return err
+				}
+				a, b := toBig(args[0]), toBig(args[1])
+				if b.Sign() == 0 {
+					return newError("division by zero")
+				}
+				q, r := new(big.Int).QuoRem(a, b, new(big.Int))
+				return object.NewArray([]object.Object{fromBig(q), fromBig(r)})
+			},
+		},
+		"random": {
+			Doc: "random(n?), random(lo, hi) returns a pseudo-random integer in [0, n), in [lo, hi), or a non negative one without arguments",
+			Fn: func(caller object.Caller, args ...object.Object) object.Object {
+				random := randomOf(caller)
+				if err := checkOptionalArgs("random", args, 0, object.INTEGER, object.INTEGER); err != nil {
+					return err
+				}
+				var lo, hi int64
+				switch len(args) {
+				case 0:
+					return &object.Integer{Value: random.int63n(0)}
+				case 1:
+					hi = args[0].(*object.Integer).Value
+				case 2:
+					lo, hi = args[0].(*object.Integer).Value, args[1].(*object.Integer).Value
+				}
+				if hi <= lo {
+					return newError("empty range of `random`: [%d, %d)", lo, hi)
+				}
+				span := new(big.Int).Sub(big.NewInt(hi), big.NewInt(lo))
+				if !span.IsInt64() {
+					return newError("range of `random` too large: [%d, %d)", lo, hi)
+				}
+				return &object.Integer{Value: lo + random.int63n(span.Int64())}
+			},
+		},
+		"seed": {
+			Doc: "seed(n) seeds the source of random of the program, the same seed makes random return the same numbers",
+			Fn: func(caller object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("seed", args, object.INTEGER); err != nil {
+					return err
+				}
+				randomOf(caller).seed(args[0].(*object.Integer).Value)
+				return nullObj
+			},
+		},
+	})
+}
+
+// Random is the source of the random built-in function, the functions
+// spawned by a program share the source of the program
+type Random struct {
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// NewRandom returns a source seeded with seed, seed and random calls of the
+// same program then return the same numbers
+func NewRandom(seed int64) *Random {
+	return &Random{rand: rand.New(rand.NewSource(seed))}
+}
+
+// randomOf returns the source of the evaluator calling a built-in function,
+// which is seeded with the time if the evaluator had none
+func randomOf(caller object.Caller) *Random {
+	e, ok := caller.(*Evaluator)
+	if !ok {
+		return NewRandom(time.Now().UnixNano())
+	}
+	if e.Random == nil {
+		e.Random = NewRandom(time.Now().UnixNano())
+	}
+	return e.Random
+}
+
+func (r *Random) seed(seed int64) {
+	r.mu.Lock()
+	r.rand.Seed(seed)
+	r.mu.Unlock()
+}
+
+// int63n returns an integer in [0, n), or a non negative integer when n is 0
+func (r *Random) int63n(n int64) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if n == 0 {
+		return r.rand.Int63()
+	}
+	return r.rand.Int63n(n)
+}
+
+// checkIntegers returns an error unless args are from min to max integers
+func checkIntegers(name string, args []object.Object, min, max int) *object.Error {
+	if len(args) < min || len(args) > max {
+		if min == max {
+			return newError("wrong number of arguments. got=%d, want=%d", len(args), min)
+		}
+		return newError("wrong number of arguments. got=%d, want=%d or %d", len(args), min, max)
+	}
+	for i, arg := range args {
+		if !isInteger(arg) {
+			return typeError(name, i, object.INTEGER, arg)
+		}
+	}
+	return nil
+}
+
+// extremum implements min, when sign is -1, and max
+func extremum(name string, args []object.Object, sign int) object.Object {
+	if len(args) == 1 {
+		if arr, ok := args[0].(*object.Array); ok {
+			if arr.Len() == 0 {
+				return newError("argument to `%s` must not be empty", name)
+			}
+			for _, el := range arr.Elements() {
+				if !isInteger(el) {
+					return newError("elements of argument to `%s` must be INTEGER, got %s",
+						name, el.Type())
+				}
+			}
+			args = arr.Elements()
+		}
+	}
+	if len(args) == 0 {
+		return newError("wrong number of arguments. got=0, want=1 or more")
+	}
+	if err := checkIntegers(name, args, len(args), len(args)); err != nil {
+		return err
+	}
+	result := args[0]
+	for _, arg := range args[1:] {
+		if toBig(arg).Cmp(toBig(result)) == sign {
+			result = arg
+		}
+	}
+	return result
+}
+
+// roundedDivision implements floor and ceil, when up is true
+func roundedDivision(name string, args []object.Object, up bool) object.Object {
+	if err := checkIntegers(name, args, 1, 2); err != nil {
+		return err
+	}
+	if len(args) == 1 {
+		return args[0]
+	}
+	a, b := toBig(args[0]), toBig(args[1])
+	if b.Sign() == 0 {
+		return newError("division by zero")
+	}
+	q, r := new(big.Int).QuoRem(a, b, new(big.Int))
+	if r.Sign() != 0 {
+		inexactUp := r.Sign() == b.Sign() // the exact quotient is above q
+		if up && inexactUp {
+			q.Add(q, big.NewInt(1))
+		} else if !up && !inexactUp {
+			q.Sub(q, big.NewInt(1))
+		}
+	}
+	return fromBig(q)
+}
@@ -0,0 +1,164 @@
+package evaluator
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"sort"
+
+	"github.com/lycheng/monkey-go/object"
+)
+
+// The functions below reach the operating system, each access is checked
+// against the policy of the evaluator and denied without one
+
+func init() {
+	register(map[string]*object.Builtin{
+		"read_file": {
+			Doc: "read_file(path) returns the content of the file at path",
+			Fn: func(caller object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("read_file", args, object.STRING); err != nil {
+					return err
+				}
+				path, err := policyOf(caller).checkPath("read_file", args[0].(*object.String).Value, false)
+				if err != nil {
+					return err
+				}
+				content, ioErr := os.ReadFile(path)
+				if ioErr != nil {
+					return osError("read_file", ioErr)
+				}
+				return &object.String{Value: string(content)}
+			},
+		},
+		"write_file": {
+			Doc: "write_file(path, str) writes str to the file at path, replacing its content",
+			Fn: func(caller object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("write_file", args, object.STRING, object.STRING); err != nil {
+					return err
+				}
+				path, err := policyOf(caller).checkPath("write_file", args[0].(*object.String).Value, true)
+				if err != nil {
+					return err
+				}
+				if ioErr := os.WriteFile(path, []byte(args[1].(*object.String).Value), 0o666); ioErr != nil {
+					return osError("write_file", ioErr)
+				}
+				return nullObj
+			},
+		},
+		"list_dir": {
+			Doc: "list_dir(path) returns the sorted names of the entries of the directory at path",
+			Fn: func(caller object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("list_dir", args, object.STRING); err != nil {
+					return err
+				}
+				path, err := policyOf(caller).checkPath("list_dir", args[0].(*object.String).Value, false)
+				if err != nil {
+					return err
+				}
+				entries, ioErr := os.ReadDir(path)
+				if ioErr != nil {
+					return osError("list_dir", ioErr)
+				}
+				names := make([]string, len(entries))
+				for i, entry := range entries {
+					names[i] = entry.Name()
+				}
+				sort.Strings(names)
+				return stringArray(names)
+			},
+		},
+		"exists": {
+			Doc: "exists(path) returns whether a file or directory exists at path",
+			Fn: func(caller object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("exists", args, object.STRING); err != nil {
+					return err
+				}
+				path, err := policyOf(caller).checkPath("exists", args[0].(*object.String).Value, false)
+				if err != nil {
+					return err
+				}
+				_, ioErr := os.Stat(path)
+				return nativeBoolToBooleanObject(ioErr == nil)
+			},
+		},
+		"getenv": {
+			Doc: "getenv(name) returns the value of the environment variable name, or null when it is not set",
+			Fn: func(caller object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("getenv", args, object.STRING); err != nil {
+					return err
+				}
+				name := args[0].(*object.String).Value
+				if err := policyOf(caller).checkEnv("getenv", name); err != nil {
+					return err
+				}
+				if value, ok := os.LookupEnv(name); ok {
+					return &object.String{Value: value}
+				}
+				return nullObj
+			},
+		},
+		"exec": {
+			Doc: `exec(command, args?) runs command with the array of string args and returns {"status": exit status, "stdout": output, "stderr": error output}`,
+			Fn: func(caller object.Caller, args ...object.Object) object.Object {
+				if err := checkOptionalArgs("exec", args, 1, object.STRING, object.ARRAY); err != nil {
+					return err
+				}
+				name := args[0].(*object.String).Value
+				if err := policyOf(caller).checkCommand("exec", name); err != nil {
+					return err
+				}
+				var cmdArgs []string
+				if len(args) == 2 {
+					for _, el := range args[1].(*object.Array).Elements() {
+						str, ok := el.(*object.String)
+						if !ok {
+							return newError("elements of argument 2 to `exec` must be STRING, got %s",
+								el.Type())
+						}
+						cmdArgs = append(cmdArgs, str.Value)
+					}
+				}
+
+				// the command is killed when the evaluation is cancelled
+				ctx := contextOf(caller)
+				var stdout, stderr bytes.Buffer
+				cmd := exec.CommandContext(ctx, name, cmdArgs...)
+				cmd.Stdout, cmd.Stderr = &stdout, &stderr
+				status := 0
+				if err := cmd.Run(); err != nil {
+					if ctx.Err() != nil {
+						return newError("exec: %s", ctx.Err())
+					}
+					var exitErr *exec.ExitError
+					if !errors.As(err, &exitErr) {
+						return osError("exec", err)
+					}
+					status = exitErr.ExitCode()
+				}
+				return stringHash(map[string]object.Object{
+					"status": &object.Integer{Value: int64(status)},
+					"stdout": &object.String{Value: stdout.String()},
+					"stderr": &object.String{Value: stderr.String()},
+				})
+			},
+		},
+	})
+}
+
+// osError returns the error of a failed access to the operating system
+func osError(name string, err error) *object.Error {
+	return newError("%s: %s", name, err)
+}
+
+// stringHash returns a hash of the values keyed by strings
+func stringHash(values map[string]object.Object) *object.Hash {
+	hash := &object.Hash{}
+	for k, v := range values {
+		key := &object.String{Value: k}
+		hash = hash.Set(key.HashKey(), object.HashPair{Key: key, Val: v})
+	}
+	return hash
+}
@@ -0,0 +1,221 @@
+package evaluator
+
+import (
+	"regexp"
+	"sync"
+
+	"github.com/lycheng/monkey-go/object"
+)
+
+// The regex arguments of the functions below are regexes or patterns,
+// patterns are compiled once and kept in a cache
+
+func init() {
+	register(map[string]*object.Builtin{
+		"regex": {
+			Doc: "regex(pattern) returns the regex of pattern, in the RE2 syntax",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("regex", args, object.STRING); err != nil {
+					return err
+				}
+				re, err := compileRegex(args[0].(*object.String).Value)
+				if err != nil {
+					return newError("regex: %s", err)
+				}
+				return &object.Regex{Regexp: re}
+			},
+		},
+		"match": {
+			Doc: "match(regex, str) returns the array of the leftmost match of regex in str followed by its groups, null for the groups not matched, or null without match",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				re, str, err := regexArgs("match", args)
+				if err != nil {
+					return err
+				}
+				groups := re.FindStringSubmatchIndex(str)
+				if groups == nil {
+					return nullObj
+				}
+				return submatches(str, groups)
+			},
+		},
+		"captures": {
+			Doc: "captures(regex, str) returns the hash of the named groups of the leftmost match of regex in str, or null without match",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				re, str, err := regexArgs("captures", args)
+				if err != nil {
+					return err
+				}
+				groups := re.FindStringSubmatchIndex(str)
+				if groups == nil {
+					return nullObj
+				}
+				matched := submatches(str, groups).Elements()
+				hash := &object.Hash{}
+				for i, name := range re.SubexpNames() {
+					if name == "" {
+						continue
+					}
+					key := &object.String{Value: name}
+					hash = hash.Set(key.HashKey(), object.HashPair{Key: key, Val: matched[i]})
+				}
+				return hash
+			},
+		},
+		"find_all": {
+			Doc: "find_all(regex, str, n?) returns the array of the matches of regex in str, at most n, as arrays of the match and its groups when regex has groups",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				re, str, err := regexArgs("find_all", args, object.INTEGER)
+				if err != nil {
+					return err
+				}
+				n := -1
+				if len(args) == 3 {
+					n = int(args[2].(*object.Integer).Value)
+				}
+				found := []object.Object{}
+				for _, groups := range re.FindAllStringSubmatchIndex(str, n) {
+					if re.NumSubexp() == 0 {
+						found = append(found, &object.String{Value: str[groups[0]:groups[1]]})
+					} else {
+						found = append(found, submatches(str, groups))
+					}
+				}
+				return object.NewArray(found)
+			},
+		},
+		"replace_regex": {
+			Doc: "replace_regex(regex, str, repl) returns str with the matches of regex replaced by repl, a string where $1 or ${name} stand for the groups, or a function called with the array of the match and its groups",
+			Fn: func(caller object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("replace_regex", args, "", object.STRING, ""); err != nil {
+					return err
+				}
+				re, err := regexArg("replace_regex", args[0])
+				if err != nil {
+					return err
+				}
+				str := args[1].(*object.String).Value
+				switch repl := args[2].(type) {
+				case *object.String:
+					return &object.String{Value: re.ReplaceAllString(str, repl.Value)}
+				case *object.Function, *object.Builtin:
+					return replaceRegexFunc(caller, re, str, repl)
+				default:
+					return newError("argument 3 to `replace_regex` must be STRING or FUNCTION, got %s",
+						repl.Type())
+				}
+			},
+		},
+		"split_regex": {
+			Doc: "split_regex(regex, str, n?) returns the array of the parts of str between the matches of regex, at most n",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				re, str, err := regexArgs("split_regex", args, object.INTEGER)
+				if err != nil {
+					return err
+				}
+				n := -1
+				if len(args) == 3 {
+					n = int(args[2].(*object.Integer).Value)
+				}
+				return stringArray(re.Split(str, n))
+			},
+		},
+	})
+}
+
+// maxCachedRegexes bounds the cache of compiled patterns, it is emptied
+// when full
+const maxCachedRegexes = 256
+
+var regexCache = struct {
+	sync.Mutex
+	regexps map[string]*regexp.Regexp
+}{regexps: make(map[string]*regexp.Regexp)}
+
+// compileRegex returns the compiled pattern from the cache, compiling it
+// when missing
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	regexCache.Lock()
+	defer regexCache.Unlock()
+	if re, ok := regexCache.regexps[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(regexCache.regexps) >= maxCachedRegexes {
+		regexCache.regexps = make(map[string]*regexp.Regexp)
+	}
+	regexCache.regexps[pattern] = re
+	return re, nil
+}
+
+// regexArg returns the regex of the first argument of a built-in function,
+// a regex or a pattern
+func regexArg(name string, arg object.Object) (*regexp.Regexp, *object.Error) {
+	switch arg := arg.(type) {
+	case *object.Regex:
+		return arg.Regexp, nil
+	case *object.String:
+		re, err := compileRegex(arg.Value)
+		if err != nil {
+			return nil, newError("%s: %s", name, err)
+		}
+		return re, nil
+	default:
+		return nil, newError("argument to `%s` must be REGEX or STRING, got %s", name, arg.Type())
+	}
+}
+
+// regexArgs checks the arguments of a built-in function taking a regex, a
+// string and the optional arguments of the types
+func regexArgs(name string, args []object.Object, optional ...object.Type) (*regexp.Regexp, string, *object.Error) {
+	types := append([]object.Type{"", object.STRING}, optional...)
+	if err := checkOptionalArgs(name, args, 2, types...); err != nil {
+		return nil, "", err
+	}
+	re, err := regexArg(name, args[0])
+	if err != nil {
+		return nil, "", err
+	}
+	return re, args[1].(*object.String).Value, nil
+}
+
+// submatches returns the array of the strings of str at the index pairs of
+// groups, null for the pairs of groups not matched
+func submatches(str string, groups []int) *object.Array {
+	elements := make([]object.Object, len(groups)/2)
+	for i := range elements {
+		start, end := groups[2*i], groups[2*i+1]
+		if start < 0 {
+			elements[i] = nullObj
+		} else {
+			elements[i] = &object.String{Value: str[start:end]}
+		}
+	}
+	return object.NewArray(elements)
+}
+
+// replaceRegexFunc replaces the matches of re in str by the strings
+// returned by fn
+func replaceRegexFunc(caller object.Caller, re *regexp.Regexp, str string, fn object.Object) object.Object {
+	var out []byte
+	last := 0
+	for _, groups := range re.FindAllStringSubmatchIndex(str, -1) {
+		result := caller.Call(fn, submatches(str, groups))
+		if isError(result) {
+			return result
+		}
+		repl, ok := result.(*object.String)
+		if !ok {
+			return newError("function passed to `replace_regex` must return STRING, got %s",
+				result.Type())
+		}
+		out = append(out, str[last:groups[0]]...)
+		out = append(out, repl.Value...)
+		last = groups[1]
+	}
+	out = append(out, str[last:]...)
+	return &object.String{Value: string(out)}
+}
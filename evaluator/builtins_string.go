@@ -0,0 +1,214 @@
+package evaluator
+
+import (
+	"strings"
+
+	"github.com/lycheng/monkey-go/object"
+)
+
+// Indexes into strings count characters, not bytes
+
+// maxRepeatLen bounds the length in bytes of the strings built by repeat
+const maxRepeatLen = 1 << 30
+
+func init() {
+	register(map[string]*object.Builtin{
+		"split": {
+			Doc: "split(str, sep?) returns the array of the parts of str between the separators, or between runs of white space without sep",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkOptionalArgs("split", args, 1, object.STRING, object.STRING); err != nil {
+					return err
+				}
+				str := args[0].(*object.String).Value
+				if len(args) == 1 {
+					return stringArray(strings.Fields(str))
+				}
+				return stringArray(strings.Split(str, args[1].(*object.String).Value))
+			},
+		},
+		"join": {
+			Doc: "join(array, sep) returns the strings of array concatenated with sep between them",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("join", args, object.ARRAY, object.STRING); err != nil {
+					return err
+				}
+				elements := args[0].(*object.Array).Elements()
+				parts := make([]string, len(elements))
+				for i, el := range elements {
+					str, ok := el.(*object.String)
+					if !ok {
+						return newError("elements of argument to `join` must be STRING, got %s",
+							el.Type())
+					}
+					parts[i] = str.Value
+				}
+				return &object.String{Value: strings.Join(parts, args[1].(*object.String).Value)}
+			},
+		},
+		"trim": {
+			Doc: "trim(str, cutset?) returns str without the leading and trailing white space, or characters of cutset",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkOptionalArgs("trim", args, 1, object.STRING, object.STRING); err != nil {
+					return err
+				}
+				str := args[0].(*object.String).Value
+				if len(args) == 1 {
+					return &object.String{Value: strings.TrimSpace(str)}
+				}
+				return &object.String{Value: strings.Trim(str, args[1].(*object.String).Value)}
+			},
+		},
+		"upper": {
+			Doc: "upper(str) returns str with all letters in upper case",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("upper", args, object.STRING); err != nil {
+					return err
+				}
+				return &object.String{Value: strings.ToUpper(args[0].(*object.String).Value)}
+			},
+		},
+		"lower": {
+			Doc: "lower(str) returns str with all letters in lower case",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("lower", args, object.STRING); err != nil {
+					return err
+				}
+				return &object.String{Value: strings.ToLower(args[0].(*object.String).Value)}
+			},
+		},
+		"replace": {
+			Doc: "replace(str, old, new) returns str with every occurrence of old replaced by new",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("replace", args, object.STRING, object.STRING, object.STRING); err != nil {
+					return err
+				}
+				return &object.String{Value: strings.ReplaceAll(
+					args[0].(*object.String).Value,
+					args[1].(*object.String).Value,
+					args[2].(*object.String).Value,
+				)}
+			},
+		},
+		"contains": {
+			Doc: "contains(str, sub) reports whether sub is within str",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("contains", args, object.STRING, object.STRING); err != nil {
+					return err
+				}
+				return nativeBoolToBooleanObject(strings.Contains(
+					args[0].(*object.String).Value, args[1].(*object.String).Value))
+			},
+		},
+		"starts_with": {
+			Doc: "starts_with(str, prefix) reports whether str begins with prefix",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("starts_with", args, object.STRING, object.STRING); err != nil {
+					return err
+				}
+				return nativeBoolToBooleanObject(strings.HasPrefix(
+					args[0].(*object.String).Value, args[1].(*object.String).Value))
+			},
+		},
+		"ends_with": {
+			Doc: "ends_with(str, suffix) reports whether str ends with suffix",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("ends_with", args, object.STRING, object.STRING); err != nil {
+					return err
+				}
+				return nativeBoolToBooleanObject(strings.HasSuffix(
+					args[0].(*object.String).Value, args[1].(*object.String).Value))
+			},
+		},
+		"index_of": {
+			Doc: "index_of(str, sub) returns the index of the first character of sub in str, or -1 if str doesn't contain sub",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("index_of", args, object.STRING, object.STRING); err != nil {
+					return err
+				}
+				str := args[0].(*object.String).Value
+				i := strings.Index(str, args[1].(*object.String).Value)
+				if i > 0 {
+					i = len([]rune(str[:i]))
+				}
+				return &object.Integer{Value: int64(i)}
+			},
+		},
+		"repeat": {
+			Doc: "repeat(str, n) returns n copies of str concatenated",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("repeat", args, object.STRING, object.INTEGER); err != nil {
+					return err
+				}
+				n := args[1].(*object.Integer).Value
+				if n < 0 {
+					return newError("argument 2 to `repeat` must not be negative, got %d", n)
+				}
+				str := args[0].(*object.String).Value
+				if len(str) != 0 && n > maxRepeatLen/int64(len(str)) {
+					return newError("result of `repeat` longer than %d bytes", maxRepeatLen)
+				}
+				return &object.String{Value: strings.Repeat(str, int(n))}
+			},
+		},
+		"chars": {
+			Doc: "chars(str) returns the array of the characters of str",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("chars", args, object.STRING); err != nil {
+					return err
+				}
+				runes := []rune(args[0].(*object.String).Value)
+				elements := make([]object.Object, len(runes))
+				for i, r := range runes {
+					elements[i] = &object.String{Value: string(r)}
+				}
+				return object.NewArray(elements)
+			},
+		},
+		"substring": {
+			Doc: "substring(str, start, end?) returns the characters of str from start up to end, or the end of str; negative indexes count from the end",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkOptionalArgs("substring", args, 2, object.STRING, object.INTEGER, object.INTEGER); err != nil {
+					return err
+				}
+				runes := []rune(args[0].(*object.String).Value)
+				last := int64(len(runes))
+				if len(args) == 3 {
+					last = args[2].(*object.Integer).Value
+				}
+				start, end := sliceBounds(args[1].(*object.Integer).Value, last, len(runes))
+				return &object.String{Value: string(runes[start:end])}
+			},
+		},
+	})
+}
+
+// stringArray returns an array of the strings
+func stringArray(strs []string) *object.Array {
+	elements := make([]object.Object, len(strs))
+	for i, s := range strs {
+		elements[i] = &object.String{Value: s}
+	}
+	return object.NewArray(elements)
+}
+
+// sliceBounds clamps the indexes of a slice of a sequence of length n,
+// negative indexes count from the end
+func sliceBounds(start, end int64, n int) (int, int) {
+	clamp := func(i int64) int {
+		if i < 0 {
+			i += int64(n)
+		}
+		if i < 0 {
+			return 0
+		}
+		if i > int64(n) {
+			return n
+		}
+		return int(i)
+	}
+	s, e := clamp(start), clamp(end)
+	if e < s {
+		e = s
+	}
+	return s, e
+}
@@ -0,0 +1,126 @@
+package evaluator
+
+import (
+	"strings"
+	"time"
+	_ "time/tzdata" // time zones don't depend on the system database
+
+	"github.com/lycheng/monkey-go/object"
+)
+
+// Layouts are Go reference layouts, like "2006-01-02 15:04", or the names
+// of the layouts below. Times are read and written in RFC 3339 by default.
+var timeLayouts = map[string]string{
+	"RFC3339":  time.RFC3339Nano,
+	"RFC1123":  time.RFC1123,
+	"RFC822":   time.RFC822,
+	"date":     "2006-01-02",
+	"datetime": "2006-01-02 15:04:05",
+	"kitchen":  time.Kitchen,
+}
+
+func init() {
+	register(map[string]*object.Builtin{
+		"now": {
+			Doc: "now() returns the current time",
+			Fn: func(caller object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("now", args); err != nil {
+					return err
+				}
+				return &object.Time{Value: clockOf(caller).Now()}
+			},
+		},
+		"parse_time": {
+			Doc: "parse_time(str, layout?) returns the time written in str with layout, RFC 3339 by default, in UTC unless str has a time zone",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkOptionalArgs("parse_time", args, 1, object.STRING, object.STRING); err != nil {
+					return err
+				}
+				t, err := time.Parse(layoutArg(args, 1), args[0].(*object.String).Value)
+				if err != nil {
+					return newError("parse_time: %s", err)
+				}
+				return &object.Time{Value: t}
+			},
+		},
+		"format_time": {
+			Doc: "format_time(time, layout?) returns time written with layout, RFC 3339 by default",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkOptionalArgs("format_time", args, 1, object.TIME, object.STRING); err != nil {
+					return err
+				}
+				t := args[0].(*object.Time).Value
+				return &object.String{Value: t.Format(layoutArg(args, 1))}
+			},
+		},
+		"in_zone": {
+			Doc: `in_zone(time, zone) returns time in the IANA time zone, like "Europe/Paris", or "UTC" or "Local"`,
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("in_zone", args, object.TIME, object.STRING); err != nil {
+					return err
+				}
+				loc, err := time.LoadLocation(args[1].(*object.String).Value)
+				if err != nil {
+					return newError("in_zone: %s", err)
+				}
+				return &object.Time{Value: args[0].(*object.Time).Value.In(loc)}
+			},
+		},
+		"unix": {
+			Doc: "unix(time) returns the number of seconds elapsed from January 1, 1970 UTC to time",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("unix", args, object.TIME); err != nil {
+					return err
+				}
+				return &object.Integer{Value: args[0].(*object.Time).Value.Unix()}
+			},
+		},
+		"from_unix": {
+			Doc: "from_unix(seconds) returns the time the number of seconds after January 1, 1970 UTC",
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("from_unix", args, object.INTEGER); err != nil {
+					return err
+				}
+				return &object.Time{Value: time.Unix(args[0].(*object.Integer).Value, 0).UTC()}
+			},
+		},
+		"duration": {
+			Doc: `duration(str) returns the duration written in str, like "1h30m" or "-1.5s", with the units "ns", "us", "ms", "s", "m" and "h"`,
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("duration", args, object.STRING); err != nil {
+					return err
+				}
+				d, err := time.ParseDuration(args[0].(*object.String).Value)
+				if err != nil {
+					return newError("duration: %s", strings.TrimPrefix(err.Error(), "time: "))
+				}
+				return &object.Duration{Value: d}
+			},
+		},
+		"sleep": {
+			Doc: "sleep(duration) waits for duration, unless the evaluation is cancelled first",
+			Fn: func(caller object.Caller, args ...object.Object) object.Object {
+				if err := checkArgs("sleep", args, object.DURATION); err != nil {
+					return err
+				}
+				err := clockOf(caller).Sleep(contextOf(caller), args[0].(*object.Duration).Value)
+				if err != nil {
+					return newError("sleep: %s", err)
+				}
+				return nullObj
+			},
+		},
+	})
+}
+
+// layoutArg returns the layout argument i, RFC 3339 when it is left out
+func layoutArg(args []object.Object, i int) string {
+	if len(args) <= i {
+		return time.RFC3339Nano
+	}
+	layout := args[i].(*object.String).Value
+	if named, ok := timeLayouts[layout]; ok {
+		return named
+	}
+	return layout
+}
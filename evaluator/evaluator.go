@@ -2,6 +2,8 @@ package evaluator
 
 import (
 	"fmt"
+	"math"
+	"math/big"
 
 	"github.com/lycheng/monkey-go/ast"
 	"github.com/lycheng/monkey-go/object"
@@ -33,16 +35,36 @@ func isError(obj object.Object) bool {
 
 // Eval returns the object of the AST node
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	return New().Eval(node, env)
+}
+
+// Eval returns the object of the AST node, the program frame is pushed on
+// the call stack when the stack is empty
+func (e *Evaluator) Eval(node ast.Node, env *object.Environment) object.Object {
+	if len(e.frames) != 0 {
+		return e.eval(node, env)
+	}
+	e.stopped = nil
+	frame := e.push(&Frame{Env: env, Pos: node.Pos()})
+	result := e.eval(node, env)
+	e.pop(frame, result)
+	return result
+}
 
+func (e *Evaluator) eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.LetStatement:
-		val := Eval(node.Value, env)
+		val := e.eval(node.Value, env)
 		if isError(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
+		bind(node.Name, val, env)
+	case *ast.FunctionStatement:
+		// bound by declareFunctions when its block was entered, the
+		// declaration evaluates to the function
+		return evalIdentifier(node.Function.Name, env)
 	case *ast.Identifier:
-		return evalIdentifier(node, env)
+		return e.located(evalIdentifier(node, env), node)
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
 	case *ast.Boolean:
@@ -50,91 +72,119 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 	case *ast.ArrayLiteral:
-		elements := evalExpressions(node.Elements, env)
+		elements := e.evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
 		}
-		return &object.Array{Elements: elements}
+		return object.NewArray(elements)
 	case *ast.HashLiteral:
-		return evalHashLiteral(node, env)
+		return e.located(e.evalHashLiteral(node, env), node)
 	case *ast.IndexExpression:
-		left := Eval(node.Left, env)
+		left := e.eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
-		index := Eval(node.Index, env)
+		index := e.eval(node.Index, env)
 		if isError(index) {
 			return index
 		}
-		return evalIndexExpression(left, index)
+		return e.located(evalIndexExpression(left, index), node)
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
+		val := e.eval(node.ReturnValue, env)
 		if isError(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
 	case *ast.PrefixExpression:
 		{
-			right := Eval(node.Right, env)
+			right := e.eval(node.Right, env)
 			if isError(right) {
 				return right
 			}
-			return evalPrefixExpression(node.Operator, right)
+			return e.located(evalPrefixExpression(node.Operator, right), node)
 		}
 	case *ast.InfixExpression:
 		{
-			left := Eval(node.Left, env)
+			left := e.eval(node.Left, env)
 			if isError(left) {
 				return left
 			}
-			right := Eval(node.Right, env)
+			right := e.eval(node.Right, env)
 			if isError(right) {
 				return right
 			}
-			return evalInfixExpression(node.Operator, left, right)
+			return e.located(evalInfixExpression(node.Operator, left, right), node)
 		}
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return e.eval(node.Expression, env)
 	case *ast.BlockStatement:
-		return evalBlockStatements(node, env)
+		return e.evalBlockStatements(node, env)
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return e.evalIfExpression(node, env)
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		fn := &object.Function{Parameters: params, Env: env, Body: body, Locals: node.Locals}
+		if node.Name != nil {
+			fn.Name = node.Name.Value
+		}
+		return fn
 	case *ast.CallExpression:
-		fn := Eval(node.Function, env)
+		fn := e.eval(node.Function, env)
 		if isError(fn) {
 			return fn
 		}
 
-		args := evalExpressions(node.Arguments, env)
+		args := e.evalExpressions(node.Arguments, env)
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
 
-		return applyFunction(fn, args)
+		return e.located(e.applyFunction(fn, args, node), node)
 	case *ast.Program:
-		return evalProgram(node, env)
+		return e.evalProgram(node, env)
 	}
 	return nil
 }
 
-func evalBlockStatements(block *ast.BlockStatement, env *object.Environment) (result object.Object) {
+// bind binds the name of a let statement or of a declared function
+func bind(name *ast.Identifier, val object.Object, env *object.Environment) {
+	if name.Local {
+		env.SetSlot(name.Slot, val)
+	} else {
+		env.Set(name.Value, val)
+	}
+}
+
+// declareFunctions binds the functions declared by the statements of a
+// block, before the statements are evaluated, so they can call each other
+// wherever they are declared in the block
+func (e *Evaluator) declareFunctions(stmts []ast.Statement, env *object.Environment) {
+	for _, stmt := range stmts {
+		if fs, ok := stmt.(*ast.FunctionStatement); ok {
+			bind(fs.Function.Name, e.eval(fs.Function, env), env)
+		}
+	}
+}
+
+func (e *Evaluator) evalBlockStatements(block *ast.BlockStatement, env *object.Environment) (result object.Object) {
+	e.declareFunctions(block.Statements, env)
 	for _, statement := range block.Statements {
-		result = Eval(statement, env)
+		if err := e.before(statement, env); err != nil {
+			return err
+		}
+		result = e.eval(statement, env)
 
-		if result.Type() == object.RETURNVALUE || result.Type() == object.ERROR {
+		if result != nil && (result.Type() == object.RETURNVALUE || result.Type() == object.ERROR) {
 			return result
 		}
 	}
 	return result
 }
 
-func evalStatements(stmts []ast.Statement, env *object.Environment) (result object.Object) {
+func (e *Evaluator) evalStatements(stmts []ast.Statement, env *object.Environment) (result object.Object) {
 	for _, statement := range stmts {
-		result = Eval(statement, env)
+		result = e.eval(statement, env)
 
 		switch rv := result.(type) {
 		case *object.ReturnValue:
@@ -146,10 +196,10 @@ func evalStatements(stmts []ast.Statement, env *object.Environment) (result obje
 	return result
 }
 
-func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+func (e *Evaluator) evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
-	for _, e := range exps {
-		evaluated := Eval(e, env)
+	for _, exp := range exps {
+		evaluated := e.eval(exp, env)
 		if isError(evaluated) {
 			return []object.Object{evaluated}
 		}
@@ -158,9 +208,13 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return result
 }
 
-func evalProgram(program *ast.Program, env *object.Environment) (result object.Object) {
+func (e *Evaluator) evalProgram(program *ast.Program, env *object.Environment) (result object.Object) {
+	e.declareFunctions(program.Statements, env)
 	for _, statement := range program.Statements {
-		result = Eval(statement, env)
+		if err := e.before(statement, env); err != nil {
+			return err
+		}
+		result = e.eval(statement, env)
 
 		switch rv := result.(type) {
 		case *object.ReturnValue:
@@ -197,20 +251,27 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER {
+	if !isInteger(right) {
 		return newError("unknown operator: -%s", right.Type())
 	}
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
+	if value, ok := right.(*object.Integer); ok && value.Value != math.MinInt64 {
+		return &object.Integer{Value: -value.Value}
+	}
+	return fromBig(new(big.Int).Neg(toBig(right)))
 }
 
 func evalInfixExpression(
 	operator string,
 	left, right object.Object,
 ) object.Object {
+	if result, ok := evalTimeInfixExpression(operator, left, right); ok {
+		return result
+	}
 	switch {
 	case left.Type() == object.INTEGER && right.Type() == object.INTEGER:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isInteger(left) && isInteger(right):
+		return evalBigIntegerInfixExpression(operator, left, right)
 	case left.Type() == object.STRING && right.Type() == object.STRING:
 		return evalStringInfixExpression(operator, left, right)
 	case operator == "==":
@@ -231,14 +292,8 @@ func evalIntegerInfixExpression(
 	leftVal := left.(*object.Integer).Value
 	rightVal := right.(*object.Integer).Value
 	switch operator {
-	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
-	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
-	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
-	case "/":
-		return &object.Integer{Value: leftVal / rightVal}
+	case "+", "-", "*", "/":
+		return evalIntegerArithmetic(operator, leftVal, rightVal)
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
@@ -278,21 +333,29 @@ func isTruthy(obj object.Object) bool {
 	}
 }
 
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
-	condition := Eval(ie.Condition, env)
+func (e *Evaluator) evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := e.eval(ie.Condition, env)
 	if isError(condition) {
 		return condition
 	}
-	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
+	truthy := isTruthy(condition)
+	if e.Coverage != nil {
+		e.Coverage.Branch(ie, truthy)
+	}
+	if truthy {
+		return e.eval(ie.Consequence, env)
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+		return e.eval(ie.Alternative, env)
 	}
 	return nullObj
 }
 
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
-	if val, ok := env.Get(node.Value); ok {
+	if node.Local {
+		if val, ok := env.GetSlot(node.Depth, node.Slot, node.Value); ok {
+			return val
+		}
+	} else if val, ok := env.Get(node.Value); ok {
 		return val
 	}
 
@@ -302,21 +365,64 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 	return newError("identifier not found: " + node.Value)
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+func (e *Evaluator) applyFunction(fn object.Object, args []object.Object, call *ast.CallExpression) object.Object {
 
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		// the calls in tail position are returned by the body and made by
+		// this loop, in place of the frame of the function calling them
+		for {
+			if len(args) != len(fn.Parameters) {
+				err := newError("wrong number of arguments. got=%d, want=%d",
+					len(args), len(fn.Parameters))
+				if fn.Name != "" {
+					err = newError("wrong number of arguments to %s. got=%d, want=%d",
+						fn.Name, len(args), len(fn.Parameters))
+				}
+				if call != nil {
+					return e.located(err, call)
+				}
+				return err
+			}
+			extendedEnv := extendFunctionEnv(fn, args)
+			frame := e.push(&Frame{
+				Function: fn,
+				Call:     call,
+				Env:      extendedEnv,
+				Pos:      fn.Body.Pos(),
+			})
+			evaluated := unwrapReturnValue(e.evalTail(fn.Body, extendedEnv, true))
+			tc, ok := evaluated.(*tailCall)
+			if !ok {
+				e.pop(frame, evaluated)
+				return evaluated
+			}
+			frame.TailCall = tc.call
+			e.pop(frame, nil)
+			fn, args, call = tc.fn, tc.args, tc.call
+		}
 	case *object.Builtin:
-		return fn.Fn(args...)
+		frame := &Frame{Builtin: fn, Call: call}
+		if call != nil {
+			frame.Pos = call.Pos()
+		}
+		e.push(frame)
+		result := fn.Fn(e, args...)
+		e.pop(frame, result)
+		return result
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
 }
 
 func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+	if fn.Locals != nil {
+		env := object.NewSlotEnvironment(fn.Locals, fn.Env)
+		for i, arg := range args {
+			env.SetSlot(i, arg)
+		}
+		return env
+	}
 	env := object.NewEnclosedEnvironment(fn.Env)
 	for paramIdx, param := range fn.Parameters {
 		env.Set(param.Value, args[paramIdx])
@@ -333,7 +439,7 @@ func unwrapReturnValue(obj object.Object) object.Object {
 
 func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
-	case left.Type() == object.ARRAY && index.Type() == object.INTEGER:
+	case left.Type() == object.ARRAY && isInteger(index):
 		return evalArrayIndexExpression(left, index)
 	case left.Type() == object.HASH:
 		return evalHashIndexExpression(left, index)
@@ -344,21 +450,26 @@ func evalIndexExpression(left, index object.Object) object.Object {
 
 func evalArrayIndexExpression(array, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
-	idx := index.(*object.Integer).Value
-	max := int64(len(arrayObject.Elements) - 1)
+	// big integers are beyond the end of any array
+	integer, ok := index.(*object.Integer)
+	if !ok {
+		return nullObj
+	}
+	idx := integer.Value
+	max := int64(arrayObject.Len() - 1)
 	if idx < 0 || idx > max {
 		return nullObj
 	}
-	return arrayObject.Elements[idx]
+	return arrayObject.At(int(idx))
 }
 
-func evalHashLiteral(
+func (e *Evaluator) evalHashLiteral(
 	node *ast.HashLiteral,
 	env *object.Environment,
 ) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	hash := &object.Hash{}
 	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env)
+		key := e.eval(keyNode, env)
 		if isError(key) {
 			return key
 		}
@@ -366,14 +477,14 @@ func evalHashLiteral(
 		if !ok {
 			return newError("unusable as hash key: %s", key.Type())
 		}
-		value := Eval(valueNode, env)
+		value := e.eval(valueNode, env)
 		if isError(value) {
 			return value
 		}
 		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Val: value}
+		hash = hash.Set(hashed, object.HashPair{Key: key, Val: value})
 	}
-	return &object.Hash{Pairs: pairs}
+	return hash
 }
 
 func evalHashIndexExpression(hash, index object.Object) object.Object {
@@ -382,7 +493,7 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 	if !ok {
 		return newError("unusable as hash key: %s", index.Type())
 	}
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	pair, ok := hashObject.Get(key.HashKey())
 	if !ok {
 		return nullObj
 	}
@@ -1,10 +1,20 @@
 package evaluator
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/lycheng/monkey-go/ast"
 	"github.com/lycheng/monkey-go/lexer"
 	"github.com/lycheng/monkey-go/object"
+	"github.com/lycheng/monkey-go/optimize"
 	"github.com/lycheng/monkey-go/parser"
 )
 
@@ -245,6 +255,7 @@ func TestLetStatements(t *testing.T) {
 		{"let a = 5 * 5; a;", 25},
 		{"let a = 5; let b = a; b;", 5},
 		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+		{"let f = fn() { let a = 5; a * 2 }; f();", 10},
 	}
 	for _, tt := range tests {
 		testIntegerObject(t, testEval(tt.input), tt.expected)
@@ -360,13 +371,13 @@ func TestArrayLiterals(t *testing.T) {
 	if !ok {
 		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
 	}
-	if len(result.Elements) != 3 {
+	if result.Len() != 3 {
 		t.Fatalf("array has wrong num of elements. got=%d",
-			len(result.Elements))
+			result.Len())
 	}
-	testIntegerObject(t, result.Elements[0], 1)
-	testIntegerObject(t, result.Elements[1], 4)
-	testIntegerObject(t, result.Elements[2], 6)
+	testIntegerObject(t, result.At(0), 1)
+	testIntegerObject(t, result.At(1), 4)
+	testIntegerObject(t, result.At(2), 6)
 }
 
 func TestArrayIndexExpressions(t *testing.T) {
@@ -414,6 +425,14 @@ func TestArrayIndexExpressions(t *testing.T) {
 			"[1, 2, 3][-1]",
 			nil,
 		},
+		{
+			"[1, 2, 3][9223372036854775807 + 1]",
+			nil,
+		},
+		{
+			"[1, 2, 3][-9223372036854775807 - 2]",
+			nil,
+		},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -449,11 +468,11 @@ false: 6
 		trueObj.HashKey():                          5,
 		falseObj.HashKey():                         6,
 	}
-	if len(result.Pairs) != len(expected) {
-		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	if result.Len() != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", result.Len())
 	}
 	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
+		pair, ok := result.Get(expectedKey)
 		if !ok {
 			t.Errorf("no pair for given key in Pairs")
 		}
@@ -505,3 +524,928 @@ func TestHashIndexExpressions(t *testing.T) {
 		}
 	}
 }
+
+func TestHook(t *testing.T) {
+	input := `let add = fn(a, b) {
+  a + b
+};
+let x = add(1, 2);
+x`
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	var visited []string
+	e := New()
+	e.Hook = func(e *Evaluator, stmt ast.Statement, env *object.Environment) *object.Error {
+		stack := []string{}
+		for _, frame := range e.Stack() {
+			stack = append(stack, fmt.Sprintf("%s@%s", frame.Name(), frame.Pos))
+		}
+		visited = append(visited, strings.Join(stack, " "))
+		if b, ok := env.Get("b"); ok && b.Inspect() == "5" {
+			return newError("stopped in add")
+		}
+		return nil
+	}
+	testIntegerObject(t, e.Eval(program, object.NewEnvironment()), 3)
+	expected := []string{
+		"main@1:1",
+		"main@4:1",
+		"main@4:1 add@2:3",
+		"main@5:1",
+	}
+	if strings.Join(visited, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("wrong statements visited.\nexpected=%q\ngot=%q", expected, visited)
+	}
+	if e.Depth() != 0 {
+		t.Errorf("call stack not empty after evaluation: %d", e.Depth())
+	}
+
+	// the evaluation stops with the error returned by the hook
+	input += "; add(2, 5); 10"
+	program = parser.New(lexer.New(input)).ParseProgram()
+	result := e.Eval(program, object.NewEnvironment())
+	if err, ok := result.(*object.Error); !ok || err.Message != "stopped in add" {
+		t.Errorf("expected the hook error, got %+v", result)
+	}
+}
+
+// recorder is a tracer recording the calls and errors
+type recorder struct {
+	events []string
+}
+
+func (r *recorder) Enter(e *Evaluator, frame *Frame) {
+	r.events = append(r.events, fmt.Sprintf("enter %s depth=%d", frame.Name(), e.Depth()))
+}
+
+func (r *recorder) Exit(e *Evaluator, frame *Frame, result object.Object) {
+	if frame.TailCall != nil {
+		r.events = append(r.events, fmt.Sprintf("exit %s tail call %s", frame.Name(), frame.TailCall))
+		return
+	}
+	r.events = append(r.events, fmt.Sprintf("exit %s %s", frame.Name(), result.Inspect()))
+}
+
+func (r *recorder) Error(e *Evaluator, err *object.Error) {
+	r.events = append(r.events, fmt.Sprintf("error %s at %s", err.Message, err.Pos))
+}
+
+func TestTracer(t *testing.T) {
+	input := `let inc = fn(x) { x + 1 };
+let n = inc(len("ab"));
+inc(n) + true`
+	program := parser.New(lexer.New(input)).ParseProgram()
+	r := &recorder{}
+	e := New()
+	e.Tracer = r
+	result := e.Eval(program, object.NewEnvironment())
+	expected := []string{
+		"enter main depth=1",
+		"enter len depth=2",
+		"exit len 2",
+		"enter inc depth=2",
+		"exit inc 3",
+		"enter inc depth=2",
+		"exit inc 4",
+		"error type mismatch: INTEGER + BOOLEAN at 3:1",
+		"exit main ERROR: type mismatch: INTEGER + BOOLEAN",
+	}
+	if strings.Join(r.events, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong events.\nexpected=%q\ngot=%q", expected, r.events)
+	}
+	if err, ok := result.(*object.Error); !ok || err.Pos.String() != "3:1" {
+		t.Errorf("expected an error at 3:1, got %+v", result)
+	}
+}
+
+func TestAssertBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // error message, empty when the assertion holds
+	}{
+		{`assert(1 < 2)`, ""},
+		{`assert(1 > 2)`, "assertion failed"},
+		{`assert(false, "math")`, "assertion failed: math"},
+		{`assert()`, "wrong number of arguments. got=0, want=1 or 2"},
+		{`assert_eq([1, {"a": [2]}], [1, {"a": [2]}])`, ""},
+		{`assert_eq(fn(x) { x }, fn(x) { x })`, "assert_eq failed\n" +
+			"  got:  fn(x) {\nx\n} (FUNCTION)\n  want: fn(x) {\nx\n} (FUNCTION)"},
+		{`assert_eq([1, 2, 3], [1, 2, 4], "list")`, "assert_eq failed: list\n" +
+			"  got:  [1, 2, 3]\n" +
+			"  want: [1, 2, 4]\n" +
+			"               ^"},
+		{`assert_eq("1", 1)`, "assert_eq failed\n  got:  1 (STRING)\n  want: 1 (INTEGER)"},
+		{`let f = fn(x) { x + 1 }; let g = fn(x) { x + 2 }; assert_eq(f, g)`, "assert_eq failed\n" +
+			"  --- got\n  +++ want\n    fn(x) {\n  - (x + 1)\n  + (x + 2)\n    }"},
+		{`assert_eq(1)`, "wrong number of arguments. got=1, want=2 or 3"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if tt.expected == "" {
+			testNullObject(t, evaluated)
+			continue
+		}
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message for %s.\nexpected=%q\ngot=%q",
+				tt.input, tt.expected, errObj.Message)
+		}
+	}
+}
+
+// testInspect checks the result of each input by its Inspect output, errors
+// inspect as "ERROR: message"
+func testInspect(t *testing.T, tests []struct{ input, expected string }) {
+	t.Helper()
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil {
+			t.Errorf("no result for %s", tt.input)
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestStringBuiltins(t *testing.T) {
+	testInspect(t, []struct{ input, expected string }{
+		{`split("a,b,,c", ",")`, "[a, b, , c]"},
+		{`len(split("  a  b c "))`, "3"},
+		{`split(1, ",")`, "ERROR: argument to `split` must be STRING, got INTEGER"},
+		{`split("a", 1)`, "ERROR: argument 2 to `split` must be STRING, got INTEGER"},
+		{`split()`, "ERROR: wrong number of arguments. got=0, want=1 or 2"},
+		{`join(["a", "b", "c"], "-")`, "a-b-c"},
+		{`join([], "-")`, ""},
+		{`join(["a", 1], "-")`, "ERROR: elements of argument to `join` must be STRING, got INTEGER"},
+		{`join("a", "-")`, "ERROR: argument to `join` must be ARRAY, got STRING"},
+		{`trim("  a b  ")`, "a b"},
+		{`trim("xxaxx", "x")`, "a"},
+		{`upper("abc")`, "ABC"},
+		{`lower("ÀBC")`, "àbc"},
+		{`upper(true)`, "ERROR: argument to `upper` must be STRING, got BOOLEAN"},
+		{`replace("a-b-c", "-", "+")`, "a+b+c"},
+		{`replace("a", "b")`, "ERROR: wrong number of arguments. got=2, want=3"},
+		{`contains("monkey", "key")`, "true"},
+		{`contains("monkey", "dog")`, "false"},
+		{`starts_with("monkey", "mon")`, "true"},
+		{`ends_with("monkey", "mon")`, "false"},
+		{`index_of("héllo", "llo")`, "2"},
+		{`index_of("hello", "x")`, "-1"},
+		{`repeat("ab", 3)`, "ababab"},
+		{`repeat("ab", -1)`, "ERROR: argument 2 to `repeat` must not be negative, got -1"},
+		{`repeat("a", 9223372036854775807)`, "ERROR: result of `repeat` longer than 1073741824 bytes"},
+		{`repeat("ab", 536870913)`, "ERROR: result of `repeat` longer than 1073741824 bytes"},
+		{`repeat("", 9223372036854775807)`, ""},
+		{`chars("hé!")`, "[h, é, !]"},
+		{`substring("héllo", 1, 3)`, "él"},
+		{`substring("héllo", 2)`, "llo"},
+		{`substring("héllo", -3, -1)`, "ll"},
+		{`substring("hello", 3, 1)`, ""},
+		{`substring("hello", 0, 100)`, "hello"},
+		{`substring("hello", "1")`, "ERROR: argument 2 to `substring` must be INTEGER, got STRING"},
+		{`substring("hello")`, "ERROR: wrong number of arguments. got=1, want=2 or 3"},
+	})
+}
+
+func TestArrayBuiltins(t *testing.T) {
+	testInspect(t, []struct{ input, expected string }{
+		{`map([1, 2, 3], fn(x) { x * 2 })`, "[2, 4, 6]"},
+		{`map(["a", "b"], upper)`, "[A, B]"},
+		{`map([1], 1)`, "ERROR: argument 2 to `map` must be FUNCTION, got INTEGER"},
+		{`map([1], fn(a, b) { a })`, "ERROR: wrong number of arguments. got=1, want=2"},
+		{`map([1, 2], fn(x) { x + true })`, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{`filter([1, 2, 3, 4], fn(x) { x > 2 })`, "[3, 4]"},
+		{`reduce([1, 2, 3], fn(acc, x) { acc + x })`, "6"},
+		{`reduce([1, 2, 3], fn(acc, x) { push(acc, x * x) }, [])`, "[1, 4, 9]"},
+		{`reduce([], fn(acc, x) { acc + x })`, "null"},
+		{`reduce([], fn(acc, x) { acc + x }, 0)`, "0"},
+		{`sort([3, 1, 2])`, "[1, 2, 3]"},
+		{`sort(["b", "c", "a"])`, "[a, b, c]"},
+		{`sort([3, 1, 2], fn(a, b) { a > b })`, "[3, 2, 1]"},
+		{`sort([3, 1, 2], fn(a, b) { b - a })`, "[3, 2, 1]"},
+		{`sort([[2, "a"], [1, "b"], [2, "c"], [1, "d"]], fn(a, b) { a[0] < b[0] })`,
+			"[[1, b], [1, d], [2, a], [2, c]]"},
+		{`sort([1, "a"])`, "ERROR: elements of argument to `sort` must have the same type, got INTEGER and STRING"},
+		{`sort([true])`, "ERROR: elements of argument to `sort` must be INTEGER or STRING, got BOOLEAN"},
+		{`sort([1, 2], fn(a, b) { "a" })`, "ERROR: comparator of `sort` must return BOOLEAN or INTEGER, got STRING"},
+		{`let a = [2, 1]; sort(a); a`, "[2, 1]"},
+		{`reverse([1, 2, 3])`, "[3, 2, 1]"},
+		{`range(4)`, "[0, 1, 2, 3]"},
+		{`range(2, 5)`, "[2, 3, 4]"},
+		{`range(5, 0, -2)`, "[5, 3, 1]"},
+		{`range(0)`, "[]"},
+		{`range(0, 5, 0)`, "ERROR: step of `range` must not be 0"},
+		{`range(9223372036854775806, 9223372036854775807, 2)`, "[9223372036854775806]"},
+		{`range(-9223372036854775807, -9223372036854775807 - 1, -9223372036854775807 - 1)`, "[-9223372036854775807]"},
+		{`range(9223372036854775807, -9223372036854775807, -9223372036854775807)`,
+			"[9223372036854775807, 0]"},
+		{`range(1099511627776)`, "ERROR: result of `range` longer than 16777216 elements"},
+		{`range(0, 16777217 * 3, 3)`, "ERROR: result of `range` longer than 16777216 elements"},
+		{`range()`, "ERROR: wrong number of arguments. got=0, want=1 to 3"},
+		{`slice([1, 2, 3, 4], 1, 3)`, "[2, 3]"},
+		{`slice([1, 2, 3, 4], -2)`, "[3, 4]"},
+		{`slice([1, 2, 3], 2, 1)`, "[]"},
+		{`slice([1, 2], "1")`, "ERROR: argument 2 to `slice` must be INTEGER, got STRING"},
+		{`let a = [1, 2, 3]; let b = push(rest(a), 4); let c = push(slice(a, 0, 2), 9); [a, b, c, push(c, 0)]`,
+			"[[1, 2, 3], [2, 3, 4], [1, 2, 9], [1, 2, 9, 0]]"},
+		{`zip([1, 2, 3], ["a", "b"])`, "[[1, a], [2, b]]"},
+		{`zip([1], 2)`, "ERROR: argument 2 to `zip` must be ARRAY, got INTEGER"},
+		{`any([1, 2, 3], fn(x) { x > 2 })`, "true"},
+		{`any([1, 2, 3], fn(x) { x > 3 })`, "false"},
+		{`any([false, find([], first)])`, "false"},
+		{`all([1, 2, 3], fn(x) { x > 0 })`, "true"},
+		{`all([1, false])`, "false"},
+		{`all([])`, "true"},
+		{`find([1, 2, 3, 4], fn(x) { x > 2 })`, "3"},
+		{`find([1, 2], fn(x) { x > 2 })`, "null"},
+		{`flatten([1, [2, [3]], [], 4])`, "[1, 2, [3], 4]"},
+		{`unique([1, "a", 1, [1], "a", [1], true])`, "[1, a, [1], true]"},
+	})
+}
+
+func TestHashBuiltins(t *testing.T) {
+	testInspect(t, []struct{ input, expected string }{
+		{`{"b": 2, "a": 1, 3: "c", true: 4}`, "{true: 4, 3: c, a: 1, b: 2}"},
+		{`{"a": 1, 9223372036854775807 + 1: 2, -1: 3, false: 4}`, "{false: 4, -1: 3, 9223372036854775808: 2, a: 1}"},
+		{`keys({"b": 2, "a": 1})`, "[a, b]"},
+		{`values({"b": 2, "a": 1})`, "[1, 2]"},
+		{`items({"b": 2, "a": 1})`, "[[a, 1], [b, 2]]"},
+		{`keys({})`, "[]"},
+		{`keys([])`, "ERROR: argument to `keys` must be HASH, got ARRAY"},
+		{`has({"a": find([], first)}, "a")`, "true"},
+		{`has({"a": 1}, "b")`, "false"},
+		{`has({"a": 1}, [])`, "ERROR: argument 2 to `has` unusable as hash key: ARRAY"},
+		{`get({"a": 1}, "a", 0)`, "1"},
+		{`get({"a": 1}, "b", 0)`, "0"},
+		{`get({"a": 1}, "b")`, "null"},
+		{`get({"a": 1})`, "ERROR: wrong number of arguments. got=1, want=2 or 3"},
+		{`delete({"a": 1, "b": 2}, "a")`, "{b: 2}"},
+		{`let h = {"a": 1}; let g = merge(h, {"b": 2}); [h, g, delete(g, "a"), g]`,
+			"[{a: 1}, {a: 1, b: 2}, {b: 2}, {a: 1, b: 2}]"},
+		{`delete({"a": 1}, "b")`, "{a: 1}"},
+		{`let h = {"a": 1}; delete(h, "a"); h`, "{a: 1}"},
+		{`merge({"a": 1, "b": 2}, {"b": 3}, {"c": 4})`, "{a: 1, b: 3, c: 4}"},
+		{`merge({"a": 1}, 1)`, "ERROR: argument 2 to `merge` must be HASH, got INTEGER"},
+		{`merge()`, "ERROR: wrong number of arguments. got=0, want=1 or more"},
+	})
+}
+
+func TestJSONBuiltins(t *testing.T) {
+	// Monkey strings have no escapes, the documents are bound to src
+	tests := []struct {
+		src      string
+		input    string
+		expected string
+	}{
+		{`{"b": [1, 2.0, -3e2], "a": {"c": null, "d": true}}`, `json_parse(src)`,
+			"{a: {c: null, d: true}, b: [1, 2, -300]}"},
+		{`"hé\n"`, `len(json_parse(src))`, "4"},
+		{`{"a": 1, "a": 2}`, `json_parse(src)`, "{a: 2}"},
+		{`[18446744073709551616, 1e20]`, `json_stringify(json_parse(src))`,
+			"[18446744073709551616,1e+20]"},
+		{`{"price": 1.5, "rates": [0.1, -2.5e-3, 1.25e-300, 1e-400]}`, `json_parse(src)`,
+			"{price: 1.5, rates: [0.1, -0.0025, 1.25e-300, 0]}"},
+		{`[2.0]`, `json_parse(src)[0] + 1`, "ERROR: type mismatch: FLOAT + INTEGER"},
+		{`[2e3]`, `json_parse(src)[0] + 1`, "ERROR: type mismatch: FLOAT + INTEGER"},
+		{`[2]`, `json_parse(src)[0] + 1`, "3"},
+		{`[1e999999]`, `json_parse(src)`, "ERROR: json_parse: line 1, column 2: number 1e999999 out of range"},
+		{`[0.1, 2.5, -1e-7]`, `json_stringify(json_parse(src))`, "[0.1,2.5,-1e-07]"},
+		{`[1.5]`, `assert_eq(json_parse(src), json_parse(src))`, "null"},
+		{"[1" + strings.Repeat("0", 400) + ".5]", `json_parse(src)`,
+			"ERROR: json_parse: line 1, column 2: number 1" + strings.Repeat("0", 400) + ".5 out of range"},
+		{"{\n  \"a\": 1,\n  \"b\" 2\n}", `json_parse(src)`,
+			"ERROR: json_parse: line 3, column 7: invalid character '2' after object key"},
+		{`[1, 2`, `json_parse(src)`,
+			"ERROR: json_parse: line 1, column 6: unexpected end of JSON input"},
+		{`[1] [2]`, `json_parse(src)`,
+			"ERROR: json_parse: line 1, column 5: unexpected data after the top level value"},
+		{`[1}`, `json_parse(src)`,
+			"ERROR: json_parse: line 1, column 3: invalid character '}' after array element"},
+		{``, `json_parse(src)`, "ERROR: json_parse: line 1, column 1: unexpected end of JSON input"},
+		{`{"b": [1, "<x>"], "a": {"c": null}}`, `json_stringify(json_parse(src))`,
+			`{"a":{"c":null},"b":[1,"<x>"]}`},
+		{`[1]`, `json_stringify(json_parse(src), 100000000000)`,
+			"ERROR: argument 2 to `json_stringify` must be at most 16, got 100000000000"},
+		{`[1]`, `json_stringify(json_parse(src), -1)`,
+			"ERROR: argument 2 to `json_stringify` must not be negative, got -1"},
+		{`[1, {"a": true}]`, `json_stringify(json_parse(src), 2)`,
+			"[\n  1,\n  {\n    \"a\": true\n  }\n]"},
+		{`{}`, `json_stringify({1: "a", false: [], "s": len}["s"])`,
+			"ERROR: json_stringify: BUILTIN is not serializable"},
+		{`{}`, `json_stringify({1: "a", false: []})`, `{"false":[],"1":"a"}`},
+		{`{}`, `json_stringify({"a": 1, 9223372036854775807 + 1: 2, 2: 3})`, `{"2":3,"9223372036854775808":2,"a":1}`},
+		{`{}`, `json_stringify([fn(x) { x }])`, "ERROR: json_stringify: FUNCTION is not serializable"},
+		{`{}`, `json_stringify(1, true)`,
+			"ERROR: argument 2 to `json_stringify` must be INTEGER or STRING, got BOOLEAN"},
+	}
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		env.Set("src", &object.String{Value: tt.src})
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated := Eval(program, env)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s of %s. expected=%q, got=%q",
+				tt.input, tt.src, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestRegexBuiltins(t *testing.T) {
+	testInspect(t, []struct{ input, expected string }{
+		{`regex("a+b")`, "/a+b/"},
+		{`regex("(a")`, "ERROR: regex: error parsing regexp: missing closing ): `(a`"},
+		{`match("(\d+)-(\d+)?", "tel: 12-")`, "[12-, 12, null]"},
+		{`match(regex("x"), "abc")`, "null"},
+		{`match(1, "abc")`, "ERROR: argument to `match` must be REGEX or STRING, got INTEGER"},
+		{`match("[", "abc")`, "ERROR: match: error parsing regexp: missing closing ]: `[`"},
+		{`captures("(?P<key>\w+)=(?P<value>\w*)", "a b=c")`, "{key: b, value: c}"},
+		{`captures("(?P<key>\w+)=", "abc")`, "null"},
+		{`find_all("\d+", "a1b22c333")`, "[1, 22, 333]"},
+		{`find_all("\d+", "a1b22c333", 2)`, "[1, 22]"},
+		{`find_all("(\w)(\d)", "a1 b2")`, "[[a1, a, 1], [b2, b, 2]]"},
+		{`find_all("\d", "abc")`, "[]"},
+		{`replace_regex("(\w+)@(\w+)", "me@home you@work", "$2:$1")`, "home:me work:you"},
+		{`replace_regex("\d+", "a1b22", fn(m) { repeat("#", len(m[0])) })`, "a#b##"},
+		{`replace_regex("\d+", "a1", fn(m) { 1 })`,
+			"ERROR: function passed to `replace_regex` must return STRING, got INTEGER"},
+		{`replace_regex("\d+", "a1", 1)`,
+			"ERROR: argument 3 to `replace_regex` must be STRING or FUNCTION, got INTEGER"},
+		{`split_regex("\s*,\s*", "a , b,c")`, "[a, b, c]"},
+		{`split_regex(",", "a,b,c", 2)`, "[a, b,c]"},
+		{`split_regex(",", "a,b,c", "2")`, "ERROR: argument 3 to `split_regex` must be INTEGER, got STRING"},
+		{`assert_eq(regex("a"), regex("a"))`, "null"},
+	})
+}
+
+func TestRegexCache(t *testing.T) {
+	a, err := compileRegex("a+")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := compileRegex("a+"); a != b {
+		t.Errorf("pattern compiled again")
+	}
+	for i := 0; i < maxCachedRegexes+1; i++ {
+		compileRegex(fmt.Sprint(i))
+	}
+	if n := len(regexCache.regexps); n > maxCachedRegexes {
+		t.Errorf("cache holds %d patterns, want at most %d", n, maxCachedRegexes)
+	}
+}
+
+func TestIntegerOverflow(t *testing.T) {
+	testInspect(t, []struct{ input, expected string }{
+		{`9223372036854775807 + 1`, "9223372036854775808"},
+		{`-9223372036854775807 - 2`, "-9223372036854775809"},
+		{`let big = 9223372036854775807 + 1; big - 1`, "9223372036854775807"},
+		{`4294967296 * 4294967296`, "18446744073709551616"},
+		{`(9223372036854775807 + 1) * -1`, "-9223372036854775808"},
+		{`-(-9223372036854775807 - 1)`, "9223372036854775808"},
+		{`(-9223372036854775807 - 1) / -1`, "9223372036854775808"},
+		{`pow(2, 64) / pow(2, 60)`, "16"},
+		{`pow(2, 64) > 1`, "true"},
+		{`pow(2, 64) == pow(4, 32)`, "true"},
+		{`{pow(2, 64): "a"}[pow(4, 32)]`, "a"},
+		{`1 / 0`, "ERROR: division by zero"},
+		{`pow(2, 64) / 0`, "ERROR: division by zero"},
+		{`pow(2, 64) + true`, "ERROR: type mismatch: BIGINT + BOOLEAN"},
+	})
+}
+
+func TestMathBuiltins(t *testing.T) {
+	testInspect(t, []struct{ input, expected string }{
+		{`abs(-3)`, "3"},
+		{`abs(-9223372036854775807 - 1)`, "9223372036854775808"},
+		{`abs("a")`, "ERROR: argument to `abs` must be INTEGER, got STRING"},
+		{`min(3, 1, 2)`, "1"},
+		{`max(3, 1, 2)`, "3"},
+		{`max([1, pow(2, 70), 3])`, "1180591620717411303424"},
+		{`min([])`, "ERROR: argument to `min` must not be empty"},
+		{`min()`, "ERROR: wrong number of arguments. got=0, want=1 or more"},
+		{`min(1, "a")`, "ERROR: argument 2 to `min` must be INTEGER, got STRING"},
+		{`pow(3, 4)`, "81"},
+		{`pow(2, 100)`, "1267650600228229401496703205376"},
+		{`pow(2, -1)`, "ERROR: argument 2 to `pow` must not be negative, got -1"},
+		{`pow(10, 9223372036854775807)`, "ERROR: result of `pow` larger than 1048576 bits"},
+		{`pow(-2, pow(2, 64))`, "ERROR: result of `pow` larger than 1048576 bits"},
+		{`pow(1, 9223372036854775807)`, "1"},
+		{`pow(-1, pow(2, 64) + 1)`, "-1"},
+		{`pow(2, 1048576) > 0`, "true"},
+		{`pow(2, 1048577)`, "ERROR: result of `pow` larger than 1048576 bits"},
+		{`sqrt(17)`, "4"},
+		{`sqrt(pow(10, 40))`, "100000000000000000000"},
+		{`sqrt(-1)`, "ERROR: argument to `sqrt` must not be negative, got -1"},
+		{`floor(7, 2)`, "3"},
+		{`floor(-7, 2)`, "-4"},
+		{`ceil(7, 2)`, "4"},
+		{`ceil(-7, 2)`, "-3"},
+		{`ceil(6, -2)`, "-3"},
+		{`floor(5)`, "5"},
+		{`floor(5, 0)`, "ERROR: division by zero"},
+		{`divmod(7, 2)`, "[3, 1]"},
+		{`divmod(-7, 2)`, "[-3, -1]"},
+		{`divmod(1, 0)`, "ERROR: division by zero"},
+		{`seed(42); let a = [random(100), random(5, 10)]; seed(42); assert_eq(a, [random(100), random(5, 10)])`,
+			"null"},
+		{`all(map(range(50), fn(i) { random(5, 10) }), fn(n) { if (n < 5) { false } else { n < 10 } })`, "true"},
+		{`random(3, 3)`, "ERROR: empty range of `random`: [3, 3)"},
+		{`random(-9223372036854775807, 9223372036854775807)`,
+			"ERROR: range of `random` too large: [-9223372036854775807, 9223372036854775807)"},
+	})
+}
+
+func TestRandomSource(t *testing.T) {
+	program, _ := Compile(`let a = map(range(5), fn(i) { random(1000) });
+let f = spawn(fn() { random(1000) });
+push(a, await(f))`)
+	run := func(seed int64) string {
+		e := New()
+		e.Random = NewRandom(seed)
+		return program.RunWith(e, object.NewEnvironment()).Inspect()
+	}
+	expected := run(1)
+
+	// concurrent runs, even reseeding, don't change the numbers of the
+	// other runs
+	reseed, _ := Compile(`seed(7); random(1000)`)
+	var wg sync.WaitGroup
+	results := make([]string, 8)
+	for i := range results {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = run(1)
+		}(i)
+		go func() {
+			defer wg.Done()
+			reseed.Run(object.NewEnvironment())
+		}()
+	}
+	wg.Wait()
+	for i, result := range results {
+		if result != expected {
+			t.Errorf("run %d differs. expected=%s, got=%s", i, expected, result)
+		}
+	}
+	if run(2) == expected {
+		t.Errorf("runs with different seeds return the same numbers %s", expected)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	if err := os.Mkdir(root, 0o777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "secret"), []byte("s"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(dir, filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("MONKEY_POLICY_TEST", "value")
+	defer os.Unsetenv("MONKEY_POLICY_TEST")
+
+	policy := &Policy{Roots: []string{root}, Env: []string{"MONKEY_POLICY_TEST"}, Commands: []string{"echo"}}
+	readOnly := &Policy{Roots: []string{root}, ReadOnly: true}
+	tests := []struct {
+		policy   *Policy
+		input    string
+		expected string
+	}{
+		{policy, `write_file("ROOT/a.txt", "hello"); read_file("ROOT/a.txt")`, "hello"},
+		{policy, `write_file("ROOT/b.txt", ""); list_dir("ROOT")`, "[a.txt, b.txt, link]"},
+		{policy, `[exists("ROOT/a.txt"), exists("ROOT/c.txt")]`, "[true, false]"},
+		{policy, `read_file("ROOT/c.txt")`, "ERROR: read_file: open ROOT/c.txt: no such file or directory"},
+		{policy, `read_file("DIR/secret")`, "ERROR: `read_file` denied: DIR/secret is outside the allowed directories"},
+		{policy, `read_file("ROOT/../secret")`, "ERROR: `read_file` denied: ROOT/../secret is outside the allowed directories"},
+		{policy, `read_file("ROOT/link/secret")`, "ERROR: `read_file` denied: ROOT/link/secret is outside the allowed directories"},
+		{policy, `write_file("ROOT/link/new", "")`, "ERROR: `write_file` denied: ROOT/link/new is outside the allowed directories"},
+		{readOnly, `read_file("ROOT/a.txt")`, "hello"},
+		{readOnly, `write_file("ROOT/a.txt", "")`, "ERROR: `write_file` denied: the file system is read-only"},
+		{policy, `getenv("MONKEY_POLICY_TEST")`, "value"},
+		{policy, `getenv("HOME")`, "ERROR: `getenv` denied: environment variable HOME is not allowed"},
+		{&Policy{Env: []string{"*"}}, `getenv("MONKEY_POLICY_UNSET")`, "null"},
+		{policy, `exec("echo", ["a", "b"])`, "{status: 0, stderr: , stdout: a b\n}"},
+		{policy, `exec("rm", ["-rf", "ROOT"])`, "ERROR: `exec` denied: command rm is not allowed"},
+		{nil, `read_file("ROOT/a.txt")`, "ERROR: `read_file` denied: ROOT/a.txt is outside the allowed directories"},
+		{nil, `exec("echo")`, "ERROR: `exec` denied: command echo is not allowed"},
+		{nil, `try(fn() { read_file("ROOT/a.txt") }, fn(err) { "caught: " + err })`,
+			"caught: `read_file` denied: ROOT/a.txt is outside the allowed directories"},
+		{nil, `try(fn() { 1 }, fn(err) { 2 })`, "1"},
+	}
+	for _, tt := range tests {
+		input := strings.NewReplacer("ROOT", root, "DIR", dir).Replace(tt.input)
+		e := New()
+		e.Policy = tt.policy
+		evaluated := e.Eval(parser.New(lexer.New(input)).ParseProgram(), object.NewEnvironment())
+		expected := strings.NewReplacer("ROOT", root, "DIR", dir).Replace(tt.expected)
+		if evaluated.Inspect() != expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%q", input, expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestTryHookError(t *testing.T) {
+	input := `try(fn() { let x = 1; x }, fn(err) { 2 })`
+	e := New()
+	e.Hook = func(e *Evaluator, stmt ast.Statement, env *object.Environment) *object.Error {
+		if _, ok := env.Get("x"); ok {
+			return newError("stopped")
+		}
+		return nil
+	}
+	result := e.Eval(parser.New(lexer.New(input)).ParseProgram(), object.NewEnvironment())
+	if err, ok := result.(*object.Error); !ok || err.Message != "stopped" {
+		t.Errorf("try caught the error of the hook, got %s", result.Inspect())
+	}
+}
+
+// fakeClock is a clock whose time only moves by sleeping
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.now = c.now.Add(d)
+	return nil
+}
+
+func TestTimeBuiltins(t *testing.T) {
+	tests := []struct{ input, expected string }{
+		{`now()`, "2024-02-29T12:30:00Z"},
+		{`let t = now(); sleep(duration("1h30m")); now() - t`, "1h30m0s"},
+		{`now() + duration("36h")`, "2024-03-02T00:30:00Z"},
+		{`duration("1m") + now()`, "2024-02-29T12:31:00Z"},
+		{`now() - duration("1s")`, "2024-02-29T12:29:59Z"},
+		{`parse_time("2024-03-01T00:00:00+01:00") - now()`, "10h30m0s"},
+		{`parse_time("2024-01-02", "date") < now()`, "true"},
+		{`parse_time("2024-01-02", "date") > now()`, "false"},
+		{`parse_time("2024-02-29 13:30", "2006-01-02 15:04") == now() + duration("1h")`, "true"},
+		{`parse_time("2024-02-29T13:30:00+01:00") == now()`, "true"},
+		{`parse_time("nope")`, "ERROR: parse_time: parsing time \"nope\" as \"2006-01-02T15:04:05.999999999Z07:00\": cannot parse \"nope\" as \"2006\""},
+		{`format_time(now(), "datetime")`, "2024-02-29 12:30:00"},
+		{`format_time(in_zone(now(), "Asia/Tokyo"), "2006-01-02 15:04 MST")`, "2024-02-29 21:30 JST"},
+		{`in_zone(now(), "America/New_York")`, "2024-02-29T07:30:00-05:00"},
+		{`in_zone(now(), "Mars/Olympus")`, "ERROR: in_zone: unknown time zone Mars/Olympus"},
+		{`unix(now())`, "1709209800"},
+		{`from_unix(0)`, "1970-01-01T00:00:00Z"},
+		{`duration("1h") * 3`, "3h0m0s"},
+		{`2 * duration("1m")`, "2m0s"},
+		{`duration("1h") / 4`, "15m0s"},
+		{`duration("1h") / duration("1m")`, "60"},
+		{`duration("1h") / 0`, "ERROR: division by zero"},
+		{`duration("1h") / duration("0s")`, "ERROR: division by zero"},
+		{`duration("2562047h") * 10`, "ERROR: duration overflow: 2562047h0m0s * 10"},
+		{`10 * duration("2562047h")`, "ERROR: duration overflow: 10 * 2562047h0m0s"},
+		{`duration("2562047h") + duration("2562047h")`, "ERROR: duration overflow: 2562047h0m0s + 2562047h0m0s"},
+		{`duration("-2562047h") - duration("2562047h")`, "ERROR: duration overflow: -2562047h0m0s - 2562047h0m0s"},
+		{`duration("2562047h") * 1`, "2562047h0m0s"},
+		{`duration("1h") - duration("2h") < duration("0s")`, "true"},
+		{`duration("1x")`, "ERROR: duration: unknown unit \"x\" in duration \"1x\""},
+		{`now() + 1`, "ERROR: type mismatch: TIME + INTEGER"},
+		{`now() * now()`, "ERROR: unknown operator: TIME * TIME"},
+		{`assert_eq(duration("60s"), duration("1m"))`, "null"},
+	}
+	for _, tt := range tests {
+		e := New()
+		e.Clock = &fakeClock{now: time.Date(2024, 2, 29, 12, 30, 0, 0, time.UTC)}
+		evaluated := e.Eval(parser.New(lexer.New(tt.input)).ParseProgram(), object.NewEnvironment())
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestSleepCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	e := New()
+	e.Context = ctx
+	done := make(chan object.Object)
+	go func() {
+		done <- e.Eval(parser.New(lexer.New(`sleep(duration("1h"))`)).ParseProgram(), object.NewEnvironment())
+	}()
+	cancel()
+	select {
+	case result := <-done:
+		if result.Inspect() != "ERROR: sleep: context canceled" {
+			t.Errorf("wrong result of a cancelled sleep: %s", result.Inspect())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("sleep not cancelled")
+	}
+}
+
+func TestExecCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	e := New()
+	e.Context = ctx
+	e.Policy = &Policy{Commands: []string{"sleep"}}
+	done := make(chan object.Object)
+	go func() {
+		done <- e.Eval(parser.New(lexer.New(`exec("sleep", ["60"])`)).ParseProgram(), object.NewEnvironment())
+	}()
+	time.AfterFunc(100*time.Millisecond, cancel)
+	select {
+	case result := <-done:
+		if result.Inspect() != "ERROR: exec: context canceled" {
+			t.Errorf("wrong result of a cancelled exec: %s", result.Inspect())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("exec not cancelled")
+	}
+}
+
+func TestConcurrencyBuiltins(t *testing.T) {
+	testInspect(t, []struct{ input, expected string }{
+		{`let square = fn(x) { x * x }; map(map(range(5), fn(i) { spawn(square, i) }), await)`, "[0, 1, 4, 9, 16]"},
+		{`await(spawn(fn() { 1 + true }))`, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{`await(spawn(len, "abc"))`, "3"},
+		{`spawn(1)`, "ERROR: argument to `spawn` must be FUNCTION, got INTEGER"},
+		{`let f = spawn(fn(a, b) { a + b }, 1, 2); await(f); f`, "future(done)"},
+		{`let ch = channel(); spawn(fn() { send(ch, 1); send(ch, 2); close(ch) }); [recv(ch), recv(ch), recv(ch)]`,
+			"[1, 2, null]"},
+		{`let ch = channel(2); send(ch, "a"); ch`, "channel(1/2)"},
+		{`let ch = channel(1); close(ch); close(ch)`, "ERROR: close of closed channel"},
+		{`let ch = channel(1); close(ch); send(ch, 1)`, "ERROR: send: send on closed channel"},
+		{`channel(-1)`, "ERROR: argument to `channel` must not be negative, got -1"},
+		{`channel(4611686018427387904)`, "ERROR: argument to `channel` must be at most 1048576, got 4611686018427387904"},
+		{`let a = channel(1); let b = channel(1); send(b, "x"); select([a, b])`, "[1, x, true]"},
+		{`let a = channel(1); close(a); select([a])`, "[0, null, false]"},
+		{`let a = channel(); let b = channel(1); select([a, [b, 5]])`, "[1, null, true]"},
+		{`let a = channel(); select([a], "none")`, "[-1, none, false]"},
+		{`select([1])`, "ERROR: case 0 of `select` must be CHANNEL or [CHANNEL, value], got INTEGER"},
+		{`select([[1, 2]])`, "ERROR: case 0 of `select` must be CHANNEL or [CHANNEL, value], got [1, 2]"},
+		{`let results = channel(10);
+let worker = fn(id) { send(results, id * 10) };
+let futures = map(range(10), fn(i) { spawn(worker, i) });
+map(futures, await);
+close(results);
+let null = find([], first);
+let collect = fn(acc) { let v = recv(results); if (v == null) { acc } else { collect(push(acc, v)) } };
+sort(collect([]))`, "[0, 10, 20, 30, 40, 50, 60, 70, 80, 90]"},
+	})
+}
+
+func TestConcurrentClosures(t *testing.T) {
+	// the spawned closures read the environment of the program while it is
+	// being set
+	input := `let n = 1;
+let reader = fn() { reduce(range(200), fn(acc, i) { acc + n }, 0) };
+let futures = map(range(4), fn(i) { spawn(reader) });
+let a = 1; let b = 2; let c = 3; let d = 4; let e = 5;
+map(futures, await)`
+	testInspect(t, []struct{ input, expected string }{{input, "[200, 200, 200, 200]"}})
+}
+
+func TestChannelCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	e := New()
+	e.Context = ctx
+	done := make(chan object.Object)
+	go func() {
+		done <- e.Eval(parser.New(lexer.New(`recv(channel())`)).ParseProgram(), object.NewEnvironment())
+	}()
+	cancel()
+	select {
+	case result := <-done:
+		if result.Inspect() != "ERROR: recv: context canceled" {
+			t.Errorf("wrong result of a cancelled recv: %s", result.Inspect())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("recv not cancelled")
+	}
+}
+
+func TestProgramConcurrentRuns(t *testing.T) {
+	program, errs := Compile(`
+let rates = {"basic": 10, "premium": 25};
+let cost = fn(item) { rates[item["plan"]] * item["units"] };
+let total = reduce(map(items, cost), fn(acc, c) { acc + c }, 0);
+let tags = sort(unique(map(items, fn(item) { upper(item["plan"]) })));
+[customer, total, join(tags, ",")]`)
+	if errs != nil {
+		t.Fatalf("parse errors: %v", errs)
+	}
+
+	item := func(plan string, units int64) object.Object {
+		return stringHash(map[string]object.Object{
+			"plan":  &object.String{Value: plan},
+			"units": &object.Integer{Value: units},
+		})
+	}
+	// the items are shared by all the runs
+	items := object.NewArray([]object.Object{item("basic", 2), item("premium", 1)})
+
+	const goroutines, runs = 16, 100
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < runs; i++ {
+				env := object.NewEnvironment()
+				customer := fmt.Sprintf("c%d-%d", g, i)
+				env.Set("customer", &object.String{Value: customer})
+				env.Set("items", items)
+				result := program.Run(env).Inspect()
+				if expected := "[" + customer + ", 45, BASIC,PREMIUM]"; result != expected {
+					t.Errorf("wrong result. expected=%q, got=%q", expected, result)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestResolvedScoping(t *testing.T) {
+	testInspect(t, []struct{ input, expected string }{
+		{`let x = 1; let f = fn() { let y = x; let x = 2; [y, x] }; f()`, "[1, 2]"},
+		{`let x = 1; let f = fn(c) { if (c) { let x = 2 }; x }; [f(true), f(false)]`, "[2, 1]"},
+		{`let f = fn() { let g = fn() { v }; let v = 5; g() }; f()`, "5"},
+		{`let adder = fn(a) { fn(b) { a + b } }; let addTwo = adder(2); [addTwo(1), adder(10)(5)]`, "[3, 15]"},
+		{`let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(10)`, "3628800"},
+		{`let f = fn(a, a) { a }; f(1, 2)`, "2"},
+		{`let f = fn(x) { let x = x + 1; x }; f(1)`, "2"},
+		{`let f = fn() { y }; let y = 3; f()`, "3"},
+		{`let f = fn() { z }; f()`, "ERROR: identifier not found: z"},
+		{`let f = fn() { let len = fn(x) { 0 }; len("abc") }; [f(), len("abc")]`, "[0, 3]"},
+	})
+}
+
+func TestDifferential(t *testing.T) {
+	// resolved and optimized programs evaluate as the unresolved ones do
+	inputs := []string{
+		`let x = 1; let f = fn() { let y = x; let x = 2; [y, x] }; f()`,
+		`let x = 1; let f = fn(c) { if (c) { let x = 2 }; x }; [f(true), f(false)]`,
+		`let f = fn() { let g = fn() { v }; let v = 5; g() }; f()`,
+		`let adder = fn(a) { fn(b) { a + b } }; let addTwo = adder(2); [addTwo(1), adder(10)(5)]`,
+		`let f = fn(a, a) { a }; f(1, 2)`,
+		`let f = fn(x) { let x = x + 1; x }; f(1)`,
+		`let f = fn() { z }; f()`,
+		`let f = fn() { let len = fn(x) { 0 }; len("abc") }; [f(), len("abc")]`,
+		`let r = [even(10), odd(7)]; fn even(n) { if (n == 0) { true } else { odd(n - 1) } } fn odd(n) { if (n == 0) { false } else { even(n - 1) } } r`,
+		`let f = fn(c) { if (c) { fn g() { 1 } } else { fn g() { 2 } } g() }; [f(true), f(false)]`,
+		`let f = fn() { fn g() { 1 } }; g()`,
+		`let a = if (true) { fn h() { 2 } }; [a() + 1, a == h]`,
+		`fn f(x) { x } let g = f; g(1, 2)`,
+		`let sum = fn(n, acc) { if (n == 0) { return acc; } return sum(n - 1, acc + n); }; sum(100, 0)`,
+		`let f = fn(n) { if (n > 0) { f(n - 1) } 7 }; f(3)`,
+		`let f = fn() { g(1) }; let g = fn(a, b) { a }; f()`,
+		`let f = fn(n) { map([n], fn(x) { g(x) }) }; let g = fn(x) { x * 2 }; f(4)`,
+		`let h = {"a": 1, true: [2, 3]}; [h["a"], h[true][1], h["b"]]`,
+		`let s = "a"; s - 1`,
+		`let big = 9223372036854775807; [big + 1, big * big]`,
+		`let zero = 0; 10 / zero`,
+		`let f = fn() { if (true) { return 1; } 2 }; f()`,
+		`let day = 60 * 60 * 24; let f = fn(n) { n * day }; f(2)`,
+		`let x = if (false) { 1 } else { "two" }; x + "!"`,
+		`let f = fn() { 1 / 0 }; f()`,
+	}
+	for _, input := range inputs {
+		testDifferential(t, input)
+	}
+}
+
+func testDifferential(t *testing.T, input string) {
+	t.Helper()
+	expected := testEval(input)
+	program, errs := Compile(input)
+	if errs != nil {
+		t.Errorf("compile errors for %s: %v", input, errs)
+		return
+	}
+	testSameResult(t, input, "resolved", expected, program.Run(object.NewEnvironment()))
+
+	parsed := parser.New(lexer.New(input)).ParseProgram()
+	optimize.Program(parsed)
+	testSameResult(t, input, "optimized", expected, Prepare(parsed).Run(object.NewEnvironment()))
+}
+
+func testSameResult(t *testing.T, input, kind string, expected, got object.Object) {
+	t.Helper()
+	if got.Inspect() != expected.Inspect() {
+		t.Errorf("wrong %s result for %s.\nexpected=%s\ngot=     %s", kind, input, expected.Inspect(), got.Inspect())
+		return
+	}
+	if err, ok := expected.(*object.Error); ok && err.Pos != got.(*object.Error).Pos {
+		t.Errorf("wrong %s error position for %s. expected=%s, got=%s", kind, input, err.Pos, got.(*object.Error).Pos)
+	}
+}
+
+func TestFunctionStatements(t *testing.T) {
+	testInspect(t, []struct{ input, expected string }{
+		{`fn fact(n) { if (n < 2) { 1 } else { n * fact(n - 1) } } fact(5)`, "120"},
+		{`let r = [even(10), odd(7)]; fn even(n) { if (n == 0) { true } else { odd(n - 1) } } fn odd(n) { if (n == 0) { false } else { even(n - 1) } } r`,
+			"[true, true]"},
+		{`let f = fn(n) { let r = even(n); fn even(n) { if (n == 0) { true } else { odd(n - 1) } } fn odd(n) { !even(n) } r }; [f(4), f(3)]`,
+			"[true, false]"},
+		{`fn f(x) { x * 2 } f`, "fn f(x) {\n(x * 2)\n}"},
+		{`fn f(x) { x } let g = f; g(1, 2)`, "ERROR: wrong number of arguments to f. got=2, want=1"},
+		{`fn f() { 1 } let f = 2; f`, "2"},
+		{`let f = fn(c) { if (c) { fn g() { 1 } } else { fn g() { 2 } } g() }; [f(true), f(false)]`, "[1, 2]"},
+		{`let f = fn() { fn g() { 1 } }; g()`, "ERROR: identifier not found: g"},
+		{`let f = fn(x) { fn g() { x } }; f(1)() + 1`, "2"},
+		{`let a = if (true) { fn h() { 2 } }; [a() + 1, a == h]`, "[3, true]"},
+		{`fn f() { 1 }`, "fn f() {\n1\n}"},
+	})
+
+	r := &recorder{}
+	e := New()
+	e.Tracer = r
+	e.Eval(parser.New(lexer.New(`fn inc(x) { x + 1 } let alias = inc; alias(1)`)).ParseProgram(),
+		object.NewEnvironment())
+	expected := []string{
+		"enter main depth=1",
+		"enter inc depth=2",
+		"exit inc 2",
+		"exit main 2",
+	}
+	if strings.Join(r.events, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong events.\nexpected=%q\ngot=%q", expected, r.events)
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	testInspect(t, []struct{ input, expected string }{
+		{`let sum = fn(n, acc) { if (n == 0) { acc } else { sum(n - 1, acc + n) } }; sum(100, 0)`, "5050"},
+		{`let sum = fn(n, acc) { if (n == 0) { return acc; } return sum(n - 1, acc + n); }; sum(100, 0)`, "5050"},
+		{`let f = fn(n) { if (n > 0) { return f(n - 1); } let m = n; m + 10 }; f(3)`, "10"},
+		{`let f = fn(n) { if (n > 0) { f(n - 1) } 7 }; f(3)`, "7"},
+		{`let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } };
+let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };
+[even(10), odd(10), even(7)]`, "[true, false, false]"},
+		{`let f = fn(x) { len(x) }; f("abc")`, "3"},
+		{`let f = fn() { g(1) }; let g = fn(a, b) { a }; f()`, "ERROR: wrong number of arguments. got=1, want=2"},
+		{`let f = fn() { 1(2) }; f()`, "ERROR: not a function: INTEGER"},
+		{`let f = fn(n) { map([n], fn(x) { g(x) }) }; let g = fn(x) { x * 2 }; f(4)`, "[8]"},
+	})
+
+	// a million calls in tail position run in constant Go stack space and
+	// with a single frame on the call stack
+	defer debug.SetMaxStack(debug.SetMaxStack(8 << 20))
+	depth := 0
+	e := New()
+	e.Hook = func(e *Evaluator, stmt ast.Statement, env *object.Environment) *object.Error {
+		if e.Depth() > depth {
+			depth = e.Depth()
+		}
+		return nil
+	}
+	input := `let countdown = fn(n) { if (n == 0) { "done" } else { countdown(n - 1) } };
+countdown(1000000)`
+	program, _ := Compile(input)
+	if result := program.RunWith(e, object.NewEnvironment()); result.Inspect() != "done" {
+		t.Errorf("wrong result of the countdown: %s", result.Inspect())
+	}
+	if depth != 2 {
+		t.Errorf("wrong maximum depth of the call stack. expected=2, got=%d", depth)
+	}
+
+	r := &recorder{}
+	e = New()
+	e.Tracer = r
+	e.Eval(parser.New(lexer.New(`let f = fn(n) { if (n > 0) { f(n - 1) } else { n } }; f(1)`)).ParseProgram(),
+		object.NewEnvironment())
+	expected := []string{
+		"enter main depth=1",
+		"enter f depth=2",
+		"exit f tail call f((n - 1))",
+		"enter f depth=2",
+		"exit f 0",
+		"exit main 0",
+	}
+	if strings.Join(r.events, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong events.\nexpected=%q\ngot=%q", expected, r.events)
+	}
+}
+
+func BenchmarkPush(b *testing.B) {
+	// building a list by pushing takes O(n log n) time
+	for _, n := range []int{100, 1000, 10000} {
+		program, _ := Compile(fmt.Sprintf(`let build = fn(arr, n) {
+  if (n == 0) { arr } else { build(push(arr, n), n - 1) }
+};
+let drain = fn(arr) { if (len(arr) == 0) { 0 } else { drain(rest(arr)) } };
+drain(build([], %d))`, n))
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				program.Run(object.NewEnvironment())
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package evaluator
+
+import (
+	"math"
+	"math/big"
+
+	"github.com/lycheng/monkey-go/object"
+)
+
+// Integers overflowing an int64 are promoted to big integers, and results
+// fitting in an int64 are demoted back, so an integer value always has a
+// single representation.
+
+// isInteger reports whether obj is an integer or a big integer
+func isInteger(obj object.Object) bool {
+	return obj.Type() == object.INTEGER || obj.Type() == object.BIGINT
+}
+
+// toBig returns the value of an integer or a big integer as a new big.Int
+func toBig(obj object.Object) *big.Int {
+	if i, ok := obj.(*object.BigInteger); ok {
+		return new(big.Int).Set(i.Value)
+	}
+	return big.NewInt(obj.(*object.Integer).Value)
+}
+
+// fromBig returns n as an integer when it fits, a big integer otherwise
+func fromBig(n *big.Int) object.Object {
+	if n.IsInt64() {
+		return &object.Integer{Value: n.Int64()}
+	}
+	return &object.BigInteger{Value: n}
+}
+
+// evalIntegerArithmetic computes +, -, * and / on int64 values, falling back
+// to big integers on overflow
+func evalIntegerArithmetic(operator string, a, b int64) object.Object {
+	switch operator {
+	case "+":
+		if sum := a + b; (sum > a) == (b > 0) {
+			return &object.Integer{Value: sum}
+		}
+	case "-":
+		if diff := a - b; (diff < a) == (b > 0) {
+			return &object.Integer{Value: diff}
+		}
+	case "*":
+		if a == 0 || b == 0 {
+			return &object.Integer{Value: 0}
+		}
+		if product := a * b; product/b == a && !(a == -1 && b == math.MinInt64) && !(b == -1 && a == math.MinInt64) {
+			return &object.Integer{Value: product}
+		}
+	case "/":
+		if b == 0 {
+			return newError("division by zero")
+		}
+		if !(a == math.MinInt64 && b == -1) {
+			return &object.Integer{Value: a / b}
+		}
+	}
+	return evalBigArithmetic(operator, big.NewInt(a), big.NewInt(b))
+}
+
+// evalBigArithmetic computes +, -, * and / on big integers, the division
+// truncates toward zero like the one of int64 values
+func evalBigArithmetic(operator string, a, b *big.Int) object.Object {
+	result := new(big.Int)
+	switch operator {
+	case "+":
+		result.Add(a, b)
+	case "-":
+		result.Sub(a, b)
+	case "*":
+		result.Mul(a, b)
+	case "/":
+		if b.Sign() == 0 {
+			return newError("division by zero")
+		}
+		result.Quo(a, b)
+	}
+	return fromBig(result)
+}
+
+// evalBigIntegerInfixExpression evaluates an infix expression with a big
+// integer operand
+func evalBigIntegerInfixExpression(operator string, left, right object.Object) object.Object {
+	a, b := toBig(left), toBig(right)
+	switch operator {
+	case "+", "-", "*", "/":
+		return evalBigArithmetic(operator, a, b)
+	case "<":
+		return nativeBoolToBooleanObject(a.Cmp(b) < 0)
+	case ">":
+		return nativeBoolToBooleanObject(a.Cmp(b) > 0)
+	case "==":
+		return nativeBoolToBooleanObject(a.Cmp(b) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(a.Cmp(b) != 0)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
@@ -0,0 +1,95 @@
+package evaluator
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/lycheng/monkey-go/object"
+)
+
+// Policy is the capabilities granted by the host to the built-in functions
+// reaching the operating system. The zero Policy denies everything.
+type Policy struct {
+	// Roots are the directories whose files may be accessed
+	Roots []string
+	// ReadOnly denies writing to the files of the roots
+	ReadOnly bool
+	// Env are the names of the environment variables which may be read, "*"
+	// allows all of them
+	Env []string
+	// Commands are the names of the commands which may be executed
+	Commands []string
+}
+
+// policyOf returns the policy of the evaluator calling a built-in function,
+// the zero policy when it has none
+func policyOf(caller object.Caller) *Policy {
+	if e, ok := caller.(*Evaluator); ok && e.Policy != nil {
+		return e.Policy
+	}
+	return &Policy{}
+}
+
+// denied returns the error of a built-in function denied by the policy
+func denied(name, format string, a ...interface{}) *object.Error {
+	return newError("`%s` denied: %s", name, fmt.Sprintf(format, a...))
+}
+
+// checkPath returns the absolute path of path when the policy lets the
+// built-in function name access it. Symbolic links are resolved so they
+// don't lead out of the roots.
+func (p *Policy) checkPath(name, path string, write bool) (string, *object.Error) {
+	if write && p.ReadOnly {
+		return "", denied(name, "the file system is read-only")
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", newError("%s: %s", name, err)
+	}
+	resolved := resolveLinks(abs)
+	for _, root := range p.Roots {
+		root, err := filepath.Abs(root)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(resolveLinks(root), resolved)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return abs, nil
+		}
+	}
+	return "", denied(name, "%s is outside the allowed directories", path)
+}
+
+// resolveLinks resolves the symbolic links of the longest existing parent
+// of the absolute path
+func resolveLinks(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	dir := filepath.Dir(path)
+	if dir == path {
+		return path
+	}
+	return filepath.Join(resolveLinks(dir), filepath.Base(path))
+}
+
+// checkEnv returns an error unless the environment variable may be read
+func (p *Policy) checkEnv(name, variable string) *object.Error {
+	for _, allowed := range p.Env {
+		if allowed == "*" || allowed == variable {
+			return nil
+		}
+	}
+	return denied(name, "environment variable %s is not allowed", variable)
+}
+
+// checkCommand returns an error unless the command may be executed
+func (p *Policy) checkCommand(name, command string) *object.Error {
+	for _, allowed := range p.Commands {
+		if allowed == command {
+			return nil
+		}
+	}
+	return denied(name, "command %s is not allowed", command)
+}
@@ -0,0 +1,56 @@
+// Package evaluator evaluates Monkey programs by walking their AST.
+//
+// A program is parsed once into a Program and run many times, possibly at
+// once on several goroutines: the Program, the built-in functions and the
+// objects other than environments are never modified after they are
+// created. Every run gets an Evaluator and an Environment of its own, the
+// environment may be shared by the goroutines spawned by the run.
+package evaluator
+
+import (
+	"github.com/lycheng/monkey-go/ast"
+	"github.com/lycheng/monkey-go/lexer"
+	"github.com/lycheng/monkey-go/object"
+	"github.com/lycheng/monkey-go/parser"
+	"github.com/lycheng/monkey-go/resolver"
+)
+
+// Program is a program prepared for evaluation, it is safe for concurrent
+// use
+type Program struct {
+	program *ast.Program
+}
+
+// Compile parses src into a program, the parse errors are returned when src
+// doesn't parse
+func Compile(src string) (*Program, []*parser.Error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.ErrorList()) != 0 {
+		return nil, p.ErrorList()
+	}
+	return Prepare(program), nil
+}
+
+// Prepare resolves the variables of an AST and returns its program, the
+// AST must not be modified afterwards
+func Prepare(program *ast.Program) *Program {
+	resolver.Resolve(program)
+	return &Program{program: program}
+}
+
+// AST returns the AST of the program, it must not be modified
+func (p *Program) AST() *ast.Program {
+	return p.program
+}
+
+// Run evaluates the program in env with a new evaluator
+func (p *Program) Run(env *object.Environment) object.Object {
+	return p.RunWith(New(), env)
+}
+
+// RunWith evaluates the program in env with the evaluator e, which must
+// not run another program at once
+func (p *Program) RunWith(e *Evaluator, env *object.Environment) object.Object {
+	return e.Eval(p.program, env)
+}
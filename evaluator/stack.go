@@ -0,0 +1,152 @@
+package evaluator
+
+import (
+	"context"
+
+	"github.com/lycheng/monkey-go/ast"
+	"github.com/lycheng/monkey-go/object"
+	"github.com/lycheng/monkey-go/token"
+)
+
+// Evaluator holds the state of an evaluation, the call stack and the hooks
+// observing it. An Evaluator must not be used by several goroutines at once,
+// the functions spawned by a program run on evaluators of their own.
+type Evaluator struct {
+	// Hook is called before each statement when it is not nil
+	Hook Hook
+	// Tracer observes the calls and errors when it is not nil
+	Tracer Tracer
+	// Coverage observes the statements and branches when it is not nil
+	Coverage Coverage
+	// Policy grants the access to the files, environment variables and
+	// commands, everything is denied when it is nil
+	Policy *Policy
+	// Clock tells the time and sleeps, the system clock when it is nil
+	Clock Clock
+	// Random is the source of random and seed, one seeded with the time is
+	// set on first use when it is nil
+	Random *Random
+	// Context cancels the functions waiting, like sleep and recv, they are
+	// not cancelled when it is nil
+	Context context.Context
+
+	frames  []*Frame
+	stopped *object.Error // error of the hook stopping the evaluation
+}
+
+// New returns an evaluator without hooks
+func New() *Evaluator {
+	return &Evaluator{}
+}
+
+// Hook is called before the statement stmt is evaluated in env. The
+// evaluation stops with the returned error when it is not nil.
+type Hook func(e *Evaluator, stmt ast.Statement, env *object.Environment) *object.Error
+
+// Tracer observes an evaluation. Enter and Exit are called around the
+// program and every call of a Monkey or built-in function, Error when an
+// error is raised. A frame replaced by a call in tail position exits with a
+// nil result and its TailCall set.
+type Tracer interface {
+	Enter(e *Evaluator, frame *Frame)
+	Exit(e *Evaluator, frame *Frame, result object.Object)
+	Error(e *Evaluator, err *object.Error)
+}
+
+// Coverage observes the statements and branches reached by an evaluation.
+// Statement is called before a statement is evaluated, Branch after the
+// condition of an if expression, consequence tells the branch taken.
+type Coverage interface {
+	Statement(stmt ast.Statement)
+	Branch(ie *ast.IfExpression, consequence bool)
+}
+
+// Frame is an entry of the call stack
+type Frame struct {
+	Function *object.Function    // nil for the program and built-in functions
+	Builtin  *object.Builtin     // nil unless a built-in function is called
+	Call     *ast.CallExpression // call site, nil for the program
+	Env      *object.Environment // nil for built-in functions
+	Pos      token.Position      // position of the statement being evaluated
+	TailCall *ast.CallExpression // call in tail position replacing the frame
+}
+
+// Name returns the name of a built-in or declared function, or the name a
+// Monkey function literal was called by, "main" for the program
+func (f *Frame) Name() string {
+	if f.Builtin != nil {
+		return f.Builtin.Name
+	}
+	if f.Function != nil && f.Function.Name != "" {
+		return f.Function.Name
+	}
+	if f.Call == nil {
+		return "main"
+	}
+	if ident, ok := f.Call.Function.(*ast.Identifier); ok {
+		return ident.Value
+	}
+	return "fn"
+}
+
+// Call calls a Monkey or built-in function with the arguments, it lets
+// built-in functions call back into Monkey code
+func (e *Evaluator) Call(fn object.Object, args ...object.Object) object.Object {
+	return e.applyFunction(fn, args, nil)
+}
+
+// Stack returns the call stack, the program frame comes first
+func (e *Evaluator) Stack() []*Frame {
+	frames := make([]*Frame, len(e.frames))
+	copy(frames, e.frames)
+	return frames
+}
+
+// Depth returns the number of frames on the call stack
+func (e *Evaluator) Depth() int {
+	return len(e.frames)
+}
+
+func (e *Evaluator) push(frame *Frame) *Frame {
+	e.frames = append(e.frames, frame)
+	if e.Tracer != nil {
+		e.Tracer.Enter(e, frame)
+	}
+	return frame
+}
+
+func (e *Evaluator) pop(frame *Frame, result object.Object) {
+	if e.Tracer != nil {
+		e.Tracer.Exit(e, frame, result)
+	}
+	e.frames = e.frames[:len(e.frames)-1]
+}
+
+// located sets the position of node on an error raised by it and reports
+// the error, errors raised deeper already have a position
+func (e *Evaluator) located(obj object.Object, node ast.Node) object.Object {
+	if err, ok := obj.(*object.Error); ok && !err.Pos.IsValid() {
+		err.Pos = node.Pos()
+		if e.Tracer != nil {
+			e.Tracer.Error(e, err)
+		}
+	}
+	return obj
+}
+
+// before records the position of the statement in the current frame and
+// runs the observers
+func (e *Evaluator) before(stmt ast.Statement, env *object.Environment) *object.Error {
+	e.frames[len(e.frames)-1].Pos = stmt.Pos()
+	if e.Coverage != nil {
+		e.Coverage.Statement(stmt)
+	}
+	if e.Hook == nil {
+		return nil
+	}
+	if err := e.Hook(e, stmt, env); err != nil {
+		e.stopped = err
+		return err
+	}
+	return nil
+}
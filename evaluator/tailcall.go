@@ -0,0 +1,101 @@
+package evaluator
+
+import (
+	"github.com/lycheng/monkey-go/ast"
+	"github.com/lycheng/monkey-go/object"
+)
+
+// Recursion is the only way to loop, so the calls of Monkey functions in
+// tail position don't grow the Go stack: the body of a function returns
+// them unevaluated and applyFunction makes them in a loop, in place of the
+// frame of the calling function. A call is in tail position when it is
+// returned by a return statement or it is the final expression of the body,
+// through the branches of if expressions.
+
+// tailCall is a call of a Monkey function left to the function applying the
+// body it was returned by
+type tailCall struct {
+	fn   *object.Function
+	args []object.Object
+	call *ast.CallExpression
+}
+
+// Type is part of the object interface, tail calls are never seen by
+// Monkey code
+func (tc *tailCall) Type() object.Type { return "TAIL_CALL" }
+
+// Inspect returns the description of the tail call
+func (tc *tailCall) Inspect() string { return "tail call of " + tc.call.Function.String() }
+
+// evalTail evaluates a node of a function body, final tells whether the
+// value of the node is the value of the body. The calls in tail position
+// are returned as tail calls.
+func (e *Evaluator) evalTail(node ast.Node, env *object.Environment, final bool) object.Object {
+	switch node := node.(type) {
+	case *ast.BlockStatement:
+		var result object.Object
+		e.declareFunctions(node.Statements, env)
+		for i, statement := range node.Statements {
+			if err := e.before(statement, env); err != nil {
+				return err
+			}
+			result = e.evalTail(statement, env, final && i == len(node.Statements)-1)
+
+			if result != nil && (result.Type() == object.RETURNVALUE || result.Type() == object.ERROR) {
+				return result
+			}
+		}
+		return result
+	case *ast.ExpressionStatement:
+		return e.evalTail(node.Expression, env, final)
+	case *ast.ReturnStatement:
+		call, ok := node.ReturnValue.(*ast.CallExpression)
+		if !ok {
+			return e.eval(node, env)
+		}
+		val := e.evalTailCall(call, env)
+		if isError(val) {
+			return val
+		}
+		return &object.ReturnValue{Value: val}
+	case *ast.IfExpression:
+		condition := e.eval(node.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		truthy := isTruthy(condition)
+		if e.Coverage != nil {
+			e.Coverage.Branch(node, truthy)
+		}
+		if truthy {
+			return e.evalTail(node.Consequence, env, final)
+		} else if node.Alternative != nil {
+			return e.evalTail(node.Alternative, env, final)
+		}
+		return nullObj
+	case *ast.CallExpression:
+		if final {
+			return e.evalTailCall(node, env)
+		}
+	}
+	return e.eval(node, env)
+}
+
+// evalTailCall returns the tail call of a Monkey function, the other
+// functions are called at once
+func (e *Evaluator) evalTailCall(call *ast.CallExpression, env *object.Environment) object.Object {
+	fn := e.eval(call.Function, env)
+	if isError(fn) {
+		return fn
+	}
+
+	args := e.evalExpressions(call.Arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+
+	if fn, ok := fn.(*object.Function); ok {
+		return &tailCall{fn: fn, args: args, call: call}
+	}
+	return e.located(e.applyFunction(fn, args, call), call)
+}
@@ -0,0 +1,127 @@
+package evaluator
+
+import (
+	"context"
+	"time"
+
+	"github.com/lycheng/monkey-go/object"
+)
+
+// Clock tells the time to the time built-in functions and makes them wait,
+// tests replace the system clock by a fake one
+type Clock interface {
+	Now() time.Time
+	// Sleep waits for d, or returns the error of ctx when it is done first
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+// SystemClock is the clock of the operating system
+type SystemClock struct{}
+
+// Now returns the current time
+func (SystemClock) Now() time.Time { return time.Now() }
+
+// Sleep waits for d unless ctx is done first
+func (SystemClock) Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// clockOf returns the clock of the evaluator calling a built-in function
+func clockOf(caller object.Caller) Clock {
+	if e, ok := caller.(*Evaluator); ok && e.Clock != nil {
+		return e.Clock
+	}
+	return SystemClock{}
+}
+
+// contextOf returns the context of the evaluator calling a built-in
+// function, it cancels the functions waiting
+func contextOf(caller object.Caller) context.Context {
+	if e, ok := caller.(*Evaluator); ok && e.Context != nil {
+		return e.Context
+	}
+	return context.Background()
+}
+
+// evalTimeInfixExpression evaluates an infix expression with a time or a
+// duration operand: times and durations add up, times subtract into
+// durations, durations scale by integers and compare with each other
+func evalTimeInfixExpression(operator string, left, right object.Object) (object.Object, bool) {
+	switch left := left.(type) {
+	case *object.Time:
+		switch right := right.(type) {
+		case *object.Duration:
+			switch operator {
+			case "+":
+				return &object.Time{Value: left.Value.Add(right.Value)}, true
+			case "-":
+				return &object.Time{Value: left.Value.Add(-right.Value)}, true
+			}
+		case *object.Time:
+			switch operator {
+			case "-":
+				return &object.Duration{Value: left.Value.Sub(right.Value)}, true
+			case "<":
+				return nativeBoolToBooleanObject(left.Value.Before(right.Value)), true
+			case ">":
+				return nativeBoolToBooleanObject(left.Value.After(right.Value)), true
+			case "==":
+				return nativeBoolToBooleanObject(left.Value.Equal(right.Value)), true
+			case "!=":
+				return nativeBoolToBooleanObject(!left.Value.Equal(right.Value)), true
+			}
+		}
+	case *object.Duration:
+		switch right := right.(type) {
+		case *object.Time:
+			if operator == "+" {
+				return &object.Time{Value: right.Value.Add(left.Value)}, true
+			}
+		case *object.Duration:
+			switch operator {
+			case "+", "-":
+				return durationArithmetic(operator, left, right, int64(left.Value), int64(right.Value)), true
+			case "/":
+				return evalIntegerArithmetic(operator, int64(left.Value), int64(right.Value)), true
+			case "<":
+				return nativeBoolToBooleanObject(left.Value < right.Value), true
+			case ">":
+				return nativeBoolToBooleanObject(left.Value > right.Value), true
+			case "==":
+				return nativeBoolToBooleanObject(left.Value == right.Value), true
+			case "!=":
+				return nativeBoolToBooleanObject(left.Value != right.Value), true
+			}
+		case *object.Integer:
+			switch operator {
+			case "*", "/":
+				return durationArithmetic(operator, left, right, int64(left.Value), right.Value), true
+			}
+		}
+	case *object.Integer:
+		if right, ok := right.(*object.Duration); ok && operator == "*" {
+			return durationArithmetic(operator, left, right, left.Value, int64(right.Value)), true
+		}
+	}
+	return nil, false
+}
+
+// durationArithmetic computes a duration from the operands, the int64 values
+// of left and right, or returns an error when it overflows
+func durationArithmetic(operator string, left, right object.Object, a, b int64) object.Object {
+	result := evalIntegerArithmetic(operator, a, b)
+	if n, ok := result.(*object.Integer); ok {
+		return &object.Duration{Value: time.Duration(n.Value)}
+	}
+	if isError(result) {
+		return result
+	}
+	return newError("duration overflow: %s %s %s", left.Inspect(), operator, right.Inspect())
+}
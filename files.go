@@ -0,0 +1,44 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sourceExt is the file extension of Monkey source files
+const sourceExt = ".mk"
+
+// sourceFiles expands the paths to Monkey source files,
+// directories are walked recursively for files ending with sourceExt
+func sourceFiles(paths []string, match func(name string) bool) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && match(d.Name()) {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func isSourceFile(name string) bool {
+	return strings.HasSuffix(name, sourceExt)
+}
@@ -0,0 +1,481 @@
+// Package format implements the canonical formatting of Monkey source code.
+//
+// The output uses four spaces for indentation, puts every statement on its
+// own line, drops redundant parentheses and breaks argument, array and hash
+// lists which do not fit in maxWidth columns one item per line. Comments and
+// single blank lines between statements are kept. Comments written inside an
+// expression are moved after the statement which contains them.
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/lycheng/monkey-go/ast"
+	"github.com/lycheng/monkey-go/lexer"
+	"github.com/lycheng/monkey-go/parser"
+	"github.com/lycheng/monkey-go/token"
+)
+
+const (
+	indentUnit = "    "
+	maxWidth   = 80
+
+	// primary is the precedence of expressions that never need parentheses
+	primary = parser.INDEX + 1
+)
+
+// Source formats src in the canonical style.
+// It returns the first parse error if src can not be parsed.
+func Source(src []byte) ([]byte, error) {
+	l := lexer.New(string(src))
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if errs := p.ErrorList(); len(errs) != 0 {
+		return nil, errs[0]
+	}
+
+	pr := newSourcePrinter(string(src), program.Comments)
+	pr.stmts(program.Statements, 0, len(src))
+	if pr.buf.Len() > 0 {
+		pr.buf.WriteByte('\n')
+	}
+	return pr.buf.Bytes(), nil
+}
+
+// Node writes the canonical form of node to w.
+// There is no source for node, so comments and blank lines are not printed.
+func Node(w io.Writer, node ast.Node) error {
+	pr := &printer{}
+	switch node := node.(type) {
+	case *ast.Program:
+		pr.stmts(node.Statements, 0, 0)
+		if pr.buf.Len() > 0 {
+			pr.buf.WriteByte('\n')
+		}
+	case ast.Statement:
+		pr.stmt(node, nil)
+	case ast.Expression:
+		pr.expr(node, parser.LOWEST)
+	default:
+		return fmt.Errorf("format: unsupported node %T", node)
+	}
+	_, err := w.Write(pr.buf.Bytes())
+	return err
+}
+
+type printer struct {
+	buf    bytes.Buffer
+	indent int
+	col    int  // column of the output, 0 at the start of a line
+	flat   bool // never break lists, used for measuring
+
+	// the following fields are only set when printing source
+	hasSource   bool
+	tokens      []token.Token       // tokens except comments
+	occupied    map[int]bool        // lines holding a token or a comment
+	closing     map[int]token.Token // offset of { to the matching }
+	comments    []*ast.Comment
+	trailing    []bool // comment follows a token on the same line
+	emitted     []bool
+	lastLine    int  // source line of the last printed element
+	first       bool // nothing printed yet in the current statement list
+	lineComment bool // a comment has been written on the output line
+}
+
+func newSourcePrinter(src string, comments []*ast.Comment) *printer {
+	p := &printer{
+		hasSource: true,
+		occupied:  make(map[int]bool),
+		closing:   make(map[int]token.Token),
+		comments:  comments,
+		trailing:  make([]bool, len(comments)),
+		emitted:   make([]bool, len(comments)),
+		first:     true,
+	}
+
+	var braces []token.Token
+	l := lexer.New(src)
+	for tk := l.NextToken(); tk.Type != token.EOF; tk = l.NextToken() {
+		p.tokens = append(p.tokens, tk)
+		for line := tk.Pos.Line; line <= endLine(tk); line++ {
+			p.occupied[line] = true
+		}
+		switch tk.Type {
+		case token.LBRACE:
+			braces = append(braces, tk)
+		case token.RBRACE:
+			if n := len(braces); n > 0 {
+				p.closing[braces[n-1].Pos.Offset] = tk
+				braces = braces[:n-1]
+			}
+		}
+	}
+
+	for i, c := range comments {
+		p.occupied[c.Pos().Line] = true
+		if tk, ok := p.tokenBefore(c.Pos().Offset); ok {
+			p.trailing[i] = endLine(tk) == c.Pos().Line
+		}
+	}
+	return p
+}
+
+// endLine returns the line where the token ends, strings may span lines
+func endLine(tk token.Token) int {
+	return tk.Pos.Line + strings.Count(tk.Literal, "\n")
+}
+
+// tokenBefore returns the last token which starts before offset
+func (p *printer) tokenBefore(offset int) (token.Token, bool) {
+	i := sort.Search(len(p.tokens), func(i int) bool {
+		return p.tokens[i].Pos.Offset >= offset
+	})
+	if i == 0 {
+		return token.Token{}, false
+	}
+	return p.tokens[i-1], true
+}
+
+// blankBetween reports whether there is an empty source line between
+// the lines from and to
+func (p *printer) blankBetween(from, to int) bool {
+	if !p.hasSource {
+		return false
+	}
+	for line := from + 1; line < to; line++ {
+		if !p.occupied[line] {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *printer) write(s string) {
+	if s == "" {
+		return
+	}
+	if p.col == 0 {
+		ind := strings.Repeat(indentUnit, p.indent)
+		p.buf.WriteString(ind)
+		p.col = len(ind)
+	}
+	p.buf.WriteString(s)
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		p.col = len(s) - i - 1
+	} else {
+		p.col += len(s)
+	}
+}
+
+func (p *printer) newline(blank bool) {
+	if p.buf.Len() == 0 {
+		return
+	}
+	p.buf.WriteByte('\n')
+	if blank {
+		p.buf.WriteByte('\n')
+	}
+	p.col = 0
+	p.lineComment = false
+}
+
+// element starts a new line for a statement or a comment starting at line
+func (p *printer) element(line int) {
+	p.newline(!p.first && p.blankBetween(p.lastLine, line))
+	p.first = false
+}
+
+// flushComments prints the comments within the source range [from, to)
+// which have not been printed yet
+func (p *printer) flushComments(from, to int) {
+	for i, c := range p.comments {
+		offset := c.Pos().Offset
+		if p.emitted[i] || offset < from {
+			continue
+		}
+		if offset >= to {
+			break
+		}
+		p.emitted[i] = true
+		line := c.Pos().Line
+		if p.trailing[i] && p.col > 0 && !p.lineComment {
+			p.write(" ")
+		} else {
+			p.element(line)
+		}
+		p.write(c.Token.Literal)
+		p.lineComment = true
+		if line > p.lastLine {
+			p.lastLine = line
+		}
+	}
+}
+
+// stmts prints a statement list, from and to are the source offsets of the
+// region holding the list
+func (p *printer) stmts(list []ast.Statement, from, to int) {
+	for i, s := range list {
+		var next ast.Statement
+		boundary := to
+		if i+1 < len(list) {
+			next = list[i+1]
+			boundary = next.Pos().Offset
+		}
+		if p.hasSource {
+			p.flushComments(from, s.Pos().Offset)
+		}
+		p.element(s.Pos().Line)
+		p.stmt(s, next)
+		if p.hasSource {
+			if tk, ok := p.tokenBefore(boundary); ok {
+				p.lastLine = endLine(tk)
+			}
+			from = s.Pos().Offset
+		}
+	}
+	if p.hasSource {
+		p.flushComments(from, to)
+	}
+}
+
+func (p *printer) stmt(s ast.Statement, next ast.Statement) {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		p.write("let " + s.Name.Value + " = ")
+		p.expr(s.Value, parser.LOWEST)
+		p.write(";")
+	case *ast.ReturnStatement:
+		p.write("return")
+		if s.ReturnValue != nil {
+			p.write(" ")
+			p.expr(s.ReturnValue, parser.LOWEST)
+		}
+		p.write(";")
+	case *ast.ExpressionStatement:
+		p.expr(s.Expression, parser.LOWEST)
+		// a statement ending with } is only terminated when the next one
+		// would otherwise continue it
+		if !endsWithBlock(s.Expression) || continues(next) {
+			p.write(";")
+		}
+	case *ast.FunctionStatement:
+		p.expr(s.Function, parser.LOWEST)
+	case *ast.BlockStatement:
+		p.block(s)
+	}
+}
+
+func endsWithBlock(e ast.Expression) bool {
+	switch e := e.(type) {
+	case *ast.IfExpression, *ast.FunctionLiteral:
+		return true
+	case *ast.InfixExpression:
+		return endsWithBlock(e.Right)
+	case *ast.PrefixExpression:
+		return endsWithBlock(e.Right)
+	}
+	return false
+}
+
+// continues reports whether s starts with a token which could be parsed as
+// the operator of an infix, call or index expression
+func continues(s ast.Statement) bool {
+	if s == nil {
+		return false
+	}
+	var buf bytes.Buffer
+	if err := Node(&buf, s); err != nil || buf.Len() == 0 {
+		return false
+	}
+	switch buf.Bytes()[0] {
+	case '(', '[', '-':
+		return true
+	}
+	return false
+}
+
+func (p *printer) block(b *ast.BlockStatement) {
+	from, to := b.Pos().Offset, b.Pos().Offset
+	hasComments := false
+	if p.hasSource {
+		if rbrace, ok := p.closing[from]; ok {
+			to = rbrace.Pos.Offset
+		}
+		for _, c := range p.comments {
+			if o := c.Pos().Offset; o > from && o < to {
+				hasComments = true
+				break
+			}
+		}
+	}
+	if len(b.Statements) == 0 && !hasComments {
+		p.write("{}")
+		return
+	}
+
+	p.write("{")
+	p.indent++
+	p.first = true
+	p.lastLine = b.Pos().Line
+	p.stmts(b.Statements, from, to)
+	p.indent--
+	p.newline(false)
+	p.write("}")
+}
+
+func precedence(e ast.Expression) int {
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(e.Token.Type)
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	}
+	return primary
+}
+
+// expr prints e, wrapped by parentheses if it binds looser than prec
+func (p *printer) expr(e ast.Expression, prec int) {
+	if precedence(e) < prec {
+		p.write("(")
+		p.expr(e, parser.LOWEST)
+		p.write(")")
+		return
+	}
+
+	switch e := e.(type) {
+	case *ast.Identifier:
+		p.write(e.Value)
+	case *ast.IntegerLiteral:
+		p.write(e.Token.Literal)
+	case *ast.Boolean:
+		p.write(fmt.Sprintf("%t", e.Value))
+	case *ast.StringLiteral:
+		p.write(`"` + e.Value + `"`)
+	case *ast.PrefixExpression:
+		p.write(e.Operator)
+		p.expr(e.Right, parser.PREFIX)
+	case *ast.InfixExpression:
+		prec := precedence(e)
+		p.expr(e.Left, prec)
+		p.write(" " + e.Operator + " ")
+		p.expr(e.Right, prec+1)
+	case *ast.IfExpression:
+		p.write("if (")
+		p.expr(e.Condition, parser.LOWEST)
+		p.write(") ")
+		p.block(e.Consequence)
+		if e.Alternative != nil {
+			p.write(" else ")
+			p.block(e.Alternative)
+		}
+	case *ast.FunctionLiteral:
+		p.write("fn")
+		if e.Name != nil {
+			p.write(" " + e.Name.Value)
+		}
+		p.list("(", ")", len(e.Parameters), func(q *printer, i int) {
+			q.write(e.Parameters[i].Value)
+		})
+		p.write(" ")
+		p.block(e.Body)
+	case *ast.CallExpression:
+		p.expr(e.Function, parser.CALL)
+		p.list("(", ")", len(e.Arguments), func(q *printer, i int) {
+			q.expr(e.Arguments[i], parser.LOWEST)
+		})
+	case *ast.ArrayLiteral:
+		p.list("[", "]", len(e.Elements), func(q *printer, i int) {
+			q.expr(e.Elements[i], parser.LOWEST)
+		})
+	case *ast.IndexExpression:
+		p.expr(e.Left, parser.CALL)
+		p.write("[")
+		p.expr(e.Index, parser.LOWEST)
+		p.write("]")
+	case *ast.HashLiteral:
+		keys := sortedKeys(e)
+		p.list("{", "}", len(keys), func(q *printer, i int) {
+			q.expr(keys[i], parser.LOWEST)
+			q.write(": ")
+			q.expr(e.Pairs[keys[i]], parser.LOWEST)
+		})
+	}
+}
+
+// sortedKeys returns the keys of a hash literal in source order
+func sortedKeys(h *ast.HashLiteral) []ast.Expression {
+	keys := make([]ast.Expression, 0, len(h.Pairs))
+	for k := range h.Pairs {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		oi, oj := keys[i].Pos().Offset, keys[j].Pos().Offset
+		if oi != oj {
+			return oi < oj
+		}
+		return keys[i].String() < keys[j].String()
+	})
+	return keys
+}
+
+// list prints n items between open and close. The items are put on one
+// line when it fits in maxWidth, otherwise each of them gets its own line.
+func (p *printer) list(open, close string, n int, item func(q *printer, i int)) {
+	if n == 0 {
+		p.write(open + close)
+		return
+	}
+	if p.flat || p.fits(open, close, n, item) {
+		p.write(open)
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				p.write(", ")
+			}
+			item(p, i)
+		}
+		p.write(close)
+		return
+	}
+
+	p.write(open)
+	p.indent++
+	for i := 0; i < n; i++ {
+		p.newline(false)
+		item(p, i)
+		if i < n-1 {
+			p.write(",")
+		}
+	}
+	p.indent--
+	p.newline(false)
+	p.write(close)
+}
+
+// fits reports whether the first line of the list fits in maxWidth when
+// the list is printed on one line
+func (p *printer) fits(open, close string, n int, item func(q *printer, i int)) bool {
+	q := &printer{indent: p.indent, col: p.col, flat: true}
+	if q.col == 0 {
+		q.col = len(indentUnit) * q.indent
+	}
+	start := q.col
+	q.buf.WriteString(strings.Repeat(" ", start))
+	q.write(open)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			q.write(", ")
+		}
+		item(q, i)
+	}
+	q.write(close)
+
+	out := q.buf.String()
+	if i := strings.IndexByte(out, '\n'); i >= 0 {
+		out = out[:i]
+	}
+	return len(out) <= maxWidth
+}
@@ -0,0 +1,216 @@
+package format
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/lycheng/monkey-go/lexer"
+	"github.com/lycheng/monkey-go/parser"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=5", "let x = 5;\n"},
+		{"((1 + 2)) * (3 + 4)", "(1 + 2) * (3 + 4);\n"},
+		{"1 + (2 * 3)", "1 + 2 * 3;\n"},
+		{"1 - (2 - 3)", "1 - (2 - 3);\n"},
+		{"(1 - 2) - 3", "1 - 2 - 3;\n"},
+		{"-(a + b)", "-(a + b);\n"},
+		{"(-a) + b", "-a + b;\n"},
+		{"!(true == false)", "!(true == false);\n"},
+		{"(-f)(x)", "(-f)(x);\n"},
+		{"(a + b)[0]", "(a + b)[0];\n"},
+		{"add(1,2)[0]", "add(1, 2)[0];\n"},
+		{"let s = \"hi\" + \" there\";", "let s = \"hi\" + \" there\";\n"},
+		{`{"b": 2, "a": 1}`, "{\"b\": 2, \"a\": 1};\n"},
+		{"{}", "{};\n"},
+		{"[]", "[];\n"},
+		{
+			"let add = fn(a,b){a+b};",
+			"let add = fn(a, b) {\n    a + b;\n};\n",
+		},
+		{
+			"fn(){}",
+			"fn() {}\n",
+		},
+		{
+			"if (x > 1) { return x; } else { let y = 2; y }",
+			"if (x > 1) {\n    return x;\n} else {\n    let y = 2;\n    y;\n}\n",
+		},
+		{
+			"if (x) { 1 }; -1",
+			"if (x) {\n    1;\n};\n-1;\n",
+		},
+		{
+			"if (x) { 1 }; y",
+			"if (x) {\n    1;\n}\ny;\n",
+		},
+		{
+			"let f = fn(x) { fn(y) { x + y } };",
+			"let f = fn(x) {\n    fn(y) {\n        x + y;\n    }\n};\n",
+		},
+		{
+			"let numbers = [1111111111, 2222222222, 3333333333, 4444444444, 5555555555, 6666666666];",
+			`let numbers = [
+    1111111111,
+    2222222222,
+    3333333333,
+    4444444444,
+    5555555555,
+    6666666666
+];
+`,
+		},
+		{
+			"fn add(a,b){a+b}; -1",
+			"fn add(a, b) {\n    a + b;\n}\n-1;\n",
+		},
+		{
+			"map(arr, fn(x) { x * 2 })",
+			"map(arr, fn(x) {\n    x * 2;\n});\n",
+		},
+	}
+	for _, tt := range tests {
+		out, err := Source([]byte(tt.input))
+		if err != nil {
+			t.Errorf("Source(%q) returned error: %s", tt.input, err)
+			continue
+		}
+		if string(out) != tt.expected {
+			t.Errorf("Source(%q) wrong.\nexpected=%q\ngot=%q", tt.input, tt.expected, out)
+		}
+	}
+}
+
+func TestSourceComments(t *testing.T) {
+	input := `// Package header
+
+
+let x = 1;  // one
+// about y
+let y = 2;
+
+
+
+let f = fn(a) { // opening
+  // inside
+
+  a
+  // closing
+};
+puts(f(x)) // done
+// end`
+
+	expected := `// Package header
+
+let x = 1; // one
+// about y
+let y = 2;
+
+let f = fn(a) { // opening
+    // inside
+
+    a;
+    // closing
+};
+puts(f(x)); // done
+// end
+`
+	out, err := Source([]byte(input))
+	if err != nil {
+		t.Fatalf("Source returned error: %s", err)
+	}
+	if string(out) != expected {
+		t.Errorf("Source wrong.\nexpected=\n%s\ngot=\n%s", expected, out)
+	}
+}
+
+func TestSourceIdempotent(t *testing.T) {
+	inputs := []string{
+		`let a = {"k": fn(x) { x }, // trailing
+  "v": [1,
+  // in array
+  2]};
+a`,
+		`if (a) { b } else { c } // one // two
+// three`,
+		`let long = fn(aaaaaaaaaa, bbbbbbbbbb, cccccccccc, dddddddddd, eeeeeeeeee, ffffff) { aaaaaaaaaa };`,
+		`let nested = [[1111111111, 2222222222, 3333333333], [4444444444, 5555555555, 6666666666, 7777777777]];`,
+		"let s = \"multi\nline\";\n\n\nputs(s)",
+	}
+	for _, input := range inputs {
+		once, err := Source([]byte(input))
+		if err != nil {
+			t.Errorf("Source(%q) returned error: %s", input, err)
+			continue
+		}
+		twice, err := Source(once)
+		if err != nil {
+			t.Errorf("Source(%q) returned error: %s", once, err)
+			continue
+		}
+		if !bytes.Equal(once, twice) {
+			t.Errorf("formatting is not idempotent.\nfirst=\n%s\nsecond=\n%s", once, twice)
+		}
+	}
+}
+
+func TestSourceKeepsMeaning(t *testing.T) {
+	inputs := []string{
+		"a + b * c + d / e - f",
+		"-a * b",
+		"!-a",
+		"a * [1, 2, 3, 4][b * c] * d",
+		"add(a + b + c * d / f + g)",
+		"if (x) { 1 }; (y)",
+		"if (x) { 1 }; [1][0]",
+		"fn(x) { x }(5)",
+		"let f = fn() { if (a < b) { return a } else { return b } }; f()",
+		"(1 < 2) == (3 > 4)",
+	}
+	for _, input := range inputs {
+		out, err := Source([]byte(input))
+		if err != nil {
+			t.Errorf("Source(%q) returned error: %s", input, err)
+			continue
+		}
+		if got, want := parse(t, string(out)), parse(t, input); got != want {
+			t.Errorf("formatting changed meaning of %q.\nexpected=%s\ngot=%s", input, want, got)
+		}
+	}
+}
+
+func TestSourceParseError(t *testing.T) {
+	if _, err := Source([]byte("let x = ;")); err == nil {
+		t.Errorf("expected error for invalid source")
+	}
+}
+
+func TestNode(t *testing.T) {
+	l := lexer.New("let x = fn(a) { (a + 1) * 2 };")
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	var buf bytes.Buffer
+	if err := Node(&buf, program.Statements[0]); err != nil {
+		t.Fatalf("Node returned error: %s", err)
+	}
+	expected := "let x = fn(a) {\n    (a + 1) * 2;\n};"
+	if buf.String() != expected {
+		t.Errorf("Node wrong. expected=%q, got=%q", expected, buf.String())
+	}
+}
+
+func parse(t *testing.T, input string) string {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %s", input, strings.Join(p.Errors(), ", "))
+	}
+	return program.String()
+}
@@ -0,0 +1,50 @@
+package framing
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReadWrite(t *testing.T) {
+	var buf bytes.Buffer
+	for _, body := range []string{`{"seq":1}`, "", `{"text":"é"}`} {
+		if err := Write(&buf, []byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := bufio.NewReader(&buf)
+	for _, expected := range []string{`{"seq":1}`, "", `{"text":"é"}`} {
+		body, err := Read(r)
+		if err != nil {
+			t.Fatalf("Read failed: %s", err)
+		}
+		if string(body) != expected {
+			t.Errorf("wrong body. expected=%q, got=%q", expected, body)
+		}
+	}
+	if _, err := Read(r); err != io.EOF {
+		t.Errorf("expected io.EOF after the messages, got %v", err)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Content-Length: -1\r\n\r\n", `invalid Content-Length "-1"`},
+		{"Content-Length: 9223372036854775807\r\n\r\n", `invalid Content-Length "9223372036854775807"`},
+		{"Content-Length: 67108865\r\n\r\n", `invalid Content-Length "67108865"`},
+		{"Content-Type: json\r\n\r\n{}", `invalid Content-Length ""`},
+		{"Content-Length: 10\r\n\r\n{}", "unexpected EOF"},
+	}
+	for _, tt := range tests {
+		_, err := Read(bufio.NewReader(strings.NewReader(tt.input)))
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error for %q. expected=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package lexer
 
 import (
+	"strings"
+
 	"github.com/lycheng/monkey-go/token"
 )
 
@@ -10,19 +12,33 @@ type Lexer struct {
 	currPos int // current position of input
 	nextPos int // next position of input
 	ch      byte
+
+	line      int // line of the current char
+	lineStart int // offset of the first char of the current line
+
+	comments []token.Token
 }
 
 // New return new Lexer object with input string
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
 
+// Comments returns the comments skipped so far, in source order
+func (l *Lexer) Comments() []token.Token {
+	return l.comments
+}
+
 // NextToken returns next token from the input
 func (l *Lexer) NextToken() token.Token {
 	l.skipWhitespace()
-	tk := token.Token{Literal: string(l.ch)}
+	for l.ch == '/' && l.peekChar() == '/' {
+		l.comments = append(l.comments, l.readComment())
+		l.skipWhitespace()
+	}
+	tk := token.Token{Literal: string(l.ch), Pos: l.pos()}
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
@@ -93,6 +109,25 @@ func (l *Lexer) NextToken() token.Token {
 	return tk
 }
 
+func (l *Lexer) pos() token.Position {
+	return token.Position{
+		Offset: l.currPos,
+		Line:   l.line,
+		Column: l.currPos - l.lineStart + 1,
+	}
+}
+
+// readComment reads a line comment, the leading // is kept in the literal
+func (l *Lexer) readComment() token.Token {
+	tk := token.Token{Type: token.COMMENT, Pos: l.pos()}
+	i := l.currPos
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+	tk.Literal = strings.TrimRight(l.input[i:l.currPos], "\r")
+	return tk
+}
+
 func (l *Lexer) readString() string {
 	i := l.currPos + 1
 	for {
@@ -105,6 +140,10 @@ func (l *Lexer) readString() string {
 }
 
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.lineStart = l.nextPos
+	}
 	if l.nextPos >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -167,3 +167,89 @@ func TestNextTokenWithIdentsAndKeywords(t *testing.T) {
 		}
 	}
 }
+
+func TestNextTokenPositions(t *testing.T) {
+	input := "let x = 5;\n  x + \"a\nb\";\nfoo"
+
+	tests := []struct {
+		expectedType   token.Type
+		expectedLine   int
+		expectedColumn int
+	}{
+		{token.LET, 1, 1},
+		{token.IDENT, 1, 5},
+		{token.ASSIGN, 1, 7},
+		{token.INT, 1, 9},
+		{token.SEMICOLON, 1, 10},
+		{token.IDENT, 2, 3},
+		{token.PLUS, 2, 5},
+		{token.STRING, 2, 7},
+		{token.SEMICOLON, 3, 3},
+		{token.IDENT, 4, 1},
+		{token.EOF, 4, 4},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tk := l.NextToken()
+		if tk.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tk.Type)
+		}
+		if tk.Pos.Line != tt.expectedLine || tk.Pos.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - position wrong. expected=%d:%d, got=%s",
+				i, tt.expectedLine, tt.expectedColumn, tk.Pos)
+		}
+	}
+}
+
+func TestNextTokenSkipsComments(t *testing.T) {
+	input := `// leading
+let x = 5; // trailing
+// a / b
+x / 2`
+
+	tests := []struct {
+		expectedType    token.Type
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tk := l.NextToken()
+		if tk.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tk.Type)
+		}
+		if tk.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tk.Literal)
+		}
+	}
+
+	comments := l.Comments()
+	expected := []string{"// leading", "// trailing", "// a / b"}
+	if len(comments) != len(expected) {
+		t.Fatalf("wrong number of comments. expected=%d, got=%d",
+			len(expected), len(comments))
+	}
+	for i, c := range comments {
+		if c.Type != token.COMMENT || c.Literal != expected[i] {
+			t.Errorf("comments[%d] wrong. expected=%q, got=%q (%s)",
+				i, expected[i], c.Literal, c.Type)
+		}
+	}
+	if comments[1].Pos.Line != 2 || comments[1].Pos.Column != 12 {
+		t.Errorf("comments[1] position wrong. got=%s", comments[1].Pos)
+	}
+}
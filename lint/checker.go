@@ -0,0 +1,224 @@
+package lint
+
+import (
+	"fmt"
+
+	"github.com/lycheng/monkey-go/ast"
+	"github.com/lycheng/monkey-go/evaluator"
+)
+
+// binding for a name bound by let, by a function declaration or by a
+// function parameter
+type binding struct {
+	ident *ast.Identifier // the first declaration
+	param bool
+	used  bool
+	lets  int                  // number of let statements and function declarations binding the name
+	fn    *ast.FunctionLiteral // the value when bound once to a function literal
+}
+
+// scope for the names of the program or of a function body.
+// Blocks of if expressions share the scope of the enclosing function.
+type scope struct {
+	outer    *scope
+	global   bool
+	names    map[string]*binding
+	order    []*binding
+	deferred []*ast.FunctionLiteral
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{outer: outer, names: make(map[string]*binding)}
+}
+
+func (s *scope) lookup(name string) *binding {
+	for sc := s; sc != nil; sc = sc.outer {
+		if b, ok := sc.names[name]; ok {
+			return b
+		}
+	}
+	return nil
+}
+
+type checker struct {
+	builtins map[string]bool
+	scope    *scope
+	issues   []Issue
+	defs     map[*ast.Identifier]*ast.Identifier // use to declaration
+}
+
+func newChecker() *checker {
+	c := &checker{
+		builtins: make(map[string]bool),
+		defs:     make(map[*ast.Identifier]*ast.Identifier),
+	}
+	for _, name := range evaluator.BuiltinNames() {
+		c.builtins[name] = true
+	}
+	return c
+}
+
+func (c *checker) report(node ast.Node, rule, format string, a ...interface{}) {
+	c.issues = append(c.issues, Issue{
+		Pos:     node.Pos(),
+		Rule:    rule,
+		Message: fmt.Sprintf(format, a...),
+	})
+}
+
+func (c *checker) checkProgram(program *ast.Program) {
+	c.scope = newScope(nil)
+	c.scope.global = true
+	c.statements(program.Statements)
+	c.closeScope()
+}
+
+// closeScope checks the bodies of the function literals found in the
+// scope, they run after the enclosing code so every name of the scope is
+// visible to them, then it reports the unused bindings
+func (c *checker) closeScope() {
+	s := c.scope
+	for i := 0; i < len(s.deferred); i++ {
+		c.function(s.deferred[i])
+	}
+	if !s.global {
+		for _, b := range s.order {
+			if !b.param && !b.used && b.ident.Value[0] != '_' {
+				c.report(b.ident, Unused, "%s declared but not used", b.ident.Value)
+			}
+		}
+	}
+	c.scope = s.outer
+}
+
+func (c *checker) function(fn *ast.FunctionLiteral) {
+	c.scope = newScope(c.scope)
+	for _, param := range fn.Parameters {
+		if c.builtins[param.Value] {
+			c.report(param, ShadowBuiltin, "parameter %s shadows built-in function", param.Value)
+		}
+		b := &binding{ident: param, param: true}
+		c.scope.names[param.Value] = b
+		c.scope.order = append(c.scope.order, b)
+	}
+	c.statements(fn.Body.Statements)
+	c.closeScope()
+}
+
+func (c *checker) statements(stmts []ast.Statement) {
+	// the functions declared in a block are bound when the block is entered
+	for _, stmt := range stmts {
+		if fs, ok := stmt.(*ast.FunctionStatement); ok {
+			c.declare("fn", fs.Function.Name, fs.Function)
+		}
+	}
+	returned := false
+	for _, stmt := range stmts {
+		if returned {
+			c.report(stmt, Unreachable, "unreachable code after return")
+			returned = false
+		}
+		c.statement(stmt)
+		if _, ok := stmt.(*ast.ReturnStatement); ok {
+			returned = true
+		}
+	}
+}
+
+func (c *checker) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		c.expression(stmt.Value)
+		c.declare("let", stmt.Name, stmt.Value)
+	case *ast.FunctionStatement:
+		c.expression(stmt.Function)
+	case *ast.ReturnStatement:
+		c.expression(stmt.ReturnValue)
+	case *ast.ExpressionStatement:
+		c.expression(stmt.Expression)
+	case *ast.BlockStatement:
+		c.statements(stmt.Statements)
+	}
+}
+
+// declare binds ident to value in the current scope, keyword tells the
+// statement binding it
+func (c *checker) declare(keyword string, ident *ast.Identifier, value ast.Expression) {
+	name := ident.Value
+	if c.builtins[name] {
+		c.report(ident, ShadowBuiltin, "%s %s shadows built-in function", keyword, name)
+	}
+	b, ok := c.scope.names[name]
+	if !ok {
+		b = &binding{ident: ident}
+		c.scope.names[name] = b
+		c.scope.order = append(c.scope.order, b)
+	}
+	b.lets++
+	b.fn = nil
+	if fn, ok := value.(*ast.FunctionLiteral); ok && b.lets == 1 && !b.param {
+		b.fn = fn
+	}
+}
+
+func (c *checker) expression(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		if b := c.scope.lookup(exp.Value); b != nil {
+			b.used = true
+			c.defs[exp] = b.ident
+		} else if !c.builtins[exp.Value] {
+			c.report(exp, Undefined, "undefined: %s", exp.Value)
+		}
+	case *ast.PrefixExpression:
+		c.expression(exp.Right)
+	case *ast.InfixExpression:
+		c.expression(exp.Left)
+		c.expression(exp.Right)
+	case *ast.IfExpression:
+		c.expression(exp.Condition)
+		c.statements(exp.Consequence.Statements)
+		if exp.Alternative != nil {
+			c.statements(exp.Alternative.Statements)
+		}
+	case *ast.FunctionLiteral:
+		c.scope.deferred = append(c.scope.deferred, exp)
+	case *ast.CallExpression:
+		c.expression(exp.Function)
+		for _, arg := range exp.Arguments {
+			c.expression(arg)
+		}
+		c.checkArity(exp)
+	case *ast.ArrayLiteral:
+		for _, el := range exp.Elements {
+			c.expression(el)
+		}
+	case *ast.IndexExpression:
+		c.expression(exp.Left)
+		c.expression(exp.Index)
+	case *ast.HashLiteral:
+		for key, value := range exp.Pairs {
+			c.expression(key)
+			c.expression(value)
+		}
+	}
+}
+
+func (c *checker) checkArity(call *ast.CallExpression) {
+	var fn *ast.FunctionLiteral
+	name := "function literal"
+	switch f := call.Function.(type) {
+	case *ast.FunctionLiteral:
+		fn = f
+	case *ast.Identifier:
+		if b := c.scope.lookup(f.Value); b != nil {
+			fn = b.fn
+			name = f.Value
+		}
+	}
+	if fn == nil || len(fn.Parameters) == len(call.Arguments) {
+		return
+	}
+	c.report(call, Arity, "%s called with %d arguments, want %d",
+		name, len(call.Arguments), len(fn.Parameters))
+}
@@ -0,0 +1,136 @@
+// Package lint implements static checks over a Monkey AST.
+//
+// Issues can be suppressed for a single line with a comment on the same or
+// the previous line, or for the whole file:
+//
+//	// lint:ignore unused,shadow-builtin
+//	// lint:file-ignore undefined
+//
+// A suppression comment without rule IDs suppresses all rules.
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lycheng/monkey-go/ast"
+	"github.com/lycheng/monkey-go/token"
+)
+
+// Rule IDs
+const (
+	Undefined     = "undefined"
+	ShadowBuiltin = "shadow-builtin"
+	Unused        = "unused"
+	Unreachable   = "unreachable"
+	Arity         = "arity"
+)
+
+// Rule describes a check
+type Rule struct {
+	ID          string
+	Description string
+}
+
+// Rules lists all checks in the order they are documented
+var Rules = []Rule{
+	{Undefined, "identifier is neither bound nor a built-in function"},
+	{ShadowBuiltin, "let binding, function declaration or parameter hides a built-in function"},
+	{Unused, "let binding inside a function is never used"},
+	{Unreachable, "statement follows a return statement in the same block"},
+	{Arity, "function literal is called with the wrong number of arguments"},
+}
+
+// Issue is a problem reported by a rule
+type Issue struct {
+	Pos     token.Position
+	Rule    string
+	Message string
+}
+
+// String returns the issue as line:column: message (rule)
+func (i Issue) String() string {
+	return fmt.Sprintf("%s: %s (%s)", i.Pos, i.Message, i.Rule)
+}
+
+// Config for a lint run
+type Config struct {
+	// Disabled rule IDs are not reported
+	Disabled map[string]bool
+}
+
+const (
+	ignoreDirective     = "lint:ignore"
+	fileIgnoreDirective = "lint:file-ignore"
+	allRules            = "*"
+)
+
+// Check runs all enabled rules over the program and returns the issues
+// sorted by position
+func Check(program *ast.Program, config Config) []Issue {
+	c := newChecker()
+	c.checkProgram(program)
+
+	lines, file := suppressions(program.Comments)
+	issues := []Issue{}
+	for _, issue := range c.issues {
+		if config.Disabled[issue.Rule] || file[issue.Rule] || file[allRules] {
+			continue
+		}
+		if rules := lines[issue.Pos.Line]; rules[issue.Rule] || rules[allRules] {
+			continue
+		}
+		issues = append(issues, issue)
+	}
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Pos.Offset < issues[j].Pos.Offset
+	})
+	return issues
+}
+
+// Definitions resolves the identifiers used in the program to the
+// identifier declaring them, which is the name of the first let statement
+// binding it in the scope or a function parameter. Built-in and undefined
+// identifiers are left out.
+func Definitions(program *ast.Program) map[*ast.Identifier]*ast.Identifier {
+	c := newChecker()
+	c.checkProgram(program)
+	return c.defs
+}
+
+// suppressions collects the rules ignored per line and for the whole file
+func suppressions(comments []*ast.Comment) (map[int]map[string]bool, map[string]bool) {
+	lines := make(map[int]map[string]bool)
+	file := make(map[string]bool)
+	for _, c := range comments {
+		text := strings.TrimSpace(strings.TrimPrefix(c.Token.Literal, "//"))
+		switch {
+		case strings.HasPrefix(text, fileIgnoreDirective):
+			for _, rule := range parseRules(text[len(fileIgnoreDirective):]) {
+				file[rule] = true
+			}
+		case strings.HasPrefix(text, ignoreDirective):
+			line := c.Pos().Line
+			for _, l := range []int{line, line + 1} {
+				if lines[l] == nil {
+					lines[l] = make(map[string]bool)
+				}
+				for _, rule := range parseRules(text[len(ignoreDirective):]) {
+					lines[l][rule] = true
+				}
+			}
+		}
+	}
+	return lines, file
+}
+
+func parseRules(s string) []string {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+	if len(fields) == 0 {
+		return []string{allRules}
+	}
+	return fields
+}
@@ -0,0 +1,132 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/lycheng/monkey-go/lexer"
+	"github.com/lycheng/monkey-go/parser"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1; x + y", []string{"1:16: undefined: y (undefined)"}},
+		{"let x = x;", []string{"1:9: undefined: x (undefined)"}},
+		{"puts(len([1]))", []string{}},
+		{
+			"let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(3)",
+			[]string{},
+		},
+		{
+			"let f = fn() { g() }; let g = fn() { 1 }; f()",
+			[]string{},
+		},
+		{"let len = fn(x) { 0 };", []string{"1:5: let len shadows built-in function (shadow-builtin)"}},
+		{"fn(first) { first }", []string{"1:4: parameter first shadows built-in function (shadow-builtin)"}},
+		{"let f = fn() { let a = 1; 2 }; f()", []string{"1:20: a declared but not used (unused)"}},
+		{"let f = fn() { let _a = 1; 2 }; f()", []string{}},
+		{"let top = 1;", []string{}},
+		{
+			"let f = fn(x) { return x; puts(x); x }; f(1)",
+			[]string{"1:27: unreachable code after return (unreachable)"},
+		},
+		{
+			"let add = fn(a, b) { a + b }; add(1)",
+			[]string{"1:31: add called with 1 arguments, want 2 (arity)"},
+		},
+		{
+			"fn(a) { a }(1, 2)",
+			[]string{"1:1: function literal called with 2 arguments, want 1 (arity)"},
+		},
+		{
+			"let f = fn(a) { a }; let f = fn(a, b) { a }; f(1, 2)",
+			[]string{},
+		},
+		{
+			"fn f() { let x = even(2); x; fn even(n) { if (n == 0) { true } else { odd(n - 1) } } fn odd(n) { !even(n) } }",
+			[]string{},
+		},
+		{
+			"fn f() { fn g() { 1 } 2 }",
+			[]string{"1:13: g declared but not used (unused)"},
+		},
+		{
+			"add(1); fn add(a, b) { a + b }",
+			[]string{"1:1: add called with 1 arguments, want 2 (arity)"},
+		},
+		{"fn len(x) { 0 }", []string{"1:4: fn len shadows built-in function (shadow-builtin)"}},
+	}
+	for _, tt := range tests {
+		issues := check(t, tt.input, Config{})
+		if len(issues) != len(tt.expected) {
+			t.Errorf("wrong number of issues for %q. expected=%d, got=%d (%v)",
+				tt.input, len(tt.expected), len(issues), issues)
+			continue
+		}
+		for i, issue := range issues {
+			if issue.String() != tt.expected[i] {
+				t.Errorf("issues[%d] wrong for %q. expected=%q, got=%q",
+					i, tt.input, tt.expected[i], issue.String())
+			}
+		}
+	}
+}
+
+func TestSuppression(t *testing.T) {
+	tests := []struct {
+		input    string
+		config   Config
+		expected int
+	}{
+		{"x; y", Config{}, 2},
+		{"x; y", Config{Disabled: map[string]bool{Undefined: true}}, 0},
+		{"x; // lint:ignore undefined\ny", Config{}, 0},
+		{"x; // lint:ignore unused\ny", Config{}, 2},
+		{"// lint:ignore\nx;\ny", Config{}, 1},
+		{"x;\n// lint:file-ignore unused, undefined\ny", Config{}, 0},
+	}
+	for _, tt := range tests {
+		issues := check(t, tt.input, tt.config)
+		if len(issues) != tt.expected {
+			t.Errorf("wrong number of issues for %q. expected=%d, got=%d (%v)",
+				tt.input, tt.expected, len(issues), issues)
+		}
+	}
+}
+
+func check(t *testing.T, input string, config Config) []Issue {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return Check(program, config)
+}
+
+func TestDefinitions(t *testing.T) {
+	input := `let x = 1;
+let f = fn(x) { x + y };
+let y = x;`
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	defs := Definitions(program)
+
+	expected := map[string]string{
+		"2:17": "2:12", // x in the body is the parameter
+		"2:21": "3:5",  // y is bound later in the global scope
+		"3:9":  "1:5",
+	}
+	if len(defs) != len(expected) {
+		t.Fatalf("wrong number of definitions. expected=%d, got=%d", len(expected), len(defs))
+	}
+	for use, def := range defs {
+		if want, ok := expected[use.Pos().String()]; !ok || def.Pos().String() != want {
+			t.Errorf("wrong definition for %s at %s. expected=%s, got=%s",
+				use.Value, use.Pos(), want, def.Pos())
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package lsp
+
+import (
+	"sort"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/lycheng/monkey-go/ast"
+	"github.com/lycheng/monkey-go/lexer"
+	"github.com/lycheng/monkey-go/lint"
+	"github.com/lycheng/monkey-go/parser"
+	"github.com/lycheng/monkey-go/token"
+)
+
+// document is an open text document and the result of its analysis
+type document struct {
+	uri     string
+	text    string
+	lines   []int // offsets of the line starts
+	program *ast.Program
+	errors  []*parser.Error
+	tokens  []token.Token // tokens and comments in source order
+	closing map[int]int   // offset of { to the offset of the matching }
+
+	idents []*ast.Identifier // identifiers in source order
+	defs   map[*ast.Identifier]*ast.Identifier
+	lets   map[*ast.Identifier]*ast.LetStatement    // let name to statement
+	funcs  map[*ast.Identifier]*ast.FunctionLiteral // declared function name to function
+	params map[*ast.Identifier]*ast.FunctionLiteral // parameter to function
+}
+
+func newDocument(uri, text string) *document {
+	d := &document{
+		uri:     uri,
+		text:    text,
+		lines:   []int{0},
+		closing: make(map[int]int),
+		lets:    make(map[*ast.Identifier]*ast.LetStatement),
+		funcs:   make(map[*ast.Identifier]*ast.FunctionLiteral),
+		params:  make(map[*ast.Identifier]*ast.FunctionLiteral),
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			d.lines = append(d.lines, i+1)
+		}
+	}
+
+	p := parser.New(lexer.New(text))
+	d.program = p.ParseProgram()
+	d.errors = p.ErrorList()
+	d.defs = lint.Definitions(d.program)
+
+	var braces []int
+	l := lexer.New(text)
+	for tk := l.NextToken(); tk.Type != token.EOF; tk = l.NextToken() {
+		d.tokens = append(d.tokens, tk)
+		switch tk.Type {
+		case token.LBRACE:
+			braces = append(braces, tk.Pos.Offset)
+		case token.RBRACE:
+			if n := len(braces); n > 0 {
+				d.closing[braces[n-1]] = tk.Pos.Offset
+				braces = braces[:n-1]
+			}
+		}
+	}
+	d.tokens = append(d.tokens, l.Comments()...)
+	sort.Slice(d.tokens, func(i, j int) bool {
+		return d.tokens[i].Pos.Offset < d.tokens[j].Pos.Offset
+	})
+
+	d.collect()
+	sort.Slice(d.idents, func(i, j int) bool {
+		return d.idents[i].Pos().Offset < d.idents[j].Pos().Offset
+	})
+	return d
+}
+
+// collect records the identifiers of the program, with the let statements,
+// function declarations and functions binding them
+func (d *document) collect() {
+	ast.Inspect(d.program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.Identifier:
+			d.idents = append(d.idents, node)
+		case *ast.LetStatement:
+			d.lets[node.Name] = node
+		case *ast.FunctionLiteral:
+			if node.Name != nil {
+				d.funcs[node.Name] = node
+			}
+			for _, param := range node.Parameters {
+				d.params[param] = node
+			}
+		}
+		return true
+	})
+}
+
+// identAt returns the identifier under or right after offset
+func (d *document) identAt(offset int) *ast.Identifier {
+	i := sort.Search(len(d.idents), func(i int) bool {
+		return d.idents[i].Pos().Offset > offset
+	})
+	if i == 0 {
+		return nil
+	}
+	ident := d.idents[i-1]
+	if start := ident.Pos().Offset; offset <= start+len(ident.Value) {
+		return ident
+	}
+	return nil
+}
+
+// declaration returns the identifier declaring ident, which may be ident
+func (d *document) declaration(ident *ast.Identifier) *ast.Identifier {
+	if def, ok := d.defs[ident]; ok {
+		return def
+	}
+	if _, ok := d.lets[ident]; ok {
+		return ident
+	}
+	if _, ok := d.funcs[ident]; ok {
+		return ident
+	}
+	if _, ok := d.params[ident]; ok {
+		return ident
+	}
+	return nil
+}
+
+// tokenEnd returns the offset following the token
+func (d *document) tokenEnd(tk token.Token) int {
+	end := tk.Pos.Offset + len(tk.Literal)
+	if tk.Type == token.STRING {
+		end++ // opening quote
+		if end < len(d.text) && d.text[end] == '"' {
+			end++
+		}
+	}
+	return end
+}
+
+// endBefore returns the end offset of the last token, which is not a
+// comment, starting before boundary
+func (d *document) endBefore(boundary int) int {
+	i := sort.Search(len(d.tokens), func(i int) bool {
+		return d.tokens[i].Pos.Offset >= boundary
+	})
+	for i--; i >= 0; i-- {
+		if d.tokens[i].Type != token.COMMENT {
+			return d.tokenEnd(d.tokens[i])
+		}
+	}
+	return 0
+}
+
+// blockEnd returns the offset of the closing brace of a block
+func (d *document) blockEnd(block *ast.BlockStatement) int {
+	if end, ok := d.closing[block.Pos().Offset]; ok {
+		return end
+	}
+	return len(d.text)
+}
+
+// position converts a byte offset to a protocol position
+func (d *document) position(offset int) Position {
+	if offset > len(d.text) {
+		offset = len(d.text)
+	}
+	line := sort.Search(len(d.lines), func(i int) bool {
+		return d.lines[i] > offset
+	}) - 1
+	character := 0
+	for _, r := range d.text[d.lines[line]:offset] {
+		character += utf16.RuneLen(r)
+	}
+	return Position{Line: line, Character: character}
+}
+
+// offset converts a protocol position to a byte offset
+func (d *document) offset(pos Position) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(d.lines) {
+		return len(d.text)
+	}
+	offset := d.lines[pos.Line]
+	for character := 0; character < pos.Character && offset < len(d.text); {
+		r, size := utf8.DecodeRuneInString(d.text[offset:])
+		if r == '\n' {
+			break
+		}
+		character += utf16.RuneLen(r)
+		offset += size
+	}
+	return offset
+}
+
+func (d *document) rangeOf(start, end int) Range {
+	return Range{Start: d.position(start), End: d.position(end)}
+}
+
+func (d *document) identRange(ident *ast.Identifier) Range {
+	start := ident.Pos().Offset
+	return d.rangeOf(start, start+len(ident.Value))
+}
@@ -0,0 +1,359 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lycheng/monkey-go/ast"
+	"github.com/lycheng/monkey-go/evaluator"
+	"github.com/lycheng/monkey-go/token"
+)
+
+func (s *Server) positionParams(params json.RawMessage) (*document, int, error) {
+	var p textDocumentPositionParams
+	if err := unmarshalParams(params, &p); err != nil {
+		return nil, 0, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, 0, err
+	}
+	return doc, doc.offset(p.Position), nil
+}
+
+func (s *Server) definition(params json.RawMessage) (interface{}, error) {
+	doc, offset, err := s.positionParams(params)
+	if err != nil {
+		return nil, err
+	}
+	ident := doc.identAt(offset)
+	if ident == nil {
+		return nil, nil
+	}
+	decl := doc.declaration(ident)
+	if decl == nil {
+		return nil, nil
+	}
+	return Location{URI: doc.uri, Range: doc.identRange(decl)}, nil
+}
+
+func (s *Server) hover(params json.RawMessage) (interface{}, error) {
+	doc, offset, err := s.positionParams(params)
+	if err != nil {
+		return nil, err
+	}
+	ident := doc.identAt(offset)
+	if ident == nil {
+		return nil, nil
+	}
+
+	var text string
+	if decl := doc.declaration(ident); decl != nil {
+		if let, ok := doc.lets[decl]; ok {
+			text = fmt.Sprintf("let %s: %s", decl.Value, doc.kindOf(let.Value, 0))
+		} else if fn, ok := doc.funcs[decl]; ok {
+			text = signature(fn)
+		} else if fn, ok := doc.params[decl]; ok {
+			text = fmt.Sprintf("parameter %s of %s", decl.Value, signature(fn))
+		}
+	} else if usage, ok := evaluator.BuiltinDoc(ident.Value); ok {
+		text = fmt.Sprintf("built-in %s\n\n%s", ident.Value, usage)
+	}
+	if text == "" {
+		return nil, nil
+	}
+	r := doc.identRange(ident)
+	return Hover{
+		Contents: MarkupContent{Kind: "plaintext", Value: text},
+		Range:    &r,
+	}, nil
+}
+
+func signature(fn *ast.FunctionLiteral) string {
+	params := []string{}
+	for _, p := range fn.Parameters {
+		params = append(params, p.Value)
+	}
+	if fn.Name != nil {
+		return "fn " + fn.Name.Value + "(" + strings.Join(params, ", ") + ")"
+	}
+	return "fn(" + strings.Join(params, ", ") + ")"
+}
+
+// maxKindDepth bounds the identifiers followed while inferring a kind
+const maxKindDepth = 8
+
+// kindOf infers the kind of the value of an expression where it can be
+// told without evaluating it
+func (d *document) kindOf(exp ast.Expression, depth int) string {
+	const unknown = "unknown"
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return "INTEGER"
+	case *ast.StringLiteral:
+		return "STRING"
+	case *ast.Boolean:
+		return "BOOLEAN"
+	case *ast.ArrayLiteral:
+		return "ARRAY"
+	case *ast.HashLiteral:
+		return "HASH"
+	case *ast.FunctionLiteral:
+		return "FUNCTION " + signature(exp)
+	case *ast.PrefixExpression:
+		if exp.Operator == "!" {
+			return "BOOLEAN"
+		}
+		return "INTEGER"
+	case *ast.InfixExpression:
+		switch exp.Operator {
+		case "==", "!=", "<", ">":
+			return "BOOLEAN"
+		}
+		left, right := d.kindOf(exp.Left, depth), d.kindOf(exp.Right, depth)
+		if left == right && (left == "INTEGER" || left == "STRING") {
+			return left
+		}
+		return unknown
+	case *ast.CallExpression:
+		if ident, ok := exp.Function.(*ast.Identifier); ok && d.declaration(ident) == nil {
+			switch ident.Value {
+			case "len":
+				return "INTEGER"
+			case "rest", "push":
+				return "ARRAY"
+			}
+		}
+		return unknown
+	case *ast.Identifier:
+		if depth >= maxKindDepth {
+			return unknown
+		}
+		if decl := d.declaration(exp); decl != nil {
+			if let, ok := d.lets[decl]; ok && let.Value != nil {
+				return d.kindOf(let.Value, depth+1)
+			}
+			if fn, ok := d.funcs[decl]; ok {
+				return "FUNCTION " + signature(fn)
+			}
+		}
+		return unknown
+	}
+	return unknown
+}
+
+func (s *Server) documentSymbol(params json.RawMessage) (interface{}, error) {
+	var p textDocumentParams
+	if err := unmarshalParams(params, &p); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	return doc.symbols(doc.program.Statements, len(doc.text)), nil
+}
+
+// symbols returns the let statements and function declarations of the
+// list, end is the offset of the end of the region holding the list
+func (d *document) symbols(stmts []ast.Statement, end int) []DocumentSymbol {
+	result := []DocumentSymbol{}
+	for i, stmt := range stmts {
+		var name *ast.Identifier
+		var value ast.Expression
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			name, value = stmt.Name, stmt.Value
+		case *ast.FunctionStatement:
+			name, value = stmt.Function.Name, stmt.Function
+		default:
+			continue
+		}
+		boundary := end
+		if i+1 < len(stmts) {
+			boundary = stmts[i+1].Pos().Offset
+		}
+		sym := DocumentSymbol{
+			Name:           name.Value,
+			Kind:           symbolVariable,
+			Range:          d.rangeOf(stmt.Pos().Offset, d.endBefore(boundary)),
+			SelectionRange: d.identRange(name),
+		}
+		if fn, ok := value.(*ast.FunctionLiteral); ok {
+			sym.Kind = symbolFunction
+			sym.Detail = signature(fn)
+			sym.Children = d.symbols(fn.Body.Statements, d.blockEnd(fn.Body))
+		}
+		result = append(result, sym)
+	}
+	return result
+}
+
+var keywords = []string{"fn", "let", "true", "false", "if", "else", "return"}
+
+func (s *Server) completion(params json.RawMessage) (interface{}, error) {
+	doc, _, err := s.positionParams(params)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []CompletionItem{}
+	seen := make(map[string]bool)
+	for _, name := range evaluator.BuiltinNames() {
+		usage, _ := evaluator.BuiltinDoc(name)
+		items = append(items, CompletionItem{
+			Label:         name,
+			Kind:          completionFunction,
+			Detail:        "built-in",
+			Documentation: usage,
+		})
+		seen[name] = true
+	}
+
+	var names []string
+	kinds := make(map[string]int)
+	for ident, let := range doc.lets {
+		kinds[ident.Value] = completionVariable
+		if _, ok := let.Value.(*ast.FunctionLiteral); ok {
+			kinds[ident.Value] = completionFunction
+		}
+	}
+	for ident := range doc.funcs {
+		kinds[ident.Value] = completionFunction
+	}
+	for ident := range doc.params {
+		if _, ok := kinds[ident.Value]; !ok {
+			kinds[ident.Value] = completionVariable
+		}
+	}
+	for name := range kinds {
+		if !seen[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		items = append(items, CompletionItem{Label: name, Kind: kinds[name]})
+	}
+
+	for _, kw := range keywords {
+		items = append(items, CompletionItem{Label: kw, Kind: completionKeyword})
+	}
+	return items, nil
+}
+
+// semantic token types and modifiers, the order is the legend
+var (
+	semanticTokenTypes = []string{
+		"keyword", "variable", "parameter", "function",
+		"string", "number", "operator", "comment",
+	}
+	semanticTokenModifiers = []string{"declaration", "defaultLibrary"}
+)
+
+const (
+	tokenKeyword = iota
+	tokenVariable
+	tokenParameter
+	tokenFunction
+	tokenString
+	tokenNumber
+	tokenOperator
+	tokenComment
+)
+
+const (
+	modDeclaration = 1 << iota
+	modDefaultLibrary
+)
+
+func (s *Server) semanticTokens(params json.RawMessage) (interface{}, error) {
+	var p textDocumentParams
+	if err := unmarshalParams(params, &p); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	idents := make(map[int]*ast.Identifier)
+	for _, ident := range doc.idents {
+		idents[ident.Pos().Offset] = ident
+	}
+
+	data := []int{}
+	prev := Position{}
+	for _, tk := range doc.tokens {
+		typ, mods, ok := doc.classify(tk, idents)
+		if !ok {
+			continue
+		}
+		start := doc.position(tk.Pos.Offset)
+		end := doc.position(doc.tokenEnd(tk))
+		if end.Line != start.Line {
+			// multiline tokens are cut at the end of the first line
+			lineEnd := len(doc.text)
+			if start.Line+1 < len(doc.lines) {
+				lineEnd = doc.lines[start.Line+1] - 1
+			}
+			end = doc.position(lineEnd)
+		}
+		deltaStart := start.Character
+		if start.Line == prev.Line {
+			deltaStart -= prev.Character
+		}
+		data = append(data, start.Line-prev.Line, deltaStart,
+			end.Character-start.Character, typ, mods)
+		prev = start
+	}
+	return SemanticTokens{Data: data}, nil
+}
+
+func (d *document) classify(tk token.Token, idents map[int]*ast.Identifier) (int, int, bool) {
+	switch tk.Type {
+	case token.LET, token.FUNCTION, token.TRUE, token.FALSE,
+		token.IF, token.ELSE, token.RETURN:
+		return tokenKeyword, 0, true
+	case token.INT:
+		return tokenNumber, 0, true
+	case token.STRING:
+		return tokenString, 0, true
+	case token.COMMENT:
+		return tokenComment, 0, true
+	case token.ASSIGN, token.PLUS, token.MINUS, token.BANG, token.SLASH,
+		token.ASTERISK, token.LT, token.GT, token.EQ, token.NOTEQ:
+		return tokenOperator, 0, true
+	case token.IDENT:
+		ident, ok := idents[tk.Pos.Offset]
+		if !ok {
+			return tokenVariable, 0, true
+		}
+		decl := d.declaration(ident)
+		if decl == nil {
+			if _, ok := evaluator.BuiltinDoc(ident.Value); ok {
+				return tokenFunction, modDefaultLibrary, true
+			}
+			return tokenVariable, 0, true
+		}
+		mods := 0
+		if decl == ident {
+			mods = modDeclaration
+		}
+		if _, ok := d.params[decl]; ok {
+			return tokenParameter, mods, true
+		}
+		if _, ok := d.funcs[decl]; ok {
+			return tokenFunction, mods, true
+		}
+		if let, ok := d.lets[decl]; ok {
+			if _, ok := let.Value.(*ast.FunctionLiteral); ok {
+				return tokenFunction, mods, true
+			}
+		}
+		return tokenVariable, mods, true
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,247 @@
+// Package lsp implements a Language Server Protocol server for Monkey.
+//
+// The server speaks JSON-RPC over a pair of streams, usually stdin and
+// stdout, and keeps the full text of the open documents. It publishes parse
+// errors and lint issues as diagnostics and answers definition, hover,
+// document symbol, completion and semantic token requests.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/lycheng/monkey-go/lint"
+)
+
+// Server for the Language Server Protocol
+type Server struct {
+	in       *bufio.Reader
+	out      io.Writer
+	docs     map[string]*document
+	shutdown bool
+}
+
+// NewServer returns a server reading requests from in and writing
+// responses and notifications to out
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:   bufio.NewReader(in),
+		out:  out,
+		docs: make(map[string]*document),
+	}
+}
+
+type handler func(s *Server, params json.RawMessage) (interface{}, error)
+
+var handlers = map[string]handler{
+	"initialize":                       (*Server).initialize,
+	"shutdown":                         (*Server).shutdownRequest,
+	"textDocument/definition":          (*Server).definition,
+	"textDocument/hover":               (*Server).hover,
+	"textDocument/documentSymbol":      (*Server).documentSymbol,
+	"textDocument/completion":          (*Server).completion,
+	"textDocument/semanticTokens/full": (*Server).semanticTokens,
+}
+
+var notifications = map[string]func(s *Server, params json.RawMessage) error{
+	"textDocument/didOpen":   (*Server).didOpen,
+	"textDocument/didChange": (*Server).didChange,
+	"textDocument/didClose":  (*Server).didClose,
+}
+
+// Run serves until the client sends the exit notification or closes the
+// input stream
+func (s *Server) Run() error {
+	for {
+		msg, err := readMessage(s.in)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			var rpcErr *rpcError
+			if errors.As(err, &rpcErr) {
+				if err := s.reply(nil, nil, rpcErr); err != nil {
+					return err
+				}
+				continue
+			}
+			return err
+		}
+		if msg.Method == "exit" {
+			return nil
+		}
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(msg *message) error {
+	if !msg.isRequest() {
+		if fn, ok := notifications[msg.Method]; ok {
+			return fn(s, msg.Params)
+		}
+		// unknown notifications such as initialized are ignored
+		return nil
+	}
+
+	fn, ok := handlers[msg.Method]
+	if !ok {
+		return s.reply(msg.ID, nil, &rpcError{
+			Code:    codeMethodNotFound,
+			Message: "method not found: " + msg.Method,
+		})
+	}
+	if s.shutdown {
+		return s.reply(msg.ID, nil, &rpcError{
+			Code:    codeInvalidRequest,
+			Message: "server is shutting down",
+		})
+	}
+	result, err := fn(s, msg.Params)
+	if err != nil {
+		var rpcErr *rpcError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &rpcError{Code: codeInternalError, Message: err.Error()}
+		}
+		return s.reply(msg.ID, nil, rpcErr)
+	}
+	return s.reply(msg.ID, result, nil)
+}
+
+func (s *Server) reply(id json.RawMessage, result interface{}, rpcErr *rpcError) error {
+	msg := &message{ID: id, Error: rpcErr}
+	if len(id) == 0 {
+		msg.ID = json.RawMessage("null")
+	}
+	if rpcErr == nil {
+		data, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		msg.Result = data
+	}
+	return writeMessage(s.out, msg)
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return writeMessage(s.out, &message{Method: method, Params: data})
+}
+
+func unmarshalParams(params json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) initialize(params json.RawMessage) (interface{}, error) {
+	result := initializeResult{}
+	result.ServerInfo.Name = "monkey-lsp"
+	result.Capabilities = serverCapabilities{
+		TextDocumentSync:       1, // full
+		DefinitionProvider:     true,
+		HoverProvider:          true,
+		DocumentSymbolProvider: true,
+		CompletionProvider:     map[string]interface{}{},
+		SemanticTokensProvider: semanticTokensOptions{
+			Legend: semanticTokensLegend{
+				TokenTypes:     semanticTokenTypes,
+				TokenModifiers: semanticTokenModifiers,
+			},
+			Full: true,
+		},
+	}
+	return result, nil
+}
+
+func (s *Server) shutdownRequest(params json.RawMessage) (interface{}, error) {
+	s.shutdown = true
+	return nil, nil
+}
+
+func (s *Server) didOpen(params json.RawMessage) error {
+	var p didOpenParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil
+	}
+	return s.update(p.TextDocument.URI, p.TextDocument.Text)
+}
+
+func (s *Server) didChange(params json.RawMessage) error {
+	var p didChangeParams
+	if err := json.Unmarshal(params, &p); err != nil || len(p.ContentChanges) == 0 {
+		return nil
+	}
+	// the server asks for full syncs, the last change holds the whole text
+	text := p.ContentChanges[len(p.ContentChanges)-1].Text
+	return s.update(p.TextDocument.URI, text)
+}
+
+func (s *Server) didClose(params json.RawMessage) error {
+	var p didCloseParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil
+	}
+	delete(s.docs, p.TextDocument.URI)
+	return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+		URI:         p.TextDocument.URI,
+		Diagnostics: []Diagnostic{},
+	})
+}
+
+func (s *Server) update(uri, text string) error {
+	doc := newDocument(uri, text)
+	s.docs[uri] = doc
+	return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: diagnostics(doc),
+	})
+}
+
+func (s *Server) document(uri string) (*document, error) {
+	doc, ok := s.docs[uri]
+	if !ok {
+		return nil, &rpcError{Code: codeInvalidParams, Message: "document not open: " + uri}
+	}
+	return doc, nil
+}
+
+// diagnostics reports the parse errors, or the lint issues when the
+// document parses
+func diagnostics(doc *document) []Diagnostic {
+	result := []Diagnostic{}
+	for _, err := range doc.errors {
+		offset := err.Pos.Offset
+		result = append(result, Diagnostic{
+			Range:    doc.rangeOf(offset, offset+1),
+			Severity: severityError,
+			Source:   "monkey",
+			Message:  err.Msg,
+		})
+	}
+	if len(result) != 0 {
+		return result
+	}
+	for _, issue := range lint.Check(doc.program, lint.Config{}) {
+		offset := issue.Pos.Offset
+		end := offset + 1
+		if ident := doc.identAt(offset); ident != nil && ident.Pos().Offset == offset {
+			end = offset + len(ident.Value)
+		}
+		result = append(result, Diagnostic{
+			Range:    doc.rangeOf(offset, end),
+			Severity: severityWarning,
+			Code:     issue.Rule,
+			Source:   "monkey-lint",
+			Message:  issue.Message,
+		})
+	}
+	return result
+}
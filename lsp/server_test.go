@@ -0,0 +1,319 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const testURI = "file:///test.mk"
+
+// session runs the server over the requests and returns the messages it
+// wrote, responses are keyed by request ID and notifications by method
+func session(t *testing.T, requests ...string) (map[string]*message, map[string][]*message) {
+	var in bytes.Buffer
+	for _, req := range requests {
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(req), req)
+	}
+	var out bytes.Buffer
+	if err := NewServer(&in, &out).Run(); err != nil {
+		t.Fatalf("server returned error: %s", err)
+	}
+
+	responses := make(map[string]*message)
+	notifications := make(map[string][]*message)
+	r := bufio.NewReader(&out)
+	for {
+		msg, err := readMessage(r)
+		if err != nil {
+			break
+		}
+		if msg.Method != "" {
+			notifications[msg.Method] = append(notifications[msg.Method], msg)
+		} else {
+			responses[string(msg.ID)] = msg
+		}
+	}
+	return responses, notifications
+}
+
+func request(id int, method string, params string) string {
+	return fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":%q,"params":%s}`, id, method, params)
+}
+
+func notification(method string, params string) string {
+	return fmt.Sprintf(`{"jsonrpc":"2.0","method":%q,"params":%s}`, method, params)
+}
+
+func didOpen(text string) string {
+	data, _ := json.Marshal(text)
+	return notification("textDocument/didOpen",
+		fmt.Sprintf(`{"textDocument":{"uri":%q,"version":1,"text":%s}}`, testURI, data))
+}
+
+func at(line, character int) string {
+	return fmt.Sprintf(`{"textDocument":{"uri":%q},"position":{"line":%d,"character":%d}}`,
+		testURI, line, character)
+}
+
+func TestInitialize(t *testing.T) {
+	responses, _ := session(t, request(1, "initialize", `{}`), request(2, "shutdown", `null`))
+	resp := responses["1"]
+	if resp == nil || resp.Error != nil {
+		t.Fatalf("initialize failed: %+v", resp)
+	}
+	var result initializeResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatalf("invalid initialize result: %s", err)
+	}
+	if !result.Capabilities.DefinitionProvider || !result.Capabilities.SemanticTokensProvider.Full {
+		t.Errorf("capabilities missing: %+v", result.Capabilities)
+	}
+	if resp := responses["2"]; resp == nil || string(resp.Result) != "null" {
+		t.Errorf("shutdown failed: %+v", resp)
+	}
+}
+
+func TestInvalidContentLength(t *testing.T) {
+	for _, length := range []string{"-1", "x", "9223372036854775807"} {
+		in := strings.NewReader("Content-Length: " + length + "\r\n\r\n{}")
+		var out bytes.Buffer
+		err := NewServer(in, &out).Run()
+		if err == nil || err.Error() != fmt.Sprintf("invalid Content-Length %q", length) {
+			t.Errorf("wrong error for Content-Length %s: %v", length, err)
+		}
+	}
+}
+
+func TestUnknownMethod(t *testing.T) {
+	responses, _ := session(t, request(1, "textDocument/unknown", `{}`))
+	resp := responses["1"]
+	if resp == nil || resp.Error == nil || resp.Error.Code != codeMethodNotFound {
+		t.Errorf("expected method not found error, got %+v", resp)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []Diagnostic
+	}{
+		{"let x = ;", []Diagnostic{{
+			Range:    Range{Start: Position{0, 8}, End: Position{0, 9}},
+			Severity: severityError,
+			Source:   "monkey",
+			Message:  "parse func for ; not found",
+		}}},
+		{"let f = fn() { let unused = 1; 2 };\nf() + y", []Diagnostic{
+			{
+				Range:    Range{Start: Position{0, 19}, End: Position{0, 25}},
+				Severity: severityWarning,
+				Code:     "unused",
+				Source:   "monkey-lint",
+				Message:  "unused declared but not used",
+			},
+			{
+				Range:    Range{Start: Position{1, 6}, End: Position{1, 7}},
+				Severity: severityWarning,
+				Code:     "undefined",
+				Source:   "monkey-lint",
+				Message:  "undefined: y",
+			},
+		}},
+		{"let x = 1; x", []Diagnostic{}},
+	}
+	for _, tt := range tests {
+		_, notifications := session(t, didOpen(tt.input))
+		msgs := notifications["textDocument/publishDiagnostics"]
+		if len(msgs) != 1 {
+			t.Fatalf("expected 1 diagnostics notification, got %d", len(msgs))
+		}
+		var params publishDiagnosticsParams
+		if err := json.Unmarshal(msgs[0].Params, &params); err != nil {
+			t.Fatalf("invalid diagnostics: %s", err)
+		}
+		if params.URI != testURI {
+			t.Errorf("wrong uri. got=%q", params.URI)
+		}
+		if fmt.Sprint(params.Diagnostics) != fmt.Sprint(tt.expected) {
+			t.Errorf("wrong diagnostics for %q.\nexpected=%+v\ngot=%+v",
+				tt.input, tt.expected, params.Diagnostics)
+		}
+	}
+}
+
+const source = `let add = fn(a, b) {
+  let sum = a + b;
+  sum
+};
+let total = add(1, 2);
+puts(len("héllo"), total);
+`
+
+func TestDefinition(t *testing.T) {
+	responses, _ := session(t,
+		didOpen(source),
+		request(1, "textDocument/definition", at(1, 12)), // a in a + b
+		request(2, "textDocument/definition", at(2, 3)),  // sum
+		request(3, "textDocument/definition", at(4, 14)), // add
+		request(4, "textDocument/definition", at(5, 1)),  // puts
+		request(5, "textDocument/definition", at(5, 21)), // total after a non ASCII string
+	)
+	expected := map[string]string{
+		"1": `{"uri":"file:///test.mk","range":{"start":{"line":0,"character":13},"end":{"line":0,"character":14}}}`,
+		"2": `{"uri":"file:///test.mk","range":{"start":{"line":1,"character":6},"end":{"line":1,"character":9}}}`,
+		"3": `{"uri":"file:///test.mk","range":{"start":{"line":0,"character":4},"end":{"line":0,"character":7}}}`,
+		"4": `null`,
+		"5": `{"uri":"file:///test.mk","range":{"start":{"line":4,"character":4},"end":{"line":4,"character":9}}}`,
+	}
+	for id, want := range expected {
+		resp := responses[id]
+		if resp == nil || string(resp.Result) != want {
+			t.Errorf("wrong definition for request %s.\nexpected=%s\ngot=%+v", id, want, resp)
+		}
+	}
+}
+
+func TestHover(t *testing.T) {
+	responses, _ := session(t,
+		didOpen(source),
+		request(1, "textDocument/hover", at(4, 5)),  // total
+		request(2, "textDocument/hover", at(0, 13)), // a
+		request(3, "textDocument/hover", at(5, 6)),  // len
+		request(4, "textDocument/hover", at(4, 12)), // add
+		request(5, "textDocument/hover", at(1, 7)),  // sum
+	)
+	expected := map[string]string{
+		"1": "let total: unknown",
+		"2": "parameter a of fn(a, b)",
+		"3": "built-in len",
+		"4": "let add: FUNCTION fn(a, b)",
+		"5": "let sum: unknown",
+	}
+	for id, want := range expected {
+		resp := responses[id]
+		var hover Hover
+		if resp == nil || json.Unmarshal(resp.Result, &hover) != nil {
+			t.Errorf("no hover for request %s: %+v", id, resp)
+			continue
+		}
+		if !strings.HasPrefix(hover.Contents.Value, want) {
+			t.Errorf("wrong hover for request %s. expected=%q, got=%q",
+				id, want, hover.Contents.Value)
+		}
+	}
+}
+
+func TestDocumentSymbol(t *testing.T) {
+	responses, _ := session(t,
+		didOpen(source),
+		request(1, "textDocument/documentSymbol", fmt.Sprintf(`{"textDocument":{"uri":%q}}`, testURI)),
+	)
+	var symbols []DocumentSymbol
+	if err := json.Unmarshal(responses["1"].Result, &symbols); err != nil {
+		t.Fatalf("invalid symbols: %s", err)
+	}
+	if len(symbols) != 2 {
+		t.Fatalf("expected 2 symbols, got %d", len(symbols))
+	}
+	add := symbols[0]
+	if add.Name != "add" || add.Kind != symbolFunction || add.Detail != "fn(a, b)" {
+		t.Errorf("wrong symbol %+v", add)
+	}
+	if add.Range != (Range{Start: Position{0, 0}, End: Position{3, 2}}) {
+		t.Errorf("wrong range for add: %+v", add.Range)
+	}
+	if len(add.Children) != 1 || add.Children[0].Name != "sum" {
+		t.Errorf("wrong children for add: %+v", add.Children)
+	}
+	if c := add.Children[0]; c.Range != (Range{Start: Position{1, 2}, End: Position{1, 18}}) {
+		t.Errorf("wrong range for sum: %+v", c.Range)
+	}
+	if total := symbols[1]; total.Name != "total" || total.Kind != symbolVariable {
+		t.Errorf("wrong symbol %+v", total)
+	}
+}
+
+func TestFunctionDeclaration(t *testing.T) {
+	src := "even(4);\nfn even(n) { if (n == 0) { true } else { !even(n - 1) } }\n"
+	responses, _ := session(t,
+		didOpen(src),
+		request(1, "textDocument/definition", at(0, 1)), // even before its declaration
+		request(2, "textDocument/hover", at(0, 1)),
+		request(3, "textDocument/documentSymbol", fmt.Sprintf(`{"textDocument":{"uri":%q}}`, testURI)),
+	)
+	want := `{"uri":"file:///test.mk","range":{"start":{"line":1,"character":3},"end":{"line":1,"character":7}}}`
+	if resp := responses["1"]; resp == nil || string(resp.Result) != want {
+		t.Errorf("wrong definition.\nexpected=%s\ngot=%+v", want, resp)
+	}
+	var hover Hover
+	if resp := responses["2"]; resp == nil || json.Unmarshal(resp.Result, &hover) != nil {
+		t.Errorf("no hover: %+v", resp)
+	} else if hover.Contents.Value != "fn even(n)" {
+		t.Errorf("wrong hover. expected=%q, got=%q", "fn even(n)", hover.Contents.Value)
+	}
+	var symbols []DocumentSymbol
+	if err := json.Unmarshal(responses["3"].Result, &symbols); err != nil {
+		t.Fatalf("invalid symbols: %s", err)
+	}
+	if len(symbols) != 1 || symbols[0].Name != "even" || symbols[0].Kind != symbolFunction ||
+		symbols[0].Detail != "fn even(n)" {
+		t.Errorf("wrong symbols %+v", symbols)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	responses, _ := session(t,
+		didOpen(source),
+		request(1, "textDocument/completion", at(5, 0)),
+	)
+	var items []CompletionItem
+	if err := json.Unmarshal(responses["1"].Result, &items); err != nil {
+		t.Fatalf("invalid completion: %s", err)
+	}
+	labels := make(map[string]int)
+	for _, item := range items {
+		labels[item.Label] = item.Kind
+	}
+	for label, kind := range map[string]int{
+		"len":   completionFunction,
+		"puts":  completionFunction,
+		"add":   completionFunction,
+		"total": completionVariable,
+		"a":     completionVariable,
+		"let":   completionKeyword,
+	} {
+		if labels[label] != kind {
+			t.Errorf("wrong completion for %s. expected kind=%d, got=%d", label, kind, labels[label])
+		}
+	}
+}
+
+func TestSemanticTokens(t *testing.T) {
+	input := "// hi\nlet f = fn(x) { x };\nlen(\"é\")"
+	responses, _ := session(t,
+		didOpen(input),
+		request(1, "textDocument/semanticTokens/full", fmt.Sprintf(`{"textDocument":{"uri":%q}}`, testURI)),
+	)
+	var tokens SemanticTokens
+	if err := json.Unmarshal(responses["1"].Result, &tokens); err != nil {
+		t.Fatalf("invalid semantic tokens: %s", err)
+	}
+	expected := []int{
+		0, 0, 5, tokenComment, 0,
+		1, 0, 3, tokenKeyword, 0,
+		0, 4, 1, tokenFunction, modDeclaration,
+		0, 2, 1, tokenOperator, 0,
+		0, 2, 2, tokenKeyword, 0,
+		0, 3, 1, tokenParameter, modDeclaration,
+		0, 5, 1, tokenParameter, 0,
+		1, 0, 3, tokenFunction, modDefaultLibrary,
+		0, 4, 3, tokenString, 0,
+	}
+	if fmt.Sprint(tokens.Data) != fmt.Sprint(expected) {
+		t.Errorf("wrong semantic tokens.\nexpected=%v\ngot=%v", expected, tokens.Data)
+	}
+}
@@ -2,13 +2,54 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/user"
 
 	"github.com/lycheng/monkey-go/repl"
 )
 
+// command for a monkey subcommand
+type command struct {
+	name  string
+	short string
+	run   func(args []string) int
+}
+
+var commands = []command{
+	{"run", "run a Monkey program", runRun},
+	{"test", "run the tests of Monkey test files", runTest},
+	{"cover", "show the coverage of an LCOV profile on the sources", runCover},
+	{"parse", "print the AST of a Monkey program", runParse},
+	{"fmt", "format Monkey source files", runFmt},
+	{"lint", "report suspicious constructs in Monkey source files", runLint},
+	{"lsp", "run the language server over stdin and stdout", runLSP},
+	{"dap", "run the debug adapter over stdin and stdout", runDAP},
+}
+
 func main() {
+	if len(os.Args) < 2 {
+		startRepl()
+		return
+	}
+
+	name, args := os.Args[1], os.Args[2:]
+	switch name {
+	case "help", "-h", "-help", "--help":
+		usage(os.Stdout)
+		return
+	}
+	for _, cmd := range commands {
+		if cmd.name == name {
+			os.Exit(cmd.run(args))
+		}
+	}
+	fmt.Fprintf(os.Stderr, "monkey: unknown command %q\n", name)
+	usage(os.Stderr)
+	os.Exit(2)
+}
+
+func startRepl() {
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
@@ -19,3 +60,11 @@ func main() {
 	fmt.Printf("Feel free to type in commands\n")
 	repl.Start(os.Stdin, os.Stdout)
 }
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: monkey [command] [arguments]\n\n")
+	fmt.Fprintf(w, "Without a command monkey starts the REPL.\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.short)
+	}
+}
@@ -0,0 +1,57 @@
+package object
+
+import "fmt"
+
+// Future for the result of a function running on its own goroutine
+type Future struct {
+	done   chan struct{}
+	result Object
+}
+
+// NewFuture returns a future waiting for its result
+func NewFuture() *Future {
+	return &Future{done: make(chan struct{})}
+}
+
+// Resolve sets the result of the future, it must be called once
+func (f *Future) Resolve(result Object) {
+	f.result = result
+	close(f.done)
+}
+
+// Done returns a channel closed when the result is set
+func (f *Future) Done() <-chan struct{} { return f.done }
+
+// Result returns the result of the future, nil until it is set
+func (f *Future) Result() Object {
+	select {
+	case <-f.done:
+		return f.result
+	default:
+		return nil
+	}
+}
+
+// Type returns FUTURE
+func (f *Future) Type() Type { return FUTURE }
+
+// Inspect tells whether the result is set
+func (f *Future) Inspect() string {
+	if f.Result() == nil {
+		return "future(pending)"
+	}
+	return "future(done)"
+}
+
+// Channel for channels passing objects between goroutines
+type Channel struct {
+	C chan Object
+}
+
+// Type returns CHANNEL
+func (c *Channel) Type() Type { return CHANNEL }
+
+// Inspect returns the number of buffered objects and the capacity
+func (c *Channel) Inspect() string {
+	return fmt.Sprintf("channel(%d/%d)", len(c.C), cap(c.C))
+}
@@ -1,8 +1,19 @@
 package object
 
-// Environment for objects map
+import (
+	"sort"
+	"sync"
+)
+
+// Environment for objects map, it is safe for concurrent use so closures
+// may run on several goroutines. The environments of the calls of resolved
+// functions hold their variables in slots, the names set by other means
+// are kept in a map.
 type Environment struct {
+	mu    sync.RWMutex
 	store map[string]Object
+	names []string // names of the slots
+	slots []Object // nil for the slots not set yet
 	outer *Environment
 }
 
@@ -19,9 +30,22 @@ func NewEnvironment() *Environment {
 	return &Environment{store: s, outer: nil}
 }
 
+// NewSlotEnvironment returns an environment enclosed by env with a slot per
+// name
+func NewSlotEnvironment(names []string, env *Environment) *Environment {
+	return &Environment{names: names, slots: make([]Object, len(names)), outer: env}
+}
+
 // Get object from map
 func (e *Environment) Get(name string) (Object, bool) {
+	e.mu.RLock()
 	obj, ok := e.store[name]
+	if !ok {
+		if slot := e.slot(name); slot >= 0 && e.slots[slot] != nil {
+			obj, ok = e.slots[slot], true
+		}
+	}
+	e.mu.RUnlock()
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.Get(name)
 	}
@@ -30,6 +54,76 @@ func (e *Environment) Get(name string) (Object, bool) {
 
 // Set object into map
 func (e *Environment) Set(name string, val Object) Object {
-	e.store[name] = val
+	e.mu.Lock()
+	if slot := e.slot(name); slot >= 0 {
+		e.slots[slot] = val
+	} else {
+		if e.store == nil {
+			e.store = make(map[string]Object)
+		}
+		e.store[name] = val
+	}
+	e.mu.Unlock()
+	return val
+}
+
+// GetSlot returns the object of the slot of the environment depth levels
+// out. Until the slot is set, name is looked up from the environment
+// enclosing that one, as Get does.
+func (e *Environment) GetSlot(depth, slot int, name string) (Object, bool) {
+	env := e
+	for ; depth > 0; depth-- {
+		env = env.outer
+	}
+	env.mu.RLock()
+	obj := env.slots[slot]
+	env.mu.RUnlock()
+	if obj != nil {
+		return obj, true
+	}
+	if env.outer == nil {
+		return nil, false
+	}
+	return env.outer.Get(name)
+}
+
+// SetSlot sets the slot of the environment
+func (e *Environment) SetSlot(slot int, val Object) Object {
+	e.mu.Lock()
+	e.slots[slot] = val
+	e.mu.Unlock()
 	return val
 }
+
+// slot returns the last slot of name, -1 when it has none
+func (e *Environment) slot(name string) int {
+	for i := len(e.names) - 1; i >= 0; i-- {
+		if e.names[i] == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Outer returns the enclosing environment, nil for the outermost one
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
+// Names returns the names set in this environment, without the ones of
+// the enclosing environments, in sorted order
+func (e *Environment) Names() []string {
+	e.mu.RLock()
+	names := make([]string, 0, len(e.store)+len(e.names))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	for i, name := range e.names {
+		if e.slots[i] != nil && e.slot(name) == i {
+			names = append(names, name)
+		}
+	}
+	e.mu.RUnlock()
+	sort.Strings(names)
+	return names
+}
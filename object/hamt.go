@@ -0,0 +1,178 @@
+package object
+
+import "math/bits"
+
+// hamtNode is a node of a persistent hash array mapped trie of hash pairs:
+// each level of the trie indexes its entries by 5 bits of the hash of the
+// keys, and an update copies the nodes on the path to the key and shares
+// the others with the previous version. The keys whose hashes collide over
+// the 64 bits are kept in a list at the bottom of the trie. Getting,
+// setting and deleting take O(log n) time. A nil node is an empty trie.
+type hamtNode struct {
+	bitmap  uint32 // of the 5 bits values having an entry
+	entries []hamtEntry
+}
+
+// hamtEntry is a pair and the hash of its key, or a node of the keys
+// sharing its bits of hash
+type hamtEntry struct {
+	key  HashKey
+	hash uint64
+	pair HashPair
+	node *hamtNode
+}
+
+const hamtBits = 5
+
+// hashOf mixes the hash key into the hash indexing the trie
+func hashOf(key HashKey) uint64 {
+	h := key.Value
+	for i := 0; i < len(key.Type); i++ {
+		h = (h ^ uint64(key.Type[i])) * 0x100000001b3
+	}
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// index returns the bit of the hash h at the level of shift and the index
+// of its entry
+func (n *hamtNode) index(h uint64, shift uint) (uint32, int) {
+	bit := uint32(1) << ((h >> shift) & (1<<hamtBits - 1))
+	return bit, bits.OnesCount32(n.bitmap & (bit - 1))
+}
+
+// get returns the pair of key, h is the hash of key
+func (n *hamtNode) get(key HashKey, h uint64, shift uint) (HashPair, bool) {
+	for n != nil {
+		if shift >= 64 {
+			for _, e := range n.entries {
+				if e.key == key {
+					return e.pair, true
+				}
+			}
+			return HashPair{}, false
+		}
+		bit, i := n.index(h, shift)
+		if n.bitmap&bit == 0 {
+			return HashPair{}, false
+		}
+		e := n.entries[i]
+		if e.node == nil {
+			return e.pair, e.key == key
+		}
+		n, shift = e.node, shift+hamtBits
+	}
+	return HashPair{}, false
+}
+
+// set returns a copy of the node with the pair of key set, and whether the
+// key is new
+func (n *hamtNode) set(key HashKey, h uint64, shift uint, pair HashPair) (*hamtNode, bool) {
+	if n == nil {
+		n = &hamtNode{}
+	}
+	if shift >= 64 {
+		for i, e := range n.entries {
+			if e.key == key {
+				return n.with(i, hamtEntry{key: key, hash: h, pair: pair}), false
+			}
+		}
+		entries := make([]hamtEntry, len(n.entries), len(n.entries)+1)
+		copy(entries, n.entries)
+		return &hamtNode{entries: append(entries, hamtEntry{key: key, hash: h, pair: pair})}, true
+	}
+	bit, i := n.index(h, shift)
+	if n.bitmap&bit == 0 {
+		entries := make([]hamtEntry, len(n.entries)+1)
+		copy(entries, n.entries[:i])
+		entries[i] = hamtEntry{key: key, hash: h, pair: pair}
+		copy(entries[i+1:], n.entries[i:])
+		return &hamtNode{bitmap: n.bitmap | bit, entries: entries}, true
+	}
+	e := n.entries[i]
+	switch {
+	case e.node != nil:
+		node, added := e.node.set(key, h, shift+hamtBits, pair)
+		return n.with(i, hamtEntry{node: node}), added
+	case e.key == key:
+		return n.with(i, hamtEntry{key: key, hash: h, pair: pair}), false
+	}
+	node, _ := (*hamtNode)(nil).set(e.key, e.hash, shift+hamtBits, e.pair)
+	node, _ = node.set(key, h, shift+hamtBits, pair)
+	return n.with(i, hamtEntry{node: node}), true
+}
+
+// delete returns a copy of the node without the pair of key, and whether
+// the key was found
+func (n *hamtNode) delete(key HashKey, h uint64, shift uint) (*hamtNode, bool) {
+	if n == nil {
+		return nil, false
+	}
+	if shift >= 64 {
+		for i, e := range n.entries {
+			if e.key == key {
+				return n.without(i, 0), true
+			}
+		}
+		return n, false
+	}
+	bit, i := n.index(h, shift)
+	if n.bitmap&bit == 0 {
+		return n, false
+	}
+	e := n.entries[i]
+	if e.node == nil {
+		if e.key != key {
+			return n, false
+		}
+		return n.without(i, bit), true
+	}
+	node, deleted := e.node.delete(key, h, shift+hamtBits)
+	switch {
+	case !deleted:
+		return n, false
+	case len(node.entries) == 0:
+		return n.without(i, bit), true
+	case len(node.entries) == 1 && node.entries[0].node == nil:
+		// a single pair moves up in place of its node
+		return n.with(i, node.entries[0]), true
+	}
+	return n.with(i, hamtEntry{node: node}), true
+}
+
+// with returns a copy of the node with the entry i replaced
+func (n *hamtNode) with(i int, e hamtEntry) *hamtNode {
+	entries := make([]hamtEntry, len(n.entries))
+	copy(entries, n.entries)
+	entries[i] = e
+	return &hamtNode{bitmap: n.bitmap, entries: entries}
+}
+
+// without returns a copy of the node without the entry i and its bit
+func (n *hamtNode) without(i int, bit uint32) *hamtNode {
+	entries := make([]hamtEntry, 0, len(n.entries)-1)
+	entries = append(entries, n.entries[:i]...)
+	entries = append(entries, n.entries[i+1:]...)
+	return &hamtNode{bitmap: n.bitmap &^ bit, entries: entries}
+}
+
+// each calls fn for the pairs of the node until it returns false
+func (n *hamtNode) each(fn func(key HashKey, pair HashPair) bool) bool {
+	if n == nil {
+		return true
+	}
+	for _, e := range n.entries {
+		if e.node != nil {
+			if !e.node.each(fn) {
+				return false
+			}
+		} else if !fn(e.key, e.pair) {
+			return false
+		}
+	}
+	return true
+}
@@ -4,9 +4,15 @@ import (
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/lycheng/monkey-go/ast"
+	"github.com/lycheng/monkey-go/token"
 )
 
 // object types
@@ -21,6 +27,13 @@ const (
 	BUILTIN     = "BUILTIN"
 	ARRAY       = "ARRAY"
 	HASH        = "HASH"
+	REGEX       = "REGEX"
+	BIGINT      = "BIGINT"
+	FLOAT       = "FLOAT"
+	TIME        = "TIME"
+	DURATION    = "DURATION"
+	FUTURE      = "FUTURE"
+	CHANNEL     = "CHANNEL"
 )
 
 // Type for object type
@@ -53,6 +66,42 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+// BigInteger for integers out of the range of Integer, the evaluator
+// promotes integers overflowing to them and demotes the results fitting in
+// an Integer
+type BigInteger struct {
+	Value *big.Int
+}
+
+// Inspect returns the decimal value of BigInteger
+func (i *BigInteger) Inspect() string { return i.Value.String() }
+
+// Type returns BIGINT
+func (i *BigInteger) Type() Type { return BIGINT }
+
+// HashKey for BigInteger type as hash type's key
+func (i *BigInteger) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write(i.Value.Bytes())
+	value := h.Sum64() << 1
+	if i.Value.Sign() < 0 {
+		value |= 1
+	}
+	return HashKey{Type: i.Type(), Value: value}
+}
+
+// Float for the numbers with a fraction read from JSON documents, the
+// evaluator doesn't compute with them
+type Float struct {
+	Value float64
+}
+
+// Inspect returns the shortest decimal value reading back as the Float
+func (f *Float) Inspect() string { return strconv.FormatFloat(f.Value, 'g', -1, 64) }
+
+// Type returns FLOAT
+func (f *Float) Type() Type { return FLOAT }
+
 // Boolean for boolean object
 type Boolean struct {
 	Value bool
@@ -116,6 +165,7 @@ func (rv *ReturnValue) Inspect() string { return rv.Value.Inspect() }
 // Error struct for eval errors
 type Error struct {
 	Message string
+	Pos     token.Position // where the error was raised, set by the evaluator
 }
 
 // Type returns the ERROR object
@@ -126,9 +176,11 @@ func (e *Error) Inspect() string { return "ERROR: " + e.Message }
 
 // Function object
 type Function struct {
+	Name       string // name of a declared function, empty for function literals
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
+	Locals     []string // names of the slots of the environments of the calls, nil when not resolved
 }
 
 // Type returns FUNCTION
@@ -142,6 +194,9 @@ func (f *Function) Inspect() string {
 		params = append(params, p.String())
 	}
 	out.WriteString("fn")
+	if f.Name != "" {
+		out.WriteString(" " + f.Name)
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") {\n")
@@ -150,12 +205,21 @@ func (f *Function) Inspect() string {
 	return out.String()
 }
 
-// BuiltinFunction for Built-In function definition
-type BuiltinFunction func(args ...Object) Object
+// Caller calls functions on behalf of built-in functions taking functions
+// as arguments
+type Caller interface {
+	Call(fn Object, args ...Object) Object
+}
+
+// BuiltinFunction for Built-In function definition, the functions passed
+// as arguments are called through caller
+type BuiltinFunction func(caller Caller, args ...Object) Object
 
 // Builtin for Built-In function object
 type Builtin struct {
-	Fn BuiltinFunction
+	Name string
+	Fn   BuiltinFunction
+	Doc  string // usage of the function, shown by tools
 }
 
 // Type returns BUILTIN
@@ -164,9 +228,38 @@ func (b *Builtin) Type() Type { return BUILTIN }
 // Inspect returns built in message
 func (b *Builtin) Inspect() string { return "Built-In function" }
 
-// Array object
+// Array object, a persistent vector: the functions updating an array return
+// a new one sharing most of its elements with the array updated. The zero
+// value is an empty array.
 type Array struct {
-	Elements []Object
+	elements vector
+}
+
+// NewArray returns an array of the elements
+func NewArray(elements []Object) *Array {
+	return &Array{elements: newVector(elements)}
+}
+
+// Len returns the number of elements of the array
+func (ao *Array) Len() int { return ao.elements.length }
+
+// At returns the element i of the array, which must be in range
+func (ao *Array) At(i int) Object { return ao.elements.at(i) }
+
+// Elements returns the elements of the array in a new slice
+func (ao *Array) Elements() []Object {
+	return ao.elements.appendTo(make([]Object, 0, ao.Len()))
+}
+
+// Push returns a new array with x appended
+func (ao *Array) Push(x Object) *Array {
+	return &Array{elements: ao.elements.push(x)}
+}
+
+// Slice returns a new array of the elements from lo to hi - 1, with
+// 0 <= lo <= hi <= Len()
+func (ao *Array) Slice(lo, hi int) *Array {
+	return &Array{elements: ao.elements.slice(lo, hi)}
 }
 
 // Type returns ARRAY
@@ -176,7 +269,7 @@ func (ao *Array) Type() Type { return ARRAY }
 func (ao *Array) Inspect() string {
 	var out bytes.Buffer
 	elements := []string{}
-	for _, e := range ao.Elements {
+	for _, e := range ao.Elements() {
 		elements = append(elements, e.Inspect())
 	}
 	out.WriteString("[")
@@ -197,19 +290,107 @@ type HashPair struct {
 	Val Object
 }
 
-// Hash for hash type
+// Hash for hash type, a persistent map: the functions updating a hash
+// return a new one sharing most of its pairs with the hash updated. The zero
+// value is an empty hash.
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	root *hamtNode
+	size int
+}
+
+// Len returns the number of pairs of the hash
+func (h *Hash) Len() int { return h.size }
+
+// Get returns the pair of key
+func (h *Hash) Get(key HashKey) (HashPair, bool) {
+	return h.root.get(key, hashOf(key), 0)
+}
+
+// Set returns a new hash with the pair of key set
+func (h *Hash) Set(key HashKey, pair HashPair) *Hash {
+	root, added := h.root.set(key, hashOf(key), 0, pair)
+	if added {
+		return &Hash{root: root, size: h.size + 1}
+	}
+	return &Hash{root: root, size: h.size}
+}
+
+// Delete returns a new hash without the pair of key, or h when key has no
+// pair
+func (h *Hash) Delete(key HashKey) *Hash {
+	root, deleted := h.root.delete(key, hashOf(key), 0)
+	if !deleted {
+		return h
+	}
+	return &Hash{root: root, size: h.size - 1}
+}
+
+// Range calls fn for each pair of the hash, in no particular order, until
+// fn returns false
+func (h *Hash) Range(fn func(key HashKey, pair HashPair) bool) {
+	h.root.each(fn)
+}
+
+// SortedPairs returns the pairs of the hash ordered by key: booleans, then
+// integers, then strings, each in ascending order
+func (h *Hash) SortedPairs() []HashPair {
+	pairs := make([]HashPair, 0, h.size)
+	h.Range(func(_ HashKey, pair HashPair) bool {
+		pairs = append(pairs, pair)
+		return true
+	})
+	sort.Slice(pairs, func(i, j int) bool {
+		return lessKey(pairs[i].Key, pairs[j].Key)
+	})
+	return pairs
+}
+
+// lessKey orders hash keys by kind, booleans, then integers, then strings,
+// and then by value, integers of any size are compared together
+func lessKey(a, b Object) bool {
+	if keyRank(a) != keyRank(b) {
+		return keyRank(a) < keyRank(b)
+	}
+	switch a := a.(type) {
+	case *Boolean:
+		return !a.Value && b.(*Boolean).Value
+	case *Integer:
+		if b, ok := b.(*Integer); ok {
+			return a.Value < b.Value
+		}
+		return big.NewInt(a.Value).Cmp(b.(*BigInteger).Value) < 0
+	case *BigInteger:
+		if b, ok := b.(*Integer); ok {
+			return a.Value.Cmp(big.NewInt(b.Value)) < 0
+		}
+		return a.Value.Cmp(b.(*BigInteger).Value) < 0
+	case *String:
+		return a.Value < b.(*String).Value
+	}
+	return false
+}
+
+// keyRank returns the rank of the kind of a hash key in the order of keys
+func keyRank(key Object) int {
+	switch key.(type) {
+	case *Boolean:
+		return 0
+	case *Integer, *BigInteger:
+		return 1
+	case *String:
+		return 2
+	}
+	return 3
 }
 
 // Type returns ARRAY
 func (h *Hash) Type() Type { return HASH }
 
-// Inspect returns hash type literal value
+// Inspect returns hash type literal value, the pairs ordered by key
 func (h *Hash) Inspect() string {
 	var out bytes.Buffer
 	pairs := []string{}
-	for _, pair := range h.Pairs {
+	for _, pair := range h.SortedPairs() {
 		pairs = append(pairs, fmt.Sprintf("%s: %s",
 			pair.Key.Inspect(), pair.Val.Inspect()))
 	}
@@ -218,3 +399,36 @@ func (h *Hash) Inspect() string {
 	out.WriteString("}")
 	return out.String()
 }
+
+// Regex for compiled regular expressions, in the RE2 syntax
+type Regex struct {
+	Regexp *regexp.Regexp
+}
+
+// Type returns REGEX
+func (r *Regex) Type() Type { return REGEX }
+
+// Inspect returns the pattern between slashes
+func (r *Regex) Inspect() string { return "/" + r.Regexp.String() + "/" }
+
+// Time for instants, with a location
+type Time struct {
+	Value time.Time
+}
+
+// Type returns TIME
+func (t *Time) Type() Type { return TIME }
+
+// Inspect returns the time in the RFC 3339 format
+func (t *Time) Inspect() string { return t.Value.Format(time.RFC3339Nano) }
+
+// Duration for elapsed times between instants
+type Duration struct {
+	Value time.Duration
+}
+
+// Type returns DURATION
+func (d *Duration) Type() Type { return DURATION }
+
+// Inspect returns the duration as "1h2m3.5s"
+func (d *Duration) Inspect() string { return d.Value.String() }
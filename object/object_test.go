@@ -1,6 +1,10 @@
 package object
 
-import "testing"
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -17,3 +21,202 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with different content have same hash keys")
 	}
 }
+
+func TestEnvironmentNames(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("b", &Integer{Value: 1})
+	outer.Set("a", &Integer{Value: 2})
+	inner := NewEnclosedEnvironment(outer)
+	inner.Set("c", &Integer{Value: 3})
+
+	if names := outer.Names(); len(names) != 2 || names[0] != "a" || names[1] != "b" {
+		t.Errorf("wrong names of outer: %v", names)
+	}
+	if names := inner.Names(); len(names) != 1 || names[0] != "c" {
+		t.Errorf("wrong names of inner: %v", names)
+	}
+	if inner.Outer() != outer || outer.Outer() != nil {
+		t.Errorf("wrong outer environments")
+	}
+}
+
+func TestHashInspect(t *testing.T) {
+	h := &Hash{}
+	for _, key := range []Object{
+		&String{Value: "b"}, &Integer{Value: 10}, &Boolean{Value: true},
+		&String{Value: "a"}, &Integer{Value: -1}, &Boolean{Value: false},
+	} {
+		h = h.Set(key.(Hashable).HashKey(), HashPair{Key: key, Val: &Null{}})
+	}
+	expected := "{false: null, true: null, -1: null, 10: null, a: null, b: null}"
+	for i := 0; i < 10; i++ {
+		if got := h.Inspect(); got != expected {
+			t.Fatalf("wrong inspect. expected=%q, got=%q", expected, got)
+		}
+	}
+}
+
+func TestArray(t *testing.T) {
+	// every version of an array keeps its elements while newer ones are made
+	var versions []*Array
+	a := &Array{}
+	for i := 0; i < 2000; i++ {
+		versions = append(versions, a)
+		a = a.Push(&Integer{Value: int64(i)})
+	}
+	for n, v := range versions {
+		testElements(t, v, 0, n)
+	}
+	testElements(t, a, 0, 2000)
+	testElements(t, NewArray(a.Elements()), 0, 2000)
+
+	// pushing to a slice replaces the elements after it in a copy
+	s := a.Slice(10, 1100)
+	testElements(t, s, 10, 1100)
+	p := s.Push(&String{Value: "x"})
+	if p.Len() != 1091 || p.At(1090).Inspect() != "x" {
+		t.Errorf("wrong push to a slice: %d elements, last %s", p.Len(), p.At(p.Len()-1).Inspect())
+	}
+	testElements(t, s, 10, 1100)
+	testElements(t, a, 0, 2000)
+	if e := a.Slice(5, 5); e.Len() != 0 || e.Push(&Integer{Value: 7}).Inspect() != "[7]" {
+		t.Errorf("wrong empty slice: %s", e.Inspect())
+	}
+
+	// a queue reuses the room before its elements instead of growing
+	q := NewArray(nil)
+	for i := 0; i < 10000; i++ {
+		q = q.Push(&Integer{Value: int64(i)})
+		if q.Len() > 100 {
+			q = q.Slice(1, q.Len())
+		}
+	}
+	testElements(t, q, 9900, 10000)
+	if q.elements.size > 2*vectorWidth*vectorWidth {
+		t.Errorf("queue of 100 elements has a trie of %d elements", q.elements.size)
+	}
+}
+
+// testElements checks that the array holds the integers from lo to hi - 1
+func testElements(t *testing.T, a *Array, lo, hi int) {
+	t.Helper()
+	if a.Len() != hi-lo {
+		t.Fatalf("wrong length. expected=%d, got=%d", hi-lo, a.Len())
+	}
+	elements := a.Elements()
+	for i := lo; i < hi; i++ {
+		if got := a.At(i - lo).(*Integer).Value; got != int64(i) {
+			t.Fatalf("wrong element %d. expected=%d, got=%d", i-lo, i, got)
+		}
+		if got := elements[i-lo].(*Integer).Value; got != int64(i) {
+			t.Fatalf("wrong element %d of Elements. expected=%d, got=%d", i-lo, i, got)
+		}
+	}
+}
+
+func TestHash(t *testing.T) {
+	// the hash matches a map through random updates, and old versions keep
+	// their pairs
+	h := &Hash{}
+	m := map[HashKey]HashPair{}
+	old, oldLen := h, 0
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000; i++ {
+		key := &Integer{Value: int64(r.Intn(3000))}
+		pair := HashPair{Key: key, Val: &Integer{Value: int64(i)}}
+		if r.Intn(3) == 0 {
+			h = h.Delete(key.HashKey())
+			delete(m, key.HashKey())
+		} else {
+			h = h.Set(key.HashKey(), pair)
+			m[key.HashKey()] = pair
+		}
+		if i == 10000 {
+			old, oldLen = h, h.Len()
+		}
+	}
+	if h.Len() != len(m) {
+		t.Fatalf("wrong length. expected=%d, got=%d", len(m), h.Len())
+	}
+	for key, pair := range m {
+		if got, ok := h.Get(key); !ok || got != pair {
+			t.Fatalf("wrong pair of %d. expected=%v, got=%v", key.Value, pair, got)
+		}
+	}
+	n := 0
+	h.Range(func(key HashKey, pair HashPair) bool {
+		if m[key] != pair {
+			t.Fatalf("wrong pair ranged over for %d", key.Value)
+		}
+		n++
+		return true
+	})
+	if n != len(m) {
+		t.Errorf("wrong number of pairs ranged over. expected=%d, got=%d", len(m), n)
+	}
+	n = 0
+	old.Range(func(HashKey, HashPair) bool { n++; return true })
+	if n != oldLen {
+		t.Errorf("old version changed. expected %d pairs, got=%d", oldLen, n)
+	}
+	if h.Delete(HashKey{Type: STRING, Value: 1}) != h {
+		t.Errorf("deleting a missing key made a new hash")
+	}
+
+	// keys whose hashes collide are kept apart
+	var root *hamtNode
+	keys := []HashKey{{Type: INTEGER, Value: 1}, {Type: STRING, Value: 1}, {Type: INTEGER, Value: 2}}
+	for i, key := range keys {
+		root, _ = root.set(key, 42, 0, HashPair{Val: &Integer{Value: int64(i)}})
+	}
+	root, _ = root.delete(keys[1], 42, 0)
+	for i, key := range keys {
+		pair, ok := root.get(key, 42, 0)
+		if ok != (i != 1) || (ok && pair.Val.(*Integer).Value != int64(i)) {
+			t.Errorf("wrong pair of colliding key %d: %v, %t", i, pair, ok)
+		}
+	}
+}
+
+func BenchmarkArrayPush(b *testing.B) {
+	for _, n := range []int{100, 1000, 10000} {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				a := &Array{}
+				for j := 0; j < n; j++ {
+					a = a.Push(&Integer{Value: int64(j)})
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkArrayRest(b *testing.B) {
+	for _, n := range []int{100, 1000, 10000} {
+		elements := make([]Object, n)
+		for i := range elements {
+			elements[i] = &Integer{Value: int64(i)}
+		}
+		a := NewArray(elements)
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for rest := a; rest.Len() > 0; rest = rest.Slice(1, rest.Len()) {
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkHashSet(b *testing.B) {
+	for _, n := range []int{100, 1000, 10000} {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				h := &Hash{}
+				for j := 0; j < n; j++ {
+					key := &Integer{Value: int64(j)}
+					h = h.Set(key.HashKey(), HashPair{Key: key, Val: key})
+				}
+			}
+		})
+	}
+}
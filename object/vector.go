@@ -0,0 +1,164 @@
+package object
+
+// vector is a persistent sequence of objects: a trie of nodes of up to 32
+// children or elements, where an update copies the nodes on the path to the
+// element changed and shares the others with the previous version. A vector
+// is a window on its trie, as a Go slice is on its array, so slicing only
+// moves the bounds of the window and pushing to a vector whose window
+// doesn't end at the end of the trie replaces the element after the window.
+// Getting, pushing and slicing take O(log n) time. The zero value is an
+// empty vector.
+type vector struct {
+	root   *vectorNode
+	shift  uint // of the index of a child of the root
+	size   int  // number of elements in the trie
+	offset int  // index in the trie of the first element of the window
+	length int
+}
+
+const (
+	vectorBits  = 5
+	vectorWidth = 1 << vectorBits
+	vectorMask  = vectorWidth - 1
+)
+
+// vectorNode is an inner node with children or a leaf with elements
+type vectorNode struct {
+	children []*vectorNode
+	elements []Object
+}
+
+// newVector returns a vector of the elements, its leaves are filled in turn
+func newVector(elements []Object) vector {
+	if len(elements) == 0 {
+		return vector{}
+	}
+	nodes := make([]*vectorNode, 0, (len(elements)+vectorMask)/vectorWidth)
+	for i := 0; i < len(elements); i += vectorWidth {
+		end := i + vectorWidth
+		if end > len(elements) {
+			end = len(elements)
+		}
+		leaf := make([]Object, end-i)
+		copy(leaf, elements[i:end])
+		nodes = append(nodes, &vectorNode{elements: leaf})
+	}
+	shift := uint(0)
+	for len(nodes) > 1 {
+		parents := make([]*vectorNode, 0, (len(nodes)+vectorMask)/vectorWidth)
+		for i := 0; i < len(nodes); i += vectorWidth {
+			end := i + vectorWidth
+			if end > len(nodes) {
+				end = len(nodes)
+			}
+			parents = append(parents, &vectorNode{children: nodes[i:end:end]})
+		}
+		nodes = parents
+		shift += vectorBits
+	}
+	return vector{root: nodes[0], shift: shift, size: len(elements), length: len(elements)}
+}
+
+// at returns the element i of the window
+func (v vector) at(i int) Object {
+	return v.leaf(v.offset + i)[(v.offset+i)&vectorMask]
+}
+
+// leaf returns the elements of the leaf holding the element i of the trie
+func (v vector) leaf(i int) []Object {
+	node := v.root
+	for shift := v.shift; shift > 0; shift -= vectorBits {
+		node = node.children[(i>>shift)&vectorMask]
+	}
+	return node.elements
+}
+
+// appendTo appends the elements of the window to dst, a leaf at a time
+func (v vector) appendTo(dst []Object) []Object {
+	end := v.offset + v.length
+	for i := v.offset; i < end; {
+		leaf := v.leaf(i)
+		start := i & vectorMask
+		n := len(leaf) - start
+		if n > end-i {
+			n = end - i
+		}
+		dst = append(dst, leaf[start:start+n]...)
+		i += n
+	}
+	return dst
+}
+
+// push returns the vector with x appended to the window
+func (v vector) push(x Object) vector {
+	i := v.offset + v.length
+	switch {
+	case i < v.size:
+		v.root = v.root.set(v.shift, i, x)
+	case v.root == nil:
+		v.root = &vectorNode{elements: []Object{x}}
+		v.size = 1
+	case v.size == 1<<(v.shift+vectorBits):
+		if v.offset >= v.length {
+			// most of the trie is before the window, drop it instead of
+			// growing the trie
+			return newVector(v.appendTo(make([]Object, 0, v.length+1))).push(x)
+		}
+		v.root = &vectorNode{children: []*vectorNode{v.root, (*vectorNode)(nil).append(v.shift, i, x)}}
+		v.shift += vectorBits
+		v.size++
+	default:
+		v.root = v.root.append(v.shift, i, x)
+		v.size++
+	}
+	v.length++
+	return v
+}
+
+// slice returns the window of the elements from lo to hi - 1
+func (v vector) slice(lo, hi int) vector {
+	if lo == hi {
+		return vector{}
+	}
+	v.offset += lo
+	v.length = hi - lo
+	return v
+}
+
+// set returns a copy of the node with the element i of the trie replaced
+// by x
+func (n *vectorNode) set(shift uint, i int, x Object) *vectorNode {
+	if shift == 0 {
+		elements := make([]Object, len(n.elements))
+		copy(elements, n.elements)
+		elements[i&vectorMask] = x
+		return &vectorNode{elements: elements}
+	}
+	children := make([]*vectorNode, len(n.children))
+	copy(children, n.children)
+	j := (i >> shift) & vectorMask
+	children[j] = children[j].set(shift-vectorBits, i, x)
+	return &vectorNode{children: children}
+}
+
+// append returns a copy of the node with x added as the element i of the
+// trie, the one after its last element, a nil node is a new one
+func (n *vectorNode) append(shift uint, i int, x Object) *vectorNode {
+	if n == nil {
+		n = &vectorNode{}
+	}
+	if shift == 0 {
+		elements := make([]Object, len(n.elements), len(n.elements)+1)
+		copy(elements, n.elements)
+		return &vectorNode{elements: append(elements, x)}
+	}
+	children := make([]*vectorNode, len(n.children), len(n.children)+1)
+	copy(children, n.children)
+	j := (i >> shift) & vectorMask
+	if j < len(children) {
+		children[j] = children[j].append(shift-vectorBits, i, x)
+	} else {
+		children = append(children, (*vectorNode)(nil).append(shift-vectorBits, i, x))
+	}
+	return &vectorNode{children: children}
+}
@@ -0,0 +1,88 @@
+package optimize
+
+import (
+	"math"
+
+	"github.com/lycheng/monkey-go/ast"
+)
+
+// The folding follows the evaluator: the expressions it evaluates to errors,
+// like a division by zero or the comparison of strings, and the integer
+// arithmetic overflowing into big integers are left to it.
+
+// foldPrefix returns the literal of a prefix expression of a literal, or nil
+func foldPrefix(exp *ast.PrefixExpression) ast.Expression {
+	pos := exp.Pos()
+	switch exp.Operator {
+	case "!":
+		if b, ok := exp.Right.(*ast.Boolean); ok {
+			return boolean(!b.Value, pos)
+		}
+		if isLiteral(exp.Right) {
+			return boolean(false, pos)
+		}
+	case "-":
+		if i, ok := exp.Right.(*ast.IntegerLiteral); ok && i.Value != math.MinInt64 {
+			return integer(-i.Value, pos)
+		}
+	}
+	return nil
+}
+
+// foldInfix returns the literal of an infix expression of literals, or nil
+func foldInfix(exp *ast.InfixExpression) ast.Expression {
+	pos := exp.Pos()
+	switch left := exp.Left.(type) {
+	case *ast.IntegerLiteral:
+		right, ok := exp.Right.(*ast.IntegerLiteral)
+		if !ok {
+			break
+		}
+		a, b := left.Value, right.Value
+		switch exp.Operator {
+		case "+":
+			if sum := a + b; (sum > a) == (b > 0) {
+				return integer(sum, pos)
+			}
+		case "-":
+			if diff := a - b; (diff < a) == (b > 0) {
+				return integer(diff, pos)
+			}
+		case "*":
+			if a == 0 || b == 0 {
+				return integer(0, pos)
+			}
+			if product := a * b; product/b == a && !(a == -1 && b == math.MinInt64) && !(b == -1 && a == math.MinInt64) {
+				return integer(product, pos)
+			}
+		case "/":
+			if b != 0 && !(a == math.MinInt64 && b == -1) {
+				return integer(a/b, pos)
+			}
+		case "<":
+			return boolean(a < b, pos)
+		case ">":
+			return boolean(a > b, pos)
+		case "==":
+			return boolean(a == b, pos)
+		case "!=":
+			return boolean(a != b, pos)
+		}
+	case *ast.StringLiteral:
+		if right, ok := exp.Right.(*ast.StringLiteral); ok && exp.Operator == "+" {
+			return str(left.Value+right.Value, pos)
+		}
+	case *ast.Boolean:
+		right, ok := exp.Right.(*ast.Boolean)
+		if !ok {
+			break
+		}
+		switch exp.Operator {
+		case "==":
+			return boolean(left.Value == right.Value, pos)
+		case "!=":
+			return boolean(left.Value != right.Value, pos)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+// Package optimize rewrites the AST of a Monkey program so it evaluates
+// faster to the same results.
+//
+// Three rewrites are done in a single pass:
+//
+//   - the prefix and infix expressions of integer, string and boolean
+//     literals are folded into literals, unless their evaluation fails or
+//     overflows an int64;
+//   - the branch not taken by the if expressions whose condition is a
+//     literal is dropped, the if expression is replaced by the branch taken
+//     when it is a statement of its own, unless the branch declares
+//     functions, or when the branch is a literal;
+//   - the uses of a name bound once to a literal in a function, or in the
+//     program, are replaced by the literal after the let statement binding
+//     it, when the let statement isn't in the block of an if expression,
+//     except in the bodies of the declared functions, which may be called
+//     before it.
+//
+// Errors keep their messages and positions, but hooks and coverage observe
+// the statements and branches left, so programs being debugged or covered
+// must not be optimized. Function values print their optimized bodies, so
+// they are left out of the same results: `let f = fn() { 60 * 60 }; puts(f)`
+// prints `fn() {\n3600\n}` once optimized.
+package optimize
+
+import (
+	"strconv"
+
+	"github.com/lycheng/monkey-go/ast"
+	"github.com/lycheng/monkey-go/token"
+)
+
+// Program optimizes program in place, before it is resolved and evaluated
+func Program(program *ast.Program) {
+	consts := make(map[string]ast.Expression)
+	program.Statements = statements(program.Statements, consts, declarations(program.Statements, nil))
+}
+
+// statements optimizes the statements of a function body or of the
+// program, consts are the literals of the names which can be inlined and
+// declared the number of bindings of the names of the function
+func statements(stmts []ast.Statement, consts map[string]ast.Expression, declared map[string]int) []ast.Statement {
+	optimized := make([]ast.Statement, 0, len(stmts))
+	for i, stmt := range stmts {
+		stmt = statement(stmt, consts)
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			if isLiteral(stmt.Value) && declared[stmt.Name.Value] == 1 {
+				consts[stmt.Name.Value] = stmt.Value
+			}
+		case *ast.ExpressionStatement:
+			branch, ok := decidedBranch(stmt.Expression)
+			if !ok || branch != nil && declaresFunctions(branch) {
+				break
+			}
+			// the statements of the branch taken replace the if
+			// expression, which is dropped when its value isn't used
+			if branch != nil && len(branch.Statements) != 0 {
+				optimized = append(optimized, branch.Statements...)
+				continue
+			}
+			if i != len(stmts)-1 {
+				continue
+			}
+		}
+		optimized = append(optimized, stmt)
+	}
+	return optimized
+}
+
+// block optimizes the statements of a block of an if expression
+func block(b *ast.BlockStatement, consts map[string]ast.Expression) {
+	for i, stmt := range b.Statements {
+		b.Statements[i] = statement(stmt, consts)
+	}
+}
+
+func statement(stmt ast.Statement, consts map[string]ast.Expression) ast.Statement {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		stmt.Value = expression(stmt.Value, consts)
+	case *ast.ReturnStatement:
+		stmt.ReturnValue = expression(stmt.ReturnValue, consts)
+	case *ast.ExpressionStatement:
+		stmt.Expression = expression(stmt.Expression, consts)
+	case *ast.FunctionStatement:
+		// a declared function may be called before the let statements
+		// preceding it, the names they bind aren't inlined in its body
+		expression(stmt.Function, map[string]ast.Expression{})
+	case *ast.BlockStatement:
+		block(stmt, consts)
+	}
+	return stmt
+}
+
+func expression(exp ast.Expression, consts map[string]ast.Expression) ast.Expression {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		if literal, ok := consts[exp.Value]; ok {
+			return withPos(literal, exp.Token.Pos)
+		}
+	case *ast.PrefixExpression:
+		exp.Right = expression(exp.Right, consts)
+		if folded := foldPrefix(exp); folded != nil {
+			return folded
+		}
+	case *ast.InfixExpression:
+		exp.Left = expression(exp.Left, consts)
+		exp.Right = expression(exp.Right, consts)
+		if folded := foldInfix(exp); folded != nil {
+			return folded
+		}
+	case *ast.IfExpression:
+		exp.Condition = expression(exp.Condition, consts)
+		block(exp.Consequence, consts)
+		if exp.Alternative != nil {
+			block(exp.Alternative, consts)
+		}
+		branch, ok := decidedBranch(exp)
+		if !ok || branch == nil {
+			break
+		}
+		if len(branch.Statements) == 1 {
+			if stmt, ok := branch.Statements[0].(*ast.ExpressionStatement); ok && isLiteral(stmt.Expression) {
+				return withPos(stmt.Expression, exp.Pos())
+			}
+		}
+		// the expressions keep their positions, which locate the errors
+		// of the expressions they are the left operand of
+		exp.Condition = boolean(true, exp.Condition.Pos())
+		exp.Consequence, exp.Alternative = branch, nil
+	case *ast.FunctionLiteral:
+		declared := declarations(exp.Body.Statements, exp.Parameters)
+		inner := make(map[string]ast.Expression, len(consts))
+		for name, literal := range consts {
+			if declared[name] == 0 {
+				inner[name] = literal
+			}
+		}
+		exp.Body.Statements = statements(exp.Body.Statements, inner, declared)
+	case *ast.CallExpression:
+		exp.Function = expression(exp.Function, consts)
+		for i, arg := range exp.Arguments {
+			exp.Arguments[i] = expression(arg, consts)
+		}
+	case *ast.ArrayLiteral:
+		for i, el := range exp.Elements {
+			exp.Elements[i] = expression(el, consts)
+		}
+	case *ast.IndexExpression:
+		exp.Left = expression(exp.Left, consts)
+		exp.Index = expression(exp.Index, consts)
+	case *ast.HashLiteral:
+		pairs := make(map[ast.Expression]ast.Expression, len(exp.Pairs))
+		for key, value := range exp.Pairs {
+			pairs[expression(key, consts)] = expression(value, consts)
+		}
+		exp.Pairs = pairs
+	}
+	return exp
+}
+
+// declarations counts the bindings of the names of a function by its
+// parameters and by the let statements and function declarations of its
+// body, nested functions left out
+func declarations(stmts []ast.Statement, params []*ast.Identifier) map[string]int {
+	declared := make(map[string]int)
+	for _, param := range params {
+		declared[param.Value]++
+	}
+	for _, stmt := range stmts {
+		ast.Inspect(stmt, func(node ast.Node) bool {
+			switch node := node.(type) {
+			case *ast.LetStatement:
+				declared[node.Name.Value]++
+			case *ast.FunctionStatement:
+				declared[node.Function.Name.Value]++
+			case *ast.FunctionLiteral:
+				return false
+			}
+			return true
+		})
+	}
+	return declared
+}
+
+// declaresFunctions reports whether a block declares functions, which are
+// bound when the block is entered and so can't be moved to another block
+func declaresFunctions(b *ast.BlockStatement) bool {
+	for _, stmt := range b.Statements {
+		if _, ok := stmt.(*ast.FunctionStatement); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// decidedBranch returns the branch taken by an if expression whose
+// condition is a literal, nil when it is false and there is no else branch
+func decidedBranch(exp ast.Expression) (*ast.BlockStatement, bool) {
+	ie, ok := exp.(*ast.IfExpression)
+	if !ok || !isLiteral(ie.Condition) {
+		return nil, false
+	}
+	if b, ok := ie.Condition.(*ast.Boolean); ok && !b.Value {
+		return ie.Alternative, true
+	}
+	return ie.Consequence, true
+}
+
+// isLiteral reports whether exp is an integer, string or boolean literal
+func isLiteral(exp ast.Expression) bool {
+	switch exp.(type) {
+	case *ast.IntegerLiteral, *ast.StringLiteral, *ast.Boolean:
+		return true
+	}
+	return false
+}
+
+// withPos returns a copy of a literal at pos
+func withPos(literal ast.Expression, pos token.Position) ast.Expression {
+	switch literal := literal.(type) {
+	case *ast.IntegerLiteral:
+		return integer(literal.Value, pos)
+	case *ast.StringLiteral:
+		return str(literal.Value, pos)
+	case *ast.Boolean:
+		return boolean(literal.Value, pos)
+	}
+	return literal
+}
+
+func integer(value int64, pos token.Position) *ast.IntegerLiteral {
+	return &ast.IntegerLiteral{
+		Token: token.Token{Type: token.INT, Literal: strconv.FormatInt(value, 10), Pos: pos},
+		Value: value,
+	}
+}
+
+func str(value string, pos token.Position) *ast.StringLiteral {
+	return &ast.StringLiteral{Token: token.Token{Type: token.STRING, Literal: value, Pos: pos}, Value: value}
+}
+
+func boolean(value bool, pos token.Position) *ast.Boolean {
+	if value {
+		return &ast.Boolean{Token: token.Token{Type: token.TRUE, Literal: "true", Pos: pos}, Value: true}
+	}
+	return &ast.Boolean{Token: token.Token{Type: token.FALSE, Literal: "false", Pos: pos}, Value: false}
+}
@@ -0,0 +1,168 @@
+package optimize
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/lycheng/monkey-go/ast"
+	"github.com/lycheng/monkey-go/evaluator"
+	"github.com/lycheng/monkey-go/lexer"
+	"github.com/lycheng/monkey-go/object"
+	"github.com/lycheng/monkey-go/parser"
+)
+
+func TestProgram(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // optimized program
+	}{
+		{"60 * 60 * 24", "86400"},
+		{`"a" + "b" + "c"`, "abc"},
+		{"-5; !5; !true; true == false; 1 < 2; 2 != 2", "-5falsefalsefalsetruefalse"},
+		{`1 / 0; 9223372036854775807 + 1; "a" == "a"; 1 + true; -true`,
+			"(1 / 0)(9223372036854775807 + 1)(a == a)(1 + true)(-true)"},
+		{"if (true) { a } else { b }", "a"},
+		{"if (1 > 2) { a } else { b }", "b"},
+		{"if (0) { a }", "a"},
+		{"if (false) { a }; b", "b"},
+		{"if (false) { a }", "iffalse a"},
+		{"let x = if (true) { let y = 1; y }", "let x = iftrue let y = 1;y;"},
+		{`let x = if (false) { y } else { a + b }; x`, "let x = iftrue (a + b);x"},
+		{`let x = if (false) { y } else { "two" }; x`, "let x = two;two"},
+		{"let f = fn() { if (false) { 1 }; let x = 2; if (true) { let y = x; y } }",
+			"let f = fn() let x = 2;let y = 2;y;"},
+		{"let day = 60 * 60 * 24; let week = day * 7; fn() { week }",
+			"let day = 86400;let week = 604800;fn() 604800"},
+		// names bound more than once, or conditionally, are left alone
+		{"let x = 1; let f = fn() { x }; let x = 2; f()", "let x = 1;let f = fn() x;let x = 2;f()"},
+		{"let x = 1; if (c) { let x = 2 }; x", "let x = 1;ifc let x = 2;x"},
+		{"if (c) { let x = 2 }; x", "ifc let x = 2;x"},
+		// nested functions binding a name again inline theirs
+		{"let x = 1; fn(x) { x }; fn() { let x = 2; x }; fn() { x }",
+			"let x = 1;fn(x) xfn() let x = 2;2fn() 1"},
+		{"let f = fn() { let g = fn() { v }; let v = 5; g() + v }",
+			"let f = fn() let g = fn() v;let v = 5;(g() + 5);"},
+		{`let s = "a"; {s: [s, s + "b"]}[s]`, "let s = a;({a:[a, ab]}[a])"},
+		// declared functions may be called before the let statements
+		// preceding them, and are bound when their block is entered
+		{"let k = 5; fn f() { k * 2 }; k", "let k = 5;fn f() (k * 2)5"},
+		{"fn f() { let k = 5; k * 2 }", "fn f() let k = 5;10"},
+		{"let k = 5; fn k() { 1 }; k", "let k = 5;fn k() 1k"},
+		{"if (true) { fn f() { 1 } }; f()", "iftrue fn f() 1f()"},
+	}
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		Program(program)
+		if got := program.String(); got != tt.expected {
+			t.Errorf("wrong program for %s.\nexpected=%q\ngot=     %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestDifferential(t *testing.T) {
+	inputs := []string{
+		"let day = 60 * 60 * 24; let f = fn(n) { n * day }; f(2)",
+		"let x = 1; let f = fn() { x }; let x = 2; f()",
+		"let f = fn() { let g = fn() { v }; let v = 5; g() + v }; f()",
+		"let f = fn(c) { if (c) { let x = 2 }; x }; let x = 1; [f(true), f(false)]",
+		"let f = fn() { if (true) { return 1; } 2 }; f()",
+		"let f = fn() { if (false) { return 1; } 2 }; f()",
+		"let f = fn(n) { let step = 1; if (n == 0) { 0 } else { f(n - step) } }; f(100)",
+		`let greeting = "hello" + " " + "world"; len(greeting)`,
+		`let s = "a"; s - 1`,
+		"let big = 9223372036854775807; big + 1",
+		"let zero = 0; 10 / zero",
+		"let t = true; if (t == !false) { 1 } else { 2 }",
+		"if (false) { 1 }",
+		"let n = 5; let n = n + 1; n",
+		"let f = fn(x) { let y = 3; fn(y) { x + y } }; f(1)(10)",
+		`let k = "key"; let h = {k: 1, "other": k}; [h[k], h["other"]]`,
+		"let x = 2; let f = fn() { x * x * x }; map([1, 2], fn(y) { y + f() })",
+		"f(); let k = 5; fn f() { k }",
+		"let k = 5; fn f() { k }; f()",
+		"let f = fn() { 1 }; let a = f(); if (true) { fn f() { 2 } }; [a, f()]",
+		"fn fib(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(15)",
+		"fn f(n) { let step = 2; g(n) + step; fn g(m) { m * step } }; [f(1), f(2, 3)]",
+	}
+	for _, input := range inputs {
+		testDifferential(t, input)
+	}
+}
+
+func TestRandomExpressions(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		var lets []string
+		names := []string{}
+		for j := 0; j < 3; j++ {
+			name := string(rune('a' + j))
+			lets = append(lets, fmt.Sprintf("let %s = %s;", name, randomExpression(r, names, 3)))
+			names = append(names, name)
+		}
+		input := strings.Join(lets, " ") +
+			fmt.Sprintf(" let f = fn(p) { [p, %s] }; f(%s)", randomExpression(r, names, 3), randomExpression(r, names, 2))
+		testDifferential(t, input)
+	}
+}
+
+// randomExpression returns a random expression of literals, the names and
+// the operators, some of them fail
+func randomExpression(r *rand.Rand, names []string, depth int) string {
+	if depth == 0 || r.Intn(4) == 0 {
+		switch n := r.Intn(6); {
+		case n < 3:
+			return fmt.Sprint(r.Intn(7) - 2)
+		case n == 3:
+			return []string{`"x"`, `"yz"`, `""`}[r.Intn(3)]
+		case n == 4:
+			return []string{"true", "false"}[r.Intn(2)]
+		case len(names) != 0:
+			return names[r.Intn(len(names))]
+		}
+		return "9223372036854775807"
+	}
+	switch r.Intn(6) {
+	case 0:
+		return fmt.Sprintf("(%s%s)", []string{"-", "!"}[r.Intn(2)], randomExpression(r, names, depth-1))
+	case 1:
+		return fmt.Sprintf("if (%s) { %s } else { %s }", randomExpression(r, names, depth-1),
+			randomExpression(r, names, depth-1), randomExpression(r, names, depth-1))
+	}
+	operators := []string{"+", "-", "*", "/", "<", ">", "==", "!="}
+	return fmt.Sprintf("(%s %s %s)", randomExpression(r, names, depth-1),
+		operators[r.Intn(len(operators))], randomExpression(r, names, depth-1))
+}
+
+// testDifferential checks that input evaluates to the same result, or fails
+// at the same position, once optimized
+func testDifferential(t *testing.T, input string) {
+	t.Helper()
+	expected := evaluate(parse(t, input))
+	program := parse(t, input)
+	Program(program)
+	got := evaluate(program)
+	if got.Inspect() != expected.Inspect() {
+		t.Errorf("wrong result for %s.\nexpected=%s\ngot=     %s\noptimized=%s",
+			input, expected.Inspect(), got.Inspect(), program.String())
+		return
+	}
+	if err, ok := expected.(*object.Error); ok && err.Pos != got.(*object.Error).Pos {
+		t.Errorf("wrong error position for %s. expected=%s, got=%s", input, err.Pos, got.(*object.Error).Pos)
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.ErrorList()) != 0 {
+		t.Fatalf("parse errors for %s: %v", input, p.Errors())
+	}
+	return program
+}
+
+func evaluate(program *ast.Program) object.Object {
+	return evaluator.Prepare(program).Run(object.NewEnvironment())
+}
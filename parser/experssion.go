@@ -35,6 +35,15 @@ var precedences = map[token.Type]int{
 	token.LBRACKET: INDEX,
 }
 
+// Precedence returns the binding power of an infix operator token,
+// LOWEST for tokens which are not operators
+func Precedence(t token.Type) int {
+	if p, ok := precedences[t]; ok {
+		return p
+	}
+	return LOWEST
+}
+
 func (p *Parser) peekPrecedence() int {
 	if p, ok := precedences[p.peekToken.Type]; ok {
 		return p
@@ -102,6 +111,7 @@ func (p *Parser) parseExpression(precedence int) (ast.Expression, error) {
 	pfn, ok := p.prefixParseFns[p.currToken.Type]
 	if !ok {
 		msg := "parse func for " + string(p.currToken.Type) + " not found"
+		p.addError(p.currToken.Pos, msg)
 		return nil, errors.New(msg)
 	}
 	exp, err := pfn()
@@ -156,7 +166,7 @@ func (p *Parser) parseIntegerLiteral() (ast.Expression, error) {
 	val, err := strconv.ParseInt(p.currToken.Literal, 0, 64)
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as integer", p.currToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(p.currToken.Pos, msg)
 		return nil, errors.New(msg)
 	}
 
@@ -175,7 +185,7 @@ func (p *Parser) parseInfixExpression(left ast.Expression) (ast.Expression, erro
 	exp, err := p.parseExpression(precedence)
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as infix expression", p.currToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(p.currToken.Pos, msg)
 		return nil, errors.New(msg)
 	}
 	expression.Right = exp
@@ -197,13 +207,13 @@ func (p *Parser) parseGroupedExpression() (ast.Expression, error) {
 	exp, err := p.parseExpression(LOWEST)
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as expression", p.currToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(p.currToken.Pos, msg)
 		return nil, errors.New(msg)
 	}
 
 	if !p.expectPeek(token.RPAREN) {
 		msg := "could not match the right parenthesis"
-		p.errors = append(p.errors, msg)
+		p.addError(p.currToken.Pos, msg)
 		return nil, errors.New(msg)
 	}
 	return exp, nil
@@ -214,6 +224,10 @@ func (p *Parser) parseBlockStatement() (*ast.BlockStatement, error) {
 	block.Statements = []ast.Statement{}
 	p.nextToken()
 	for !p.currTokenIs(token.RBRACE) && !p.currTokenIs(token.EOF) {
+		if p.currTokenIs(token.SEMICOLON) {
+			p.nextToken()
+			continue
+		}
 		stmt, err := p.parseStatement()
 		if err != nil {
 			return nil, err
@@ -289,23 +303,32 @@ func (p *Parser) parseFunctionParameters() ([]*ast.Identifier, error) {
 
 func (p *Parser) parseFunctionLiteral() (ast.Expression, error) {
 	fn := &ast.FunctionLiteral{Token: p.currToken}
+	if err := p.parseFunction(fn); err != nil {
+		return nil, err
+	}
+	return fn, nil
+}
+
+// parseFunction parses the parameters and the body of fn, the current
+// token is the one before the (
+func (p *Parser) parseFunction(fn *ast.FunctionLiteral) error {
 	if !p.expectPeek(token.LPAREN) {
-		return nil, errors.New("no ( token for function definition")
+		return errors.New("no ( token for function definition")
 	}
 	params, err := p.parseFunctionParameters()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	fn.Parameters = params
 	if !p.expectPeek(token.LBRACE) {
-		return nil, errors.New("no ) token for function definition")
+		return errors.New("no ) token for function definition")
 	}
 	body, err := p.parseBlockStatement()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	fn.Body = body
-	return fn, nil
+	return nil
 }
 
 func (p *Parser) parseArrayLiteral() (ast.Expression, error) {
@@ -9,6 +9,17 @@ import (
 	"github.com/lycheng/monkey-go/token"
 )
 
+// Error for a parse error in the source
+type Error struct {
+	Pos token.Position
+	Msg string
+}
+
+// Error returns the message prefixed by the position
+func (e *Error) Error() string {
+	return e.Pos.String() + ": " + e.Msg
+}
+
 type (
 	prefixParseFn func() (ast.Expression, error)
 	// accept left side operator
@@ -19,7 +30,7 @@ type (
 type Parser struct {
 	l *lexer.Lexer
 
-	errors []string
+	errors []*Error
 
 	currToken token.Token
 	peekToken token.Token
@@ -30,7 +41,7 @@ type Parser struct {
 
 // New return new Parser with Lexer instance
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser{l: l, errors: make([]string, 0)}
+	p := &Parser{l: l, errors: make([]*Error, 0)}
 
 	p.registerParseFuncs()
 
@@ -51,6 +62,11 @@ func (p *Parser) parseStatement() (ast.Statement, error) {
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.FUNCTION:
+		if p.peekTokenIs(token.IDENT) {
+			return p.parseFunctionStatement()
+		}
+		return p.parseExpressionStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -95,11 +111,32 @@ func (p *Parser) parseReturnStatement() (*ast.ReturnStatement, error) {
 	return stmt, nil
 }
 
+// parseFunctionStatement parses the declaration fn name(params) { ... }
+func (p *Parser) parseFunctionStatement() (*ast.FunctionStatement, error) {
+	stmt := &ast.FunctionStatement{Token: p.currToken}
+	fn := &ast.FunctionLiteral{Token: p.currToken}
+	p.nextToken()
+	fn.Name = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+	if err := p.parseFunction(fn); err != nil {
+		return nil, err
+	}
+	stmt.Function = fn
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt, nil
+}
+
 func (p *Parser) peekError(t token.Type) {
 	msg := fmt.Sprintf(
 		"expect next token to be %s, but got %s",
 		t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.addError(p.peekToken.Pos, msg)
+}
+
+func (p *Parser) addError(pos token.Position, msg string) {
+	p.errors = append(p.errors, &Error{Pos: pos, Msg: msg})
 }
 
 func (p *Parser) currTokenIs(t token.Type) bool {
@@ -125,15 +162,37 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program.Statements = make([]ast.Statement, 0)
 
 	for p.currToken.Type != token.EOF {
-		if stmt, err := p.parseStatement(); err == nil {
+		if p.currTokenIs(token.SEMICOLON) {
+			// empty statement
+			p.nextToken()
+			continue
+		}
+		n := len(p.errors)
+		stmt, err := p.parseStatement()
+		if err == nil {
 			program.Statements = append(program.Statements, stmt)
+		} else if len(p.errors) == n {
+			// the statement failed without recording why
+			p.addError(p.currToken.Pos, err.Error())
 		}
 		p.nextToken()
 	}
+	for _, c := range p.l.Comments() {
+		program.Comments = append(program.Comments, &ast.Comment{Token: c})
+	}
 	return program
 }
 
 // Errors returns the errors durning parsing
 func (p *Parser) Errors() []string {
+	msgs := make([]string, 0, len(p.errors))
+	for _, err := range p.errors {
+		msgs = append(msgs, err.Msg)
+	}
+	return msgs
+}
+
+// ErrorList returns the errors durning parsing with their positions
+func (p *Parser) ErrorList() []*Error {
 	return p.errors
 }
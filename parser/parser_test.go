@@ -565,6 +565,53 @@ func TestFunctionLiteralParsing(t *testing.T) {
 	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
 }
 
+func TestFunctionStatementParsing(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedName   string
+		expectedParams []string
+		expectedString string
+	}{
+		{"fn add(x, y) { x + y; }", "add", []string{"x", "y"}, "fn add(x, y) (x + y)"},
+		{"fn zero() {};", "zero", []string{}, "fn zero() "},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d",
+				len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.FunctionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.FunctionStatement. got=%T",
+				program.Statements[0])
+		}
+		if !testIdentifier(t, stmt.Function.Name, tt.expectedName) {
+			return
+		}
+		if len(stmt.Function.Parameters) != len(tt.expectedParams) {
+			t.Fatalf("length parameters wrong. want %d, got=%d",
+				len(tt.expectedParams), len(stmt.Function.Parameters))
+		}
+		for i, ident := range tt.expectedParams {
+			testLiteralExpression(t, stmt.Function.Parameters[i], ident)
+		}
+		if stmt.String() != tt.expectedString {
+			t.Errorf("stmt.String() wrong. expected=%q, got=%q", tt.expectedString, stmt.String())
+		}
+	}
+
+	// without a name, fn starts a function literal
+	program := New(lexer.New("fn(x) { x }(1)")).ParseProgram()
+	if _, ok := program.Statements[0].(*ast.ExpressionStatement); !ok {
+		t.Errorf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+}
+
 func TestFunctionParameterParsing(t *testing.T) {
 	tests := []struct {
 		input          string
@@ -760,3 +807,108 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 		testFunc(value)
 	}
 }
+
+func TestParsingErrorsAreReported(t *testing.T) {
+	tests := []string{
+		"let x = ;",
+		"return ;",
+		"fn(x { x }",
+		"let x = 1;; x +",
+		"if (x) { let = 1 }",
+	}
+	for _, input := range tests {
+		l := lexer.New(input)
+		p := New(l)
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q, got none", input)
+		}
+	}
+}
+
+func TestParsingComments(t *testing.T) {
+	input := `// header
+let x = 1; // one
+x`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d",
+			len(program.Statements))
+	}
+	if len(program.Comments) != 2 {
+		t.Fatalf("program.Comments does not contain 2 comments. got=%d",
+			len(program.Comments))
+	}
+	if program.Comments[1].String() != "// one" {
+		t.Errorf("comment wrong. got=%q", program.Comments[1].String())
+	}
+	pos := program.Statements[1].Pos()
+	if pos.Line != 3 || pos.Column != 1 {
+		t.Errorf("statement position wrong. got=%s", pos)
+	}
+}
+
+func TestParsingErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = ;", "1:9: parse func for ; not found"},
+		{"let x 5;", "1:7: expect next token to be =, but got INT"},
+		{"let a = 1;\nif (a { a }", "2:7: expect next token to be ), but got {"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+		errs := p.ErrorList()
+		if len(errs) == 0 {
+			t.Errorf("expected parser errors for %q, got none", tt.input)
+			continue
+		}
+		if errs[0].Error() != tt.expected {
+			t.Errorf("wrong error for %q. expected=%q, got=%q",
+				tt.input, tt.expected, errs[0].Error())
+		}
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	inputs := []string{
+		"let x = 5; return x;",
+		"-a * !b + c / d - e; a < b == b > a != true",
+		`let s = "hello" + "";`,
+		"if (x < y) { x } else { y }; if (x) { }",
+		"let add = fn(a, b) { a + b }; add(1, fn() { }())",
+		`[1, [], {"a": 1, 2: "b", true: {}}][0]`,
+		"// leading\nlet x = 1; // trailing\n\tx\n",
+	}
+	for _, input := range inputs {
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		data, err := ast.EncodeJSON(program)
+		if err != nil {
+			t.Fatalf("EncodeJSON failed for %q: %s", input, err)
+		}
+		decoded, err := ast.DecodeJSON(data)
+		if err != nil {
+			t.Fatalf("DecodeJSON failed for %q: %s", input, err)
+		}
+		// the encoding is lossless, the decoded program is identical when
+		// its encoding is
+		again, err := ast.EncodeJSON(decoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(again) != string(data) {
+			t.Errorf("wrong program decoded for %q.\nexpected=%s\ngot=     %s", input, data, again)
+		}
+		if decoded.String() != program.String() {
+			t.Errorf("wrong program decoded for %q. expected=%s, got=%s", input, program.String(), decoded.String())
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Package profile measures where the evaluation of a Monkey program spends
+// its time. A Profiler is an evaluator.Tracer aggregating the time and the
+// number of calls per function, it writes them as a table or as folded
+// stacks for flame graphs.
+package profile
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/lycheng/monkey-go/evaluator"
+	"github.com/lycheng/monkey-go/object"
+)
+
+// Func holds the measures of a function
+type Func struct {
+	Name  string        // name and line of the definition of Monkey functions, as "add:3"
+	Calls int           // number of calls
+	Self  time.Duration // time spent in the function itself
+	Total time.Duration // time spent in the function and the functions it called
+
+	active int // calls on the stack, the time of recursive calls is counted once in Total
+}
+
+// Profiler is a tracer measuring the functions of an evaluation
+type Profiler struct {
+	now    func() time.Time
+	stack  []*call
+	funcs  map[string]*Func
+	stacks map[string]time.Duration // self time by folded stack
+}
+
+// call is an active call of a function
+type call struct {
+	fn       *Func
+	stack    string // folded stack of the call
+	start    time.Time
+	children time.Duration
+}
+
+// New returns a profiler without measures
+func New() *Profiler {
+	return &Profiler{
+		now:    time.Now,
+		funcs:  make(map[string]*Func),
+		stacks: make(map[string]time.Duration),
+	}
+}
+
+// label names the function of a frame
+func label(frame *evaluator.Frame) string {
+	if frame.Function != nil {
+		return fmt.Sprintf("%s:%d", frame.Name(), frame.Function.Body.Pos().Line)
+	}
+	return frame.Name()
+}
+
+// Enter starts the measure of a call
+func (p *Profiler) Enter(e *evaluator.Evaluator, frame *evaluator.Frame) {
+	name := label(frame)
+	fn, ok := p.funcs[name]
+	if !ok {
+		fn = &Func{Name: name}
+		p.funcs[name] = fn
+	}
+	fn.Calls++
+	fn.active++
+
+	stack := name
+	if n := len(p.stack); n > 0 {
+		stack = p.stack[n-1].stack + ";" + name
+	}
+	p.stack = append(p.stack, &call{fn: fn, stack: stack, start: p.now()})
+}
+
+// Exit ends the measure of the innermost call
+func (p *Profiler) Exit(e *evaluator.Evaluator, frame *evaluator.Frame, result object.Object) {
+	n := len(p.stack)
+	if n == 0 {
+		return
+	}
+	c := p.stack[n-1]
+	p.stack = p.stack[:n-1]
+
+	elapsed := p.now().Sub(c.start)
+	self := elapsed - c.children
+	c.fn.Self += self
+	c.fn.active--
+	if c.fn.active == 0 {
+		c.fn.Total += elapsed
+	}
+	p.stacks[c.stack] += self
+	if n > 1 {
+		p.stack[n-2].children += elapsed
+	}
+}
+
+// Error is part of the tracer interface, errors are not measured
+func (p *Profiler) Error(e *evaluator.Evaluator, err *object.Error) {}
+
+// Funcs returns the measured functions by decreasing self time
+func (p *Profiler) Funcs() []*Func {
+	funcs := make([]*Func, 0, len(p.funcs))
+	for _, fn := range p.funcs {
+		funcs = append(funcs, fn)
+	}
+	sort.Slice(funcs, func(i, j int) bool {
+		if funcs[i].Self != funcs[j].Self {
+			return funcs[i].Self > funcs[j].Self
+		}
+		return funcs[i].Name < funcs[j].Name
+	})
+	return funcs
+}
+
+// WriteFolded writes a line per call stack, the frames separated by
+// semicolons followed by the self time in nanoseconds, as read by
+// flamegraph.pl and speedscope
+func (p *Profiler) WriteFolded(w io.Writer) error {
+	stacks := make([]string, 0, len(p.stacks))
+	for stack := range p.stacks {
+		stacks = append(stacks, stack)
+	}
+	sort.Strings(stacks)
+	for _, stack := range stacks {
+		if _, err := fmt.Fprintf(w, "%s %d\n", stack, p.stacks[stack].Nanoseconds()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteText writes a table of the functions by decreasing self time
+func (p *Profiler) WriteText(w io.Writer) error {
+	funcs := p.Funcs()
+	var total time.Duration
+	for _, fn := range funcs {
+		total += fn.Self
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "self\tself%%\ttotal\tcalls\t %s\n", "function")
+	for _, fn := range funcs {
+		percent := 0.0
+		if total > 0 {
+			percent = 100 * float64(fn.Self) / float64(total)
+		}
+		fmt.Fprintf(tw, "%s\t%.1f%%\t%s\t%d\t %s\n",
+			fn.Self, percent, fn.Total, fn.Calls, fn.Name)
+	}
+	return tw.Flush()
+}
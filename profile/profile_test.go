@@ -0,0 +1,97 @@
+package profile
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/lycheng/monkey-go/evaluator"
+	"github.com/lycheng/monkey-go/lexer"
+	"github.com/lycheng/monkey-go/object"
+	"github.com/lycheng/monkey-go/parser"
+)
+
+const input = `let fib = fn(n) {
+  if (n < 2) { return n; }
+  fib(n - 1) + fib(n - 2)
+};
+let double = fn(x) { x * 2 };
+len([double(fib(3))])`
+
+// profile evaluates the input with a clock moving 1ms each time it's read
+func profile(t *testing.T) *Profiler {
+	p := New()
+	var clock time.Time
+	p.now = func() time.Time {
+		clock = clock.Add(time.Millisecond)
+		return clock
+	}
+	e := evaluator.New()
+	e.Tracer = p
+	program := parser.New(lexer.New(input)).ParseProgram()
+	result := e.Eval(program, object.NewEnvironment())
+	if result.Inspect() != "1" {
+		t.Fatalf("wrong result %s", result.Inspect())
+	}
+	return p
+}
+
+func TestFuncs(t *testing.T) {
+	expected := map[string]Func{
+		// the clock is read on entry and exit, fib(3) calls fib 4 more times
+		"fib:1":    {Calls: 5, Self: 9 * time.Millisecond, Total: 9 * time.Millisecond},
+		"double:5": {Calls: 1, Self: time.Millisecond, Total: time.Millisecond},
+		"len":      {Calls: 1, Self: time.Millisecond, Total: time.Millisecond},
+		"main":     {Calls: 1, Self: 4 * time.Millisecond, Total: 15 * time.Millisecond},
+	}
+	funcs := profile(t).Funcs()
+	if len(funcs) != len(expected) {
+		t.Fatalf("wrong number of functions. expected=%d, got=%d", len(expected), len(funcs))
+	}
+	for _, fn := range funcs {
+		want, ok := expected[fn.Name]
+		if !ok {
+			t.Errorf("unexpected function %s", fn.Name)
+			continue
+		}
+		if fn.Calls != want.Calls || fn.Self != want.Self || fn.Total != want.Total {
+			t.Errorf("wrong measures for %s. expected=%+v, got=%+v", fn.Name, want, *fn)
+		}
+	}
+	if funcs[0].Name != "fib:1" {
+		t.Errorf("functions not sorted by self time, first is %s", funcs[0].Name)
+	}
+}
+
+func TestWriteFolded(t *testing.T) {
+	var out bytes.Buffer
+	if err := profile(t).WriteFolded(&out); err != nil {
+		t.Fatal(err)
+	}
+	expected := `main 4000000
+main;double:5 1000000
+main;fib:1 3000000
+main;fib:1;fib:1 4000000
+main;fib:1;fib:1;fib:1 2000000
+main;len 1000000
+`
+	if out.String() != expected {
+		t.Errorf("wrong folded stacks.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	var out bytes.Buffer
+	if err := profile(t).WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+	expected := `  self  self%  total  calls function
+   9ms  60.0%    9ms      5 fib:1
+   4ms  26.7%   15ms      1 main
+   1ms   6.7%    1ms      1 double:5
+   1ms   6.7%    1ms      1 len
+`
+	if out.String() != expected {
+		t.Errorf("wrong table.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
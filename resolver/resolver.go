@@ -0,0 +1,159 @@
+// Package resolver binds the variables of the functions of a Monkey program
+// to slots, so the evaluator reads them by index instead of looking their
+// names up in maps.
+//
+// Every call of a function gets an environment with a slot per parameter
+// and per name bound by a let statement or a function declaration of its
+// body, the blocks of if expressions share the slots of their function. An
+// identifier naming a variable of a function is annotated with the number
+// of functions between its use and that function, and with the slot of the
+// variable. Globals and built-ins keep being looked up by name, since the
+// host and the REPL bind them at run time.
+//
+// A let statement may come after the uses of its name, or not be evaluated
+// at all, so a slot is only used once set: until then the name is looked
+// up in the enclosing environments, as without resolution.
+package resolver
+
+import (
+	"github.com/lycheng/monkey-go/ast"
+)
+
+// scope for the variables of a function
+type scope struct {
+	outer *scope
+	slots map[string]int
+	fn    *ast.FunctionLiteral
+}
+
+func (s *scope) declare(ident *ast.Identifier) {
+	s.slots[ident.Value] = len(s.fn.Locals)
+	s.fn.Locals = append(s.fn.Locals, ident.Value)
+}
+
+// Resolve annotates the identifiers and the function literals of program.
+// It must be called before the program is evaluated concurrently.
+func Resolve(program *ast.Program) {
+	for _, stmt := range program.Statements {
+		resolve(stmt, nil)
+	}
+}
+
+func resolve(node ast.Node, s *scope) {
+	switch node := node.(type) {
+	case *ast.BlockStatement:
+		for _, stmt := range node.Statements {
+			resolve(stmt, s)
+		}
+	case *ast.LetStatement:
+		resolve(node.Name, s)
+		resolve(node.Value, s)
+	case *ast.ReturnStatement:
+		resolve(node.ReturnValue, s)
+	case *ast.ExpressionStatement:
+		resolve(node.Expression, s)
+	case *ast.FunctionStatement:
+		resolve(node.Function.Name, s)
+		resolve(node.Function, s)
+	case *ast.Identifier:
+		node.Local = false
+		for depth, sc := 0, s; sc != nil; depth, sc = depth+1, sc.outer {
+			if slot, ok := sc.slots[node.Value]; ok {
+				node.Local, node.Depth, node.Slot = true, depth, slot
+				return
+			}
+		}
+	case *ast.PrefixExpression:
+		resolve(node.Right, s)
+	case *ast.InfixExpression:
+		resolve(node.Left, s)
+		resolve(node.Right, s)
+	case *ast.IfExpression:
+		resolve(node.Condition, s)
+		resolve(node.Consequence, s)
+		if node.Alternative != nil {
+			resolve(node.Alternative, s)
+		}
+	case *ast.FunctionLiteral:
+		fn := &scope{outer: s, slots: make(map[string]int), fn: node}
+		node.Locals = make([]string, 0, len(node.Parameters))
+		for _, param := range node.Parameters {
+			fn.declare(param)
+		}
+		declareLets(node.Body, fn)
+		for _, param := range node.Parameters {
+			resolve(param, fn)
+		}
+		resolve(node.Body, fn)
+	case *ast.CallExpression:
+		resolve(node.Function, s)
+		for _, arg := range node.Arguments {
+			resolve(arg, s)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			resolve(el, s)
+		}
+	case *ast.IndexExpression:
+		resolve(node.Left, s)
+		resolve(node.Index, s)
+	case *ast.HashLiteral:
+		for key, value := range node.Pairs {
+			resolve(key, s)
+			resolve(value, s)
+		}
+	}
+}
+
+// declareLets gives a slot to the names bound by the let statements and the
+// function declarations of a function body, including the ones in blocks of
+// if expressions but not the ones of nested functions
+func declareLets(node ast.Node, s *scope) {
+	switch node := node.(type) {
+	case *ast.BlockStatement:
+		for _, stmt := range node.Statements {
+			declareLets(stmt, s)
+		}
+	case *ast.LetStatement:
+		if _, ok := s.slots[node.Name.Value]; !ok {
+			s.declare(node.Name)
+		}
+		declareLets(node.Value, s)
+	case *ast.FunctionStatement:
+		if _, ok := s.slots[node.Function.Name.Value]; !ok {
+			s.declare(node.Function.Name)
+		}
+	case *ast.ReturnStatement:
+		declareLets(node.ReturnValue, s)
+	case *ast.ExpressionStatement:
+		declareLets(node.Expression, s)
+	case *ast.PrefixExpression:
+		declareLets(node.Right, s)
+	case *ast.InfixExpression:
+		declareLets(node.Left, s)
+		declareLets(node.Right, s)
+	case *ast.IfExpression:
+		declareLets(node.Condition, s)
+		declareLets(node.Consequence, s)
+		if node.Alternative != nil {
+			declareLets(node.Alternative, s)
+		}
+	case *ast.CallExpression:
+		declareLets(node.Function, s)
+		for _, arg := range node.Arguments {
+			declareLets(arg, s)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			declareLets(el, s)
+		}
+	case *ast.IndexExpression:
+		declareLets(node.Left, s)
+		declareLets(node.Index, s)
+	case *ast.HashLiteral:
+		for key, value := range node.Pairs {
+			declareLets(key, s)
+			declareLets(value, s)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package resolver
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lycheng/monkey-go/ast"
+	"github.com/lycheng/monkey-go/lexer"
+	"github.com/lycheng/monkey-go/parser"
+)
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // identifiers in source order, name@depth:slot for the local ones
+		locals   string // locals of the function literals in source order
+	}{
+		{"let x = 1; x", "x x", ""},
+		{"fn(a, b) { a + b + c }", "a@0:0 b@0:1 a@0:0 b@0:1 c", "[a b]"},
+		{"fn(a) { let b = a; let a = b; a }", "a@0:0 b@0:1 a@0:0 a@0:0 b@0:1 a@0:0", "[a b]"},
+		{"fn(a) { fn(b) { a + b } }", "a@0:0 b@0:0 a@1:0 b@0:0", "[a] [b]"},
+		{"fn() { x; let x = 1; if (x) { let y = 2 } else { let z = y } }",
+			"x@0:0 x@0:0 x@0:0 y@0:1 z@0:2 y@0:1", "[x y z]"},
+		{"let f = fn(n) { if (n) { f(n - 1) } else { 0 } }", "f n@0:0 n@0:0 f n@0:0", "[n]"},
+		{"fn(a, a) { a }", "a@0:1 a@0:1 a@0:1", "[a a]"},
+		{"fn() { let g = fn() { let h = 1; g }; len(g) }", "g@0:0 h@0:0 g@1:0 len g@0:0", "[g] [h]"},
+		{"fn f(n) { f(n) }", "f n@0:0 f n@0:0", "[n]"},
+		{"fn() { even(1); fn even(n) { odd(n) } fn odd(n) { even(n) } }",
+			"even@0:0 even@0:0 n@0:0 odd@1:1 n@0:0 odd@0:1 n@0:0 even@1:0 n@0:0", "[even odd] [n] [n]"},
+	}
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		Resolve(program)
+		var idents, locals []string
+		collect(program, &idents, &locals)
+		if got := strings.Join(idents, " "); got != tt.expected {
+			t.Errorf("wrong identifiers for %s.\nexpected=%q\ngot=     %q", tt.input, tt.expected, got)
+		}
+		if got := strings.Join(locals, " "); got != tt.locals {
+			t.Errorf("wrong locals for %s. expected=%q, got=%q", tt.input, tt.locals, got)
+		}
+	}
+}
+
+func collect(node ast.Node, idents, locals *[]string) {
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, stmt := range node.Statements {
+			collect(stmt, idents, locals)
+		}
+	case *ast.BlockStatement:
+		for _, stmt := range node.Statements {
+			collect(stmt, idents, locals)
+		}
+	case *ast.LetStatement:
+		collect(node.Name, idents, locals)
+		collect(node.Value, idents, locals)
+	case *ast.ReturnStatement:
+		collect(node.ReturnValue, idents, locals)
+	case *ast.ExpressionStatement:
+		collect(node.Expression, idents, locals)
+	case *ast.FunctionStatement:
+		collect(node.Function.Name, idents, locals)
+		collect(node.Function, idents, locals)
+	case *ast.Identifier:
+		if node.Local {
+			*idents = append(*idents, fmt.Sprintf("%s@%d:%d", node.Value, node.Depth, node.Slot))
+		} else {
+			*idents = append(*idents, node.Value)
+		}
+	case *ast.PrefixExpression:
+		collect(node.Right, idents, locals)
+	case *ast.InfixExpression:
+		collect(node.Left, idents, locals)
+		collect(node.Right, idents, locals)
+	case *ast.IfExpression:
+		collect(node.Condition, idents, locals)
+		collect(node.Consequence, idents, locals)
+		if node.Alternative != nil {
+			collect(node.Alternative, idents, locals)
+		}
+	case *ast.FunctionLiteral:
+		*locals = append(*locals, fmt.Sprint(node.Locals))
+		for _, param := range node.Parameters {
+			collect(param, idents, locals)
+		}
+		collect(node.Body, idents, locals)
+	case *ast.CallExpression:
+		collect(node.Function, idents, locals)
+		for _, arg := range node.Arguments {
+			collect(arg, idents, locals)
+		}
+	}
+}
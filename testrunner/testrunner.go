@@ -0,0 +1,115 @@
+// Package testrunner runs tests written in Monkey.
+//
+// A test is a function without parameters declared at the top level, or
+// bound by a top level let statement, with a name starting with "test_".
+// Every test runs in a fresh environment: the file is evaluated again before
+// the test function is called, so tests don't share state. A test fails when
+// the evaluation returns an error, such as the ones of the assert and
+// assert_eq built-in functions.
+package testrunner
+
+import (
+	"strings"
+	"time"
+
+	"github.com/lycheng/monkey-go/ast"
+	"github.com/lycheng/monkey-go/evaluator"
+	"github.com/lycheng/monkey-go/lexer"
+	"github.com/lycheng/monkey-go/object"
+	"github.com/lycheng/monkey-go/parser"
+	"github.com/lycheng/monkey-go/token"
+)
+
+// Prefix of the names of test functions
+const Prefix = "test_"
+
+// FileSuffix of the names of the files holding tests
+const FileSuffix = "_test.mk"
+
+// Result of a test
+type Result struct {
+	Name    string
+	Pos     token.Position // position of the statement of the test
+	Err     *object.Error  // nil when the test passed
+	Elapsed time.Duration
+}
+
+// Passed reports whether the test passed
+func (r *Result) Passed() bool {
+	return r.Err == nil
+}
+
+// Tests returns the function statements and the let statements of the test
+// functions of program in source order
+func Tests(program *ast.Program) []ast.Statement {
+	var tests []ast.Statement
+	for _, stmt := range program.Statements {
+		if testName(stmt) != nil {
+			tests = append(tests, stmt)
+		}
+	}
+	return tests
+}
+
+// testName returns the name of the test function of stmt, nil when stmt
+// doesn't bind a test function
+func testName(stmt ast.Statement) *ast.Identifier {
+	var fn *ast.FunctionLiteral
+	var name *ast.Identifier
+	switch stmt := stmt.(type) {
+	case *ast.FunctionStatement:
+		fn, name = stmt.Function, stmt.Function.Name
+	case *ast.LetStatement:
+		fn, _ = stmt.Value.(*ast.FunctionLiteral)
+		name = stmt.Name
+	}
+	if fn == nil || len(fn.Parameters) != 0 || !strings.HasPrefix(name.Value, Prefix) {
+		return nil
+	}
+	return name
+}
+
+// Run parses src and runs its tests whose name match accepts, all of them
+// when match is nil. The parse errors are returned when src doesn't parse.
+func Run(src string, match func(name string) bool) ([]*Result, []*parser.Error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.ErrorList()) != 0 {
+		return nil, p.ErrorList()
+	}
+
+	var results []*Result
+	for _, test := range Tests(program) {
+		name := testName(test)
+		if match != nil && !match(name.Value) {
+			continue
+		}
+		start := time.Now()
+		result := &Result{Name: name.Value, Pos: test.Pos()}
+		result.Err = runTest(program, test)
+		result.Elapsed = time.Since(start)
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// runTest evaluates program in a fresh environment and calls the test
+func runTest(program *ast.Program, test ast.Statement) *object.Error {
+	name := testName(test)
+	env := object.NewEnvironment()
+	e := evaluator.New()
+	if err, ok := e.Eval(program, env).(*object.Error); ok {
+		return err
+	}
+	if _, ok := env.Get(name.Value); !ok {
+		return &object.Error{Message: "test function not defined", Pos: test.Pos()}
+	}
+	call := &ast.CallExpression{
+		Token:    token.Token{Type: token.LPAREN, Literal: "(", Pos: test.Pos()},
+		Function: name,
+	}
+	if err, ok := e.Eval(call, env).(*object.Error); ok {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package testrunner
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+)
+
+const input = `let counter = [];
+let add = fn(a, b) { a + b };
+
+let test_add = fn() {
+  assert_eq(add(1, 2), 3);
+};
+
+let test_fresh_environment = fn() {
+  let counter = push(counter, 1);
+  assert_eq(len(counter), 1)
+};
+
+let test_fails = fn() {
+  assert(add(1, 1) == 3, "one and one");
+  assert(false)
+};
+
+let test_error = fn() { add(1, true) };
+
+let test_with_param = fn(x) { x };
+let helper = fn() { 1 };
+
+fn test_declared() { assert_eq(twice(2), 4) }
+fn twice(x) { add(x, x) }
+`
+
+func TestRun(t *testing.T) {
+	results, errs := Run(input, nil)
+	if errs != nil {
+		t.Fatalf("unexpected parse errors %v", errs)
+	}
+	expected := []string{
+		"test_add 4:1 ok",
+		"test_fresh_environment 8:1 ok",
+		"test_fails 13:1 14:3: assertion failed: one and one",
+		"test_error 18:1 2:22: type mismatch: INTEGER + BOOLEAN",
+		"test_declared 23:1 ok",
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("expected %d results, got %d", len(expected), len(results))
+	}
+	for i, r := range results {
+		got := fmt.Sprintf("%s %s ok", r.Name, r.Pos)
+		if !r.Passed() {
+			got = fmt.Sprintf("%s %s %s: %s", r.Name, r.Pos, r.Err.Pos, r.Err.Message)
+		}
+		if got != expected[i] {
+			t.Errorf("wrong result %d. expected=%q, got=%q", i, expected[i], got)
+		}
+	}
+}
+
+func TestRunMatch(t *testing.T) {
+	re := regexp.MustCompile("add|fresh")
+	results, _ := Run(input, re.MatchString)
+	if len(results) != 2 || results[0].Name != "test_add" || results[1].Name != "test_fresh_environment" {
+		t.Errorf("wrong tests run: %+v", results)
+	}
+}
+
+func TestRunParseError(t *testing.T) {
+	results, errs := Run("let test_x = ;", nil)
+	if results != nil || len(errs) == 0 {
+		t.Errorf("expected parse errors, got results=%v errs=%v", results, errs)
+	}
+}
@@ -1,9 +1,12 @@
 package token
 
+import "fmt"
+
 // Token types
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
+	COMMENT = "COMMENT"
 
 	// Identitfiers + literals
 	IDENT  = "IDENT"
@@ -48,10 +51,29 @@ const (
 // Type for monkey's token type
 type Type string
 
+// Position for the location of a token in the source
+type Position struct {
+	Offset int // byte offset, starting at 0
+	Line   int // line number, starting at 1
+	Column int // column number in bytes, starting at 1
+}
+
+// IsValid reports whether the position has been set
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+// String returns the position as line:column
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
 // Token for monkey's token
 type Token struct {
 	Type    Type
 	Literal string
+	Pos     Position
 }
 
 var keywords = map[string]Type{